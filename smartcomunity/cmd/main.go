@@ -20,12 +20,15 @@ func main() {
 		env = "dev"
 	}
 	config.Init(env)
+	if err := service.CheckPaymentConfig(); err != nil {
+		log.Fatalf("invalid payment config: %v", err)
+	}
 
 	global.InitDB(config.Conf.DB.DSN)
 	global.InitRedis(config.Conf.Redis.Addr, "")
 	global.InitMinio(config.Conf.MinIO)
 
-	if err := global.DB.AutoMigrate(
+	models := []interface{}{
 		&model.SysUser{},
 		&model.SysRole{},
 		&model.SysMenu{},
@@ -35,29 +38,73 @@ func main() {
 		&model.ProductCategory{},
 		&model.HotProduct{},
 		&model.Promotion{},
+		&model.Coupon{},
+		&model.UserCoupon{},
+		&model.FlashSale{},
 		&model.Store{},
 		&model.StoreProduct{},
+		&model.StoreStockMovement{},
+		&model.SysUserStore{},
 		&model.Cart{},
 		&model.Order{},
 		&model.OrderItem{},
+		&model.OrderStatusLog{},
+		&model.OrderRefund{},
+		&model.OrderRefundItem{},
+		&model.GroupBuy{},
+		&model.GroupBuyMember{},
+		&model.StoreSettlement{},
+		&model.StoreSettlementItem{},
 		&model.Notice{},
 		&model.NoticeRead{},
+		&model.UserNotice{},
 		&model.Repair{},
 		&model.Visitor{},
 		&model.Parking{},
 		&model.PropertyFee{},
+		&model.Household{},
+		&model.PropertyFeeRate{},
+		&model.PropertyBillingRun{},
+		&model.Receipt{},
 		&model.Favorite{},
 		&model.ProductComment{},
 		&model.SysTransaction{},
+		&model.Transfer{},
+		&model.LedgerAccount{},
+		&model.LedgerEntry{},
+		&model.LedgerLine{},
+		&model.WalletReconciliation{},
+		&model.WalletDiscrepancy{},
+		&model.IdempotencyRecord{},
+		&model.PaymentCharge{},
 		&model.GreenPointRecord{},
+		&model.GreenPointBatch{},
+		&model.GarbageUpload{},
+		&model.RedeemItem{},
+		&model.Redemption{},
 		&model.AIReport{},
 		&model.ChatMessage{},
 		&model.CommunityMessage{},
-	); err != nil {
+	}
+	if err := service.MigrateMoneyColumns(models...); err != nil {
+		log.Fatalf("money column migration failed: %v", err)
+	}
+	if err := global.DB.AutoMigrate(models...); err != nil {
 		log.Fatalf("database migration failed: %v", err)
 	}
 
 	service.StartAIReportDailyScheduler()
+	service.StartOrderTimeoutScheduler()
+	service.StartPromotionExpiryScheduler()
+	service.StartFlashSaleWorker()
+	service.StartGroupBuyScheduler()
+	service.StartPickupScheduler()
+	service.StartSettlementScheduler()
+	service.StartWalletReconcileScheduler()
+	service.StartPropertyDunningScheduler()
+	service.StartTransferSettleScheduler()
+	service.StartGreenPointExpiryScheduler()
+	service.StartLeaderboardRebuildScheduler()
 
 	r := gin.Default()
 	r.Use(middleware.CORS())
@@ -9,12 +9,22 @@ import (
 var Conf *Config
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	DB       DBConfig       `mapstructure:"db"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	MinIO    MinIOConfig    `mapstructure:"minio"`
-	AI       AIConfig       `mapstructure:"ai"`
-	FaceBody FaceBodyConfig `mapstructure:"facebody"`
+	Server     ServerConfig     `mapstructure:"server"`
+	DB         DBConfig         `mapstructure:"db"`
+	Redis      RedisConfig      `mapstructure:"redis"`
+	MinIO      MinIOConfig      `mapstructure:"minio"`
+	AI         AIConfig         `mapstructure:"ai"`
+	FaceBody   FaceBodyConfig   `mapstructure:"facebody"`
+	Order      OrderConfig      `mapstructure:"order"`
+	Settlement SettlementConfig `mapstructure:"settlement"`
+	Payment    PaymentConfig    `mapstructure:"payment"`
+	Property   PropertyConfig   `mapstructure:"property"`
+	SMS        SMSConfig        `mapstructure:"sms"`
+	Transfer   TransferConfig   `mapstructure:"transfer"`
+	GreenPoint GreenPointConfig `mapstructure:"green_point"`
+
+	// Env 当前加载的配置环境(dev/prod)，由 Init 设置
+	Env string `mapstructure:"-"`
 }
 
 type ServerConfig struct {
@@ -49,6 +59,96 @@ type FaceBodyConfig struct {
 	Endpoint        string `mapstructure:"endpoint"`
 }
 
+type OrderConfig struct {
+	// 未支付订单超时自动取消的分钟数，<=0 时使用默认值
+	PaymentTimeoutMinutes int `mapstructure:"payment_timeout_minutes"`
+	// 扫描超时订单的间隔秒数，<=0 时使用默认值
+	CancelScanIntervalSeconds int `mapstructure:"cancel_scan_interval_seconds"`
+	// 自提订单支付后需在多少小时内取货，<=0 时使用默认值
+	PickupDeadlineHours int `mapstructure:"pickup_deadline_hours"`
+	// 距自提截止多少小时发送取货提醒，<=0 时使用默认值
+	PickupRemindHours int `mapstructure:"pickup_remind_hours"`
+}
+
+type SettlementConfig struct {
+	// 结算周期: daily 或 weekly，默认 daily
+	Cycle string `mapstructure:"cycle"`
+	// 每天几点生成结算单，默认 2 点
+	RunHour int `mapstructure:"run_hour"`
+}
+
+type PaymentConfig struct {
+	// 充值使用的支付渠道，默认 mock；mock 渠道仅允许在 dev 环境使用
+	Provider string `mapstructure:"provider"`
+	// 渠道异步通知地址，mock 渠道为空时直接在进程内回调
+	NotifyURL string `mapstructure:"notify_url"`
+	// 未支付的充值单多少分钟后关闭，<=0 时使用默认值
+	ChargeExpireMinutes int        `mapstructure:"charge_expire_minutes"`
+	Mock                MockConfig `mapstructure:"mock"`
+}
+
+type MockConfig struct {
+	// 通知签名密钥
+	Secret string `mapstructure:"secret"`
+	// 模拟用户支付耗时，<=0 时使用默认值
+	NotifyDelaySeconds int `mapstructure:"notify_delay_seconds"`
+}
+
+type PropertyConfig struct {
+	// 物业费到期日为账期当月的第几天，<=0 时默认 15 日
+	DueDay int `mapstructure:"due_day"`
+	// 到期后的宽限天数，宽限期内不计滞纳金
+	GraceDays int `mapstructure:"grace_days"`
+	// 滞纳金日费率，如 0.0005 表示每天按账单金额的万分之五计收
+	LateFeeDailyRate float64 `mapstructure:"late_fee_daily_rate"`
+	// 滞纳金上限占账单金额的比例，<=0 时不封顶
+	LateFeeCapRate float64 `mapstructure:"late_fee_cap_rate"`
+	// 逾期第几天发送催缴提醒，如 [1, 7, 15]
+	RemindDays []int `mapstructure:"remind_days"`
+	// 从第几次催缴开始同时发送短信(从 1 开始)，<=0 时只发站内信
+	SMSFromStage int `mapstructure:"sms_from_stage"`
+}
+
+type SMSConfig struct {
+	// 通知类短信的推送地址，为空时只记录日志
+	NoticeURL string `mapstructure:"notice_url"`
+}
+
+type TransferConfig struct {
+	// 转账发起后多少分钟内可撤销，到期后才入账到收款人，<=0 时立即到账
+	PendingMinutes int `mapstructure:"pending_minutes"`
+	// 按角色配置的转账限额，未单独配置的角色使用 default
+	Limits map[string]TransferLimit `mapstructure:"limits"`
+}
+
+type TransferLimit struct {
+	// 单笔上限(元)，<=0 时不限
+	PerTransaction float64 `mapstructure:"per_transaction"`
+	// 当日累计上限(元)，<=0 时不限
+	Daily float64 `mapstructure:"daily"`
+}
+
+type GreenPointConfig struct {
+	// 积分在获得年份之后第几年的年底过期，<=0 时默认 1（次年 12 月 31 日）
+	ExpireYears int `mapstructure:"expire_years"`
+	// "即将过期"查询的默认天数，<=0 时默认 30
+	ExpiringSoonDays int `mapstructure:"expiring_soon_days"`
+	// 垃圾分类拍照每人每天最多获得的积分，<=0 时不限
+	DailyRewardCap int `mapstructure:"daily_reward_cap"`
+	// 每人每小时最多上传次数，<=0 时默认 10
+	UploadsPerHour int `mapstructure:"uploads_per_hour"`
+	// 两次上传的最小间隔秒数，<=0 时不限制
+	UploadIntervalSeconds int `mapstructure:"upload_interval_seconds"`
+	// 感知哈希汉明距离不超过该值视为重复图片，<=0 时默认 5
+	DuplicateDistance int `mapstructure:"duplicate_distance"`
+	// 近似图片查重只比对最近多少天的上传，<=0 时默认 30 天；完全相同的图片不受此限制
+	DuplicateWindowDays int `mapstructure:"duplicate_window_days"`
+	// EXIF 拍摄时间距上传时间超过多少小时需人工审核，<=0 时不检查
+	MaxPhotoAgeHours int `mapstructure:"max_photo_age_hours"`
+	// 没有 EXIF 拍摄时间的图片是否需要人工审核
+	RequireExif bool `mapstructure:"require_exif"`
+}
+
 func Init(env string) {
 	fileName := "dev"
 	if env != "" {
@@ -65,6 +165,7 @@ func Init(env string) {
 	if err := viper.Unmarshal(&Conf); err != nil {
 		log.Fatalf("failed to unmarshal config: %v", err)
 	}
+	Conf.Env = fileName
 
 	log.Println("config loaded successfully")
 }
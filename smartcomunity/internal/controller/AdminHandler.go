@@ -121,11 +121,11 @@ func (h *AdminHandler) AssignRole(c *gin.Context) {
 
 func (h *AdminHandler) UpdateUserBalance(c *gin.Context) {
 	var req struct {
-		UserID int64   `json:"user_id"`
-		Amount float64 `json:"amount"`
+		UserID int64       `json:"user_id"`
+		Amount model.Money `json:"amount"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Fail(c, "invalid request parameters")
+		failWithBindError(c, "invalid request parameters", err)
 		return
 	}
 	if err := h.Service.UpdateUserBalance(req.UserID, req.Amount); err != nil {
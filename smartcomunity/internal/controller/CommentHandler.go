@@ -58,3 +58,31 @@ func (h *CommentHandler) List(c *gin.Context) {
 		"total": total,
 	})
 }
+
+// ListManaged 后台评论列表 (Admin/Store)
+func (h *CommentHandler) ListManaged(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListManagedComments(userID.(int64), role.(string), productID, page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Delete 删除评论 (Admin/Store)
+func (h *CommentHandler) Delete(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := h.Service.DeleteComment(userID.(int64), role.(string), id); err != nil {
+		failWithStoreScope(c, "删除失败: ", err)
+		return
+	}
+	response.Success(c, nil)
+}
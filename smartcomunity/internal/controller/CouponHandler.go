@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"strconv"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CouponHandler struct {
+	Service service.CouponService
+}
+
+// Create 创建/修改优惠券 (Admin)
+func (h *CouponHandler) Create(c *gin.Context) {
+	var req model.Coupon
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.CreateCoupon(&req); err != nil {
+		response.Fail(c, "操作失败: "+err.Error())
+		return
+	}
+	response.Success(c, req)
+}
+
+// ListAll 优惠券列表 (Admin)
+func (h *CouponHandler) ListAll(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	list, total, err := h.Service.ListCoupons(page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Claimable 可领取的优惠券
+func (h *CouponHandler) Claimable(c *gin.Context) {
+	list, err := h.Service.ListClaimable()
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// Claim 领取优惠券
+func (h *CouponHandler) Claim(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		CouponID int64 `json:"coupon_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	userCoupon, err := h.Service.ClaimCoupon(userID.(int64), req.CouponID)
+	if err != nil {
+		response.Fail(c, "领取失败: "+err.Error())
+		return
+	}
+	response.Success(c, userCoupon)
+}
+
+// Mine 我的券包
+func (h *CouponHandler) Mine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var status *int
+	if statusStr := c.Query("status"); statusStr != "" {
+		if s, err := strconv.Atoi(statusStr); err == nil {
+			status = &s
+		}
+	}
+	list, err := h.Service.ListUserCoupons(userID.(int64), status)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"smartcommunity/internal/global"
 	"smartcommunity/internal/model"
@@ -15,7 +17,12 @@ import (
 )
 
 type FinanceHandler struct {
-	Service service.FinanceService
+	Service          service.FinanceService
+	LedgerService    service.LedgerService
+	ReconcileService service.ReconcileService
+	PaymentService   service.PaymentService
+	StatementService service.StatementService
+	TransferService  service.TransferService
 }
 
 type financePayRequest struct {
@@ -38,34 +45,8 @@ func (h *FinanceHandler) Pay(c *gin.Context) {
 
 	var confidence *float32
 	if req.PayType == service.AuthTypeFace {
-		var user model.SysUser
-		if err := global.DB.Select("id", "face_registered", "face_image_url").First(&user, uid).Error; err != nil {
-			response.Fail(c, "user not found")
-			return
-		}
-		if !user.FaceRegistered || strings.TrimSpace(user.FaceImageURL) == "" {
-			response.Fail(c, "face is not registered, please register first")
-			return
-		}
-		if strings.TrimSpace(req.FaceImageURL) == "" {
-			response.Fail(c, "face image is required")
-			return
-		}
-
-		faceService, err := service.NewFaceService()
-		if err != nil {
-			response.Fail(c, "face service init failed: "+err.Error())
-			return
-		}
-
-		score, err := faceService.CompareFace(user.FaceImageURL, req.FaceImageURL)
-		if err != nil {
-			log.Printf("face verification error: user_id=%d registered_url=%s capture_url=%s err=%v", uid, user.FaceImageURL, req.FaceImageURL, err)
-			response.Fail(c, "人脸验证失败，请稍后重试")
-			return
-		}
-		if score < 85.0 {
-			response.Fail(c, "人脸不匹配，请重试")
+		score, ok := verifyPaymentFace(c, uid, req.FaceImageURL)
+		if !ok {
 			return
 		}
 		confidence = &score
@@ -87,6 +68,41 @@ func (h *FinanceHandler) Pay(c *gin.Context) {
 	response.Success(c, result)
 }
 
+// verifyPaymentFace 比对人脸，失败时直接写入响应并返回 false
+func verifyPaymentFace(c *gin.Context, uid int64, faceImageURL string) (float32, bool) {
+	var user model.SysUser
+	if err := global.DB.Select("id", "face_registered", "face_image_url").First(&user, uid).Error; err != nil {
+		response.Fail(c, "user not found")
+		return 0, false
+	}
+	if !user.FaceRegistered || strings.TrimSpace(user.FaceImageURL) == "" {
+		response.Fail(c, "face is not registered, please register first")
+		return 0, false
+	}
+	if strings.TrimSpace(faceImageURL) == "" {
+		response.Fail(c, "face image is required")
+		return 0, false
+	}
+
+	faceService, err := service.NewFaceService()
+	if err != nil {
+		response.Fail(c, "face service init failed: "+err.Error())
+		return 0, false
+	}
+
+	score, err := faceService.CompareFace(user.FaceImageURL, faceImageURL)
+	if err != nil {
+		log.Printf("face verification error: user_id=%d registered_url=%s capture_url=%s err=%v", uid, user.FaceImageURL, faceImageURL, err)
+		response.Fail(c, "人脸验证失败，请稍后重试")
+		return 0, false
+	}
+	if score < 85.0 {
+		response.Fail(c, "人脸不匹配，请重试")
+		return 0, false
+	}
+	return score, true
+}
+
 func (h *FinanceHandler) ListPropertyFee(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -103,38 +119,152 @@ func (h *FinanceHandler) ListPropertyFee(c *gin.Context) {
 func (h *FinanceHandler) Recharge(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var req struct {
-		Amount float64 `json:"amount"`
+		Amount   model.Money `json:"amount"`
+		Scenario string      `json:"scenario"` // 仅 mock 渠道使用: success/fail/delay/lost
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Fail(c, "invalid request parameters")
+		failWithBindError(c, "invalid request parameters", err)
 		return
 	}
 
-	if err := h.Service.Recharge(userID.(int64), req.Amount); err != nil {
+	charge, err := h.Service.Recharge(userID.(int64), req.Amount, map[string]string{"scenario": req.Scenario})
+	if err != nil {
 		response.Fail(c, "recharge failed: "+err.Error())
 		return
 	}
+	response.Success(c, charge)
+}
+
+func (h *FinanceHandler) RechargeStatus(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	status, err := h.PaymentService.GetCharge(userID.(int64), c.Query("charge_no"))
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, status)
+}
+
+func (h *FinanceHandler) ListRecharges(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	list, total, err := h.PaymentService.ListCharges(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch recharge list")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *FinanceHandler) RefundRecharge(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ChargeNo string `json:"charge_no"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+
+	if err := h.PaymentService.RefundCharge(userID.(int64), req.ChargeNo, req.Reason); err != nil {
+		response.Fail(c, "refund failed: "+err.Error())
+		return
+	}
 	response.Success(c, nil)
 }
 
 func (h *FinanceHandler) Transfer(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var req struct {
-		ToMobile string  `json:"to_mobile"`
-		Amount   float64 `json:"amount"`
+		ToMobile     string      `json:"to_mobile"`
+		Amount       model.Money `json:"amount"`
+		ConfirmToken string      `json:"confirm_token"`
+		PayType      string      `json:"pay_type"` // password(默认) 或 face
+		Password     string      `json:"password"`
+		FaceImageURL string      `json:"face_image_url"`
+		Remark       string      `json:"remark"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Fail(c, "invalid request parameters")
+		failWithBindError(c, "invalid request parameters", err)
 		return
 	}
 
-	if err := h.Service.Transfer(userID.(int64), req.ToMobile, req.Amount); err != nil {
+	uid := userID.(int64)
+	if strings.EqualFold(strings.TrimSpace(req.PayType), service.AuthTypeFace) {
+		if _, ok := verifyPaymentFace(c, uid, req.FaceImageURL); !ok {
+			return
+		}
+	}
+
+	transfer, err := h.Service.Transfer(uid, service.TransferRequest{
+		ToMobile:     req.ToMobile,
+		Amount:       req.Amount,
+		ConfirmToken: req.ConfirmToken,
+		AuthType:     req.PayType,
+		Password:     req.Password,
+		Remark:       req.Remark,
+	})
+	if err != nil {
 		response.Fail(c, "transfer failed: "+err.Error())
 		return
 	}
+	response.Success(c, transfer)
+}
+
+// PreviewTransfer 确认收款人信息，返回脱敏姓名和转账确认凭证
+func (h *FinanceHandler) PreviewTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ToMobile string      `json:"to_mobile"`
+		Amount   model.Money `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failWithBindError(c, "invalid request parameters", err)
+		return
+	}
+
+	preview, err := h.TransferService.Preview(userID.(int64), req.ToMobile, req.Amount)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, preview)
+}
+
+// CancelTransfer 撤销期内撤回转账
+func (h *FinanceHandler) CancelTransfer(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+	if err := h.TransferService.Cancel(userID.(int64), req.ID); err != nil {
+		response.Fail(c, "cancel failed: "+err.Error())
+		return
+	}
 	response.Success(c, nil)
 }
 
+// ListTransfers 我发出和收到的转账
+func (h *FinanceHandler) ListTransfers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	list, total, err := h.TransferService.List(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch transfer list")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
 func (h *FinanceHandler) ListTransactions(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -152,6 +282,86 @@ func (h *FinanceHandler) ListTransactions(c *gin.Context) {
 	})
 }
 
+// ExportStatement 导出本人指定时间段的余额与积分对账单
+func (h *FinanceHandler) ExportStatement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	filter, format, err := parseStatementRequest(c)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	if filter.End.Sub(filter.Start) > service.StatementUserMaxRange {
+		response.Fail(c, "statement range cannot exceed one year")
+		return
+	}
+	filter.UserID = userID.(int64)
+	h.writeStatement(c, format, filter, false)
+}
+
+// AdminExportStatement 财务导出全部或指定用户的对账单
+func (h *FinanceHandler) AdminExportStatement(c *gin.Context) {
+	filter, format, err := parseStatementRequest(c)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	filter.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	h.writeStatement(c, format, filter, true)
+}
+
+func (h *FinanceHandler) writeStatement(c *gin.Context, format string, filter service.StatementFilter, withUser bool) {
+	contentType := "text/csv; charset=utf-8"
+	if format == service.StatementFormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	filename := fmt.Sprintf("statement_%s_%s.%s", filter.Start.Format("20060102"), filter.End.AddDate(0, 0, -1).Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	// 边查边写，响应头发出后出错只能记录日志并中断下载
+	if err := h.StatementService.Export(c.Writer, format, filter, withUser); err != nil {
+		log.Printf("export statement failed, user_id=%d err=%v", filter.UserID, err)
+		c.Error(err)
+	}
+}
+
+// parseStatementRequest 解析 start/end(YYYY-MM-DD，含当天)、types 和 format，默认导出最近 30 天的 CSV
+func parseStatementRequest(c *gin.Context) (service.StatementFilter, string, error) {
+	var filter service.StatementFilter
+	format := strings.ToLower(c.DefaultQuery("format", service.StatementFormatCSV))
+	if format != service.StatementFormatCSV && format != service.StatementFormatXLSX {
+		return filter, "", errors.New("format must be csv or xlsx")
+	}
+
+	today := time.Now()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	filter.Start = today.AddDate(0, 0, -29)
+	filter.End = today.AddDate(0, 0, 1)
+	if v := c.Query("start"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return filter, "", errors.New("start must be YYYY-MM-DD")
+		}
+		filter.Start = start
+	}
+	if v := c.Query("end"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return filter, "", errors.New("end must be YYYY-MM-DD")
+		}
+		filter.End = end.AddDate(0, 0, 1)
+	}
+	if !filter.End.After(filter.Start) {
+		return filter, "", errors.New("end date must not be earlier than start date")
+	}
+
+	types, err := service.ParseStatementTypes(c.Query("types"))
+	if err != nil {
+		return filter, "", err
+	}
+	filter.Types = types
+	return filter, format, nil
+}
+
 func (h *FinanceHandler) CreatePropertyFee(c *gin.Context) {
 	var req model.PropertyFee
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -181,6 +391,110 @@ func (h *FinanceHandler) ListAllPropertyFees(c *gin.Context) {
 	})
 }
 
+func (h *FinanceHandler) ListLedgerAccounts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	ownerID, _ := strconv.ParseInt(c.Query("owner_id"), 10, 64)
+
+	list, total, err := h.LedgerService.ListAccounts(c.Query("type"), ownerID, page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch ledger accounts")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *FinanceHandler) ListLedgerEntries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	accountID, _ := strconv.ParseInt(c.Query("account_id"), 10, 64)
+
+	list, total, err := h.LedgerService.ListEntries(accountID, c.Query("biz_type"), page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch ledger entries")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *FinanceHandler) CheckLedger(c *gin.Context) {
+	report, err := h.LedgerService.CheckConsistency()
+	if err != nil {
+		response.Fail(c, "ledger check failed: "+err.Error())
+		return
+	}
+	response.Success(c, report)
+}
+
+func (h *FinanceHandler) RunReconciliation(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	run, err := h.ReconcileService.RunWalletReconciliation(userID.(int64))
+	if err != nil {
+		response.Fail(c, "reconciliation failed: "+err.Error())
+		return
+	}
+	response.Success(c, run)
+}
+
+func (h *FinanceHandler) ListReconciliations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	list, total, err := h.ReconcileService.ListReconciliations(page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch reconciliation list")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *FinanceHandler) ListDiscrepancies(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	reconciliationID, _ := strconv.ParseInt(c.Query("reconciliation_id"), 10, 64)
+	var status *int
+	if statusStr := c.Query("status"); statusStr != "" {
+		if s, err := strconv.Atoi(statusStr); err == nil {
+			status = &s
+		}
+	}
+
+	list, total, err := h.ReconcileService.ListDiscrepancies(reconciliationID, status, page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch discrepancies")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *FinanceHandler) ResolveDiscrepancy(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID         int64  `json:"id"`
+		Resolution string `json:"resolution"` // balance / history / ignore
+		Remark     string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+
+	if err := h.ReconcileService.ResolveDiscrepancy(userID.(int64), req.ID, req.Resolution, req.Remark); err != nil {
+		response.Fail(c, "resolve failed: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// failWithBindError 金额精度错误直接提示用户，其他绑定错误返回通用提示
+func failWithBindError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, model.ErrSubCentAmount) {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Fail(c, msg)
+}
+
 func parseFinancePayRequest(c *gin.Context) (*financePayRequest, error) {
 	var raw map[string]interface{}
 	if err := c.ShouldBindJSON(&raw); err != nil {
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"strconv"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FlashSaleHandler struct {
+	Service service.FlashSaleService
+}
+
+// Create 创建秒杀活动 (Admin)
+func (h *FlashSaleHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req model.FlashSale
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.CreateFlashSale(userID.(int64), &req); err != nil {
+		response.Fail(c, "创建失败: "+err.Error())
+		return
+	}
+	response.Success(c, req)
+}
+
+// End 提前结束秒杀活动 (Admin)
+func (h *FlashSaleHandler) End(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	sale, err := h.Service.EndFlashSale(userID.(int64), req.ID)
+	if err != nil {
+		response.Fail(c, "操作失败: "+err.Error())
+		return
+	}
+	response.Success(c, sale)
+}
+
+// ListAll 秒杀活动列表 (Admin)
+func (h *FlashSaleHandler) ListAll(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	list, total, err := h.Service.ListFlashSales(page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// List 进行中的秒杀活动
+func (h *FlashSaleHandler) List(c *gin.Context) {
+	list, err := h.Service.ListActiveFlashSales()
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// Buy 秒杀下单，返回订单号后轮询结果
+func (h *FlashSaleHandler) Buy(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		FlashSaleID int64 `json:"flash_sale_id"`
+		Quantity    int   `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	orderNo, err := h.Service.Buy(userID.(int64), req.FlashSaleID, req.Quantity)
+	if err != nil {
+		response.Fail(c, "抢购失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"order_no": orderNo, "status": "queued"})
+}
+
+// Result 查询秒杀订单结果
+func (h *FlashSaleHandler) Result(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	result, err := h.Service.GetBuyResult(userID.(int64), c.Query("order_no"))
+	if err != nil {
+		response.Fail(c, "查询失败: "+err.Error())
+		return
+	}
+	response.Success(c, result)
+}
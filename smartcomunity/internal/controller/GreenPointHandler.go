@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"strconv"
 
 	"smartcommunity/internal/service"
@@ -22,6 +23,10 @@ func (h *GreenPointHandler) UploadGarbage(c *gin.Context) {
 	}
 
 	result, err := h.Service.UploadGarbage(userID.(int64), file)
+	if errors.Is(err, service.ErrGarbageUploadTooFrequent) {
+		response.FailWithCode(c, 429, err.Error())
+		return
+	}
 	if err != nil {
 		response.Fail(c, "garbage recognition failed: "+err.Error())
 		return
@@ -32,7 +37,7 @@ func (h *GreenPointHandler) UploadGarbage(c *gin.Context) {
 
 func (h *GreenPointHandler) Leaderboard(c *gin.Context) {
 	limit, _ := strconv.ParseInt(c.DefaultQuery("limit", "10"), 10, 64)
-	list, err := h.Service.GetLeaderboard(limit)
+	list, err := h.Service.GetScopedLeaderboard(c.Query("period"), c.Query("building"), limit)
 	if err != nil {
 		response.Fail(c, "failed to fetch leaderboard")
 		return
@@ -40,3 +45,69 @@ func (h *GreenPointHandler) Leaderboard(c *gin.Context) {
 
 	response.Success(c, gin.H{"list": list})
 }
+
+// MyRank 我在指定榜单(period=all/week/month，可选 building)中的名次
+func (h *GreenPointHandler) MyRank(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	rank, err := h.Service.MyLeaderboardRank(userID.(int64), c.Query("period"), c.Query("building"))
+	if err != nil {
+		response.Fail(c, "failed to fetch rank: "+err.Error())
+		return
+	}
+	response.Success(c, rank)
+}
+
+// RebuildLeaderboard 从积分流水重建所有排行榜 (Admin)
+func (h *GreenPointHandler) RebuildLeaderboard(c *gin.Context) {
+	if err := h.Service.RebuildLeaderboards(); err != nil {
+		response.Fail(c, "failed to rebuild leaderboard: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Expiring 我的积分过期计划，days 指定"即将过期"的天数范围
+func (h *GreenPointHandler) Expiring(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	days, _ := strconv.Atoi(c.Query("days"))
+	view, err := h.Service.ExpiringSoon(userID.(int64), days)
+	if err != nil {
+		response.Fail(c, "failed to fetch point expiry: "+err.Error())
+		return
+	}
+	response.Success(c, view)
+}
+
+// AdminUploads 垃圾分类上传记录，status=0 为待审核队列 (Admin)
+func (h *GreenPointHandler) AdminUploads(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	userID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	list, total, err := h.Service.ListGarbageUploads(queryStatus(c), userID, page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch uploads")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// ReviewUpload 审核被风控拦截的上传，通过时可指定发放积分 (Admin)
+func (h *GreenPointHandler) ReviewUpload(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID      int64  `json:"id" binding:"required"`
+		Approve bool   `json:"approve"`
+		Points  int    `json:"points"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid parameters")
+		return
+	}
+	upload, err := h.Service.ReviewGarbageUpload(userID.(int64), req.ID, req.Approve, req.Points, req.Note)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, upload)
+}
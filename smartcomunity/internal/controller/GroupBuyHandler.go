@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupBuyHandler struct {
+	Service service.GroupBuyService
+}
+
+// Open 发起拼团，返回的订单通过 /order/pay 支付
+func (h *GroupBuyHandler) Open(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ProductID   int64  `json:"product_id"`
+		StoreID     int64  `json:"store_id"`
+		TargetCount int    `json:"target_count"`
+		Deadline    string `json:"deadline"` // 2006-01-02 15:04:05
+		Quantity    int    `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	deadline, err := time.ParseInLocation("2006-01-02 15:04:05", req.Deadline, time.Local)
+	if err != nil {
+		response.Fail(c, "截止时间格式错误")
+		return
+	}
+
+	group, order, err := h.Service.OpenGroup(userID.(int64), req.ProductID, req.StoreID, req.TargetCount, deadline, req.Quantity)
+	if err != nil {
+		response.Fail(c, "发起拼团失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"group": group, "order": order})
+}
+
+// Join 参团，返回的订单通过 /order/pay 支付
+func (h *GroupBuyHandler) Join(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		GroupID  int64 `json:"group_id"`
+		Quantity int   `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+
+	group, order, err := h.Service.JoinGroup(userID.(int64), req.GroupID, req.Quantity)
+	if err != nil {
+		response.Fail(c, "参团失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"group": group, "order": order})
+}
+
+// List 可参加的拼团
+func (h *GroupBuyHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+
+	list, total, err := h.Service.ListOpenGroups(productID, page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Detail 拼团详情
+func (h *GroupBuyHandler) Detail(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	group, err := h.Service.GetGroupDetail(id)
+	if err != nil {
+		response.Fail(c, "拼团不存在")
+		return
+	}
+	response.Success(c, group)
+}
+
+// Mine 我参与的拼团
+func (h *GroupBuyHandler) Mine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListUserGroups(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
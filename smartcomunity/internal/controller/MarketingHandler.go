@@ -20,7 +20,7 @@ func (h *MarketingHandler) Create(c *gin.Context) {
 		return
 	}
 	if err := h.Service.CreatePromotion(&req); err != nil {
-		response.Fail(c, "操作失败")
+		response.Fail(c, err.Error())
 		return
 	}
 	response.Success(c, req)
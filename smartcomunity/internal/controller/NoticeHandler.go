@@ -102,3 +102,28 @@ func (h *NoticeHandler) Read(c *gin.Context) {
 	}
 	response.Success(c, nil)
 }
+
+// Mine 我的站内信
+func (h *NoticeHandler) Mine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, unread, err := h.Service.ListUserNotices(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total, "unread": unread})
+}
+
+// ReadMine 站内信标记已读，id 为 0 时全部已读
+func (h *NoticeHandler) ReadMine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := h.Service.MarkUserNoticeRead(userID.(int64), id); err != nil {
+		response.Fail(c, "操作失败")
+		return
+	}
+	response.Success(c, nil)
+}
@@ -18,8 +18,10 @@ func (h *OrderHandler) Create(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var req struct {
-		Items   []model.CartItemParam `json:"items"`
-		StoreID int64                 `json:"store_id"`
+		Items        []model.CartItemParam `json:"items"`
+		StoreID      int64                 `json:"store_id"`
+		UserCouponID int64                 `json:"user_coupon_id"`
+		DeliveryType int                   `json:"delivery_type"` // 1 配送 2 到店自提
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Fail(c, "invalid request parameters")
@@ -30,16 +32,18 @@ func (h *OrderHandler) Create(c *gin.Context) {
 		return
 	}
 
-	order, err := h.Service.CreateOrder(userID.(int64), req.StoreID, req.Items)
+	order, err := h.Service.CreateOrder(userID.(int64), req.StoreID, req.Items, req.UserCouponID, req.DeliveryType)
 	if err != nil {
 		response.Fail(c, "create order failed: "+err.Error())
 		return
 	}
 
 	response.Success(c, gin.H{
-		"order_no":     order.OrderNo,
-		"total_amount": order.TotalAmount,
-		"order_id":     order.ID,
+		"order_no":        order.OrderNo,
+		"total_amount":    order.TotalAmount,
+		"discount_amount": order.DiscountAmount,
+		"order_id":        order.ID,
+		"expire_at":       order.CreatedAt.Add(service.OrderPaymentTimeout()),
 	})
 }
 
@@ -93,6 +97,8 @@ func (h *OrderHandler) Pay(c *gin.Context) {
 }
 
 func (h *OrderHandler) Ship(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	var req struct {
 		ID int64 `json:"id"`
 	}
@@ -101,13 +107,34 @@ func (h *OrderHandler) Ship(c *gin.Context) {
 		return
 	}
 
-	if err := h.Service.ShipOrder(req.ID); err != nil {
-		response.Fail(c, err.Error())
+	if err := h.Service.ShipOrder(userID.(int64), role.(string), req.ID); err != nil {
+		failWithStoreScope(c, "", err)
 		return
 	}
 	response.Success(c, nil)
 }
 
+// VerifyPickup 门店扫码或输入自提码核销订单，二维码内容即为自提码
+func (h *OrderHandler) VerifyPickup(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		StoreID int64  `json:"store_id"`
+		Code    string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+
+	order, err := h.Service.VerifyPickup(userID.(int64), role.(string), req.StoreID, req.Code)
+	if err != nil {
+		failWithStoreScope(c, "verify pickup failed: ", err)
+		return
+	}
+	response.Success(c, order)
+}
+
 func (h *OrderHandler) Receive(c *gin.Context) {
 	userID, _ := c.Get("userID")
 	var req struct {
@@ -142,12 +169,33 @@ func (h *OrderHandler) Cancel(c *gin.Context) {
 	response.Success(c, nil)
 }
 
+func (h *OrderHandler) Close(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		ID     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+
+	if err := h.Service.CloseOrder(userID.(int64), role.(string), req.ID, req.Reason); err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
 func (h *OrderHandler) ListAll(c *gin.Context) {
+	operatorID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 	userID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
 
-	list, total, err := h.Service.ListAllOrders(page, size, userID)
+	list, total, err := h.Service.ListAllOrders(operatorID.(int64), role.(string), page, size, userID)
 	if err != nil {
 		response.Fail(c, "failed to fetch order list")
 		return
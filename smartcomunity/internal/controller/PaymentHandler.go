@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"smartcommunity/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentHandler struct {
+	Service service.PaymentService
+}
+
+// Notify 支付渠道异步通知，按渠道约定返回纯文本 success/fail
+func (h *PaymentHandler) Notify(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "fail")
+		return
+	}
+	params := make(map[string]string, len(c.Request.Form))
+	for k := range c.Request.Form {
+		params[k] = c.Request.Form.Get(k)
+	}
+
+	if err := h.Service.HandleNotify(c.Param("provider"), params); err != nil {
+		log.Printf("handle payment notify failed, provider=%s chargeNo=%s err=%v", c.Param("provider"), params["charge_no"], err)
+		c.String(http.StatusOK, "fail")
+		return
+	}
+	c.String(http.StatusOK, "success")
+}
@@ -25,8 +25,8 @@ func (h *ProductHandler) List(c *gin.Context) {
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 	name := c.Query("name")
 	sort := c.Query("sort")
-	minPrice, _ := strconv.ParseFloat(c.Query("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.Query("max_price"), 64)
+	minPrice, _ := model.ParseMoney(c.Query("min_price"))
+	maxPrice, _ := model.ParseMoney(c.Query("max_price"))
 
 	// Added category_id filter
 	categoryID, _ := strconv.ParseInt(c.Query("category_id"), 10, 64)
@@ -39,7 +39,10 @@ func (h *ProductHandler) List(c *gin.Context) {
 		}
 	}
 
-	list, total, err := h.Service.GetList(page, size, name, minPrice, maxPrice, sort, categoryID, isPromotion, status)
+	// 传入 store_id 时按门店铺货过滤并返回门店库存
+	storeID, _ := strconv.ParseInt(c.Query("store_id"), 10, 64)
+
+	list, total, err := h.Service.GetList(page, size, name, minPrice, maxPrice, sort, categoryID, isPromotion, status, storeID)
 	if err != nil {
 		response.Fail(c, "获取失败")
 		return
@@ -64,8 +67,10 @@ func (h *ProductHandler) Detail(c *gin.Context) {
 	response.Success(c, product)
 }
 
-// Create 发布商品 (Admin)
+// Create 发布商品 (Admin/Store)
 func (h *ProductHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	var req ProductReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Fail(c, "参数错误")
@@ -77,38 +82,57 @@ func (h *ProductHandler) Create(c *gin.Context) {
 
 	// Handler now just passes request payload which includes OriginalPrice
 	// Service handles logic
-	if err := h.Service.Create(&req.Product); err != nil {
-		response.Fail(c, "发布失败")
+	if err := h.Service.Create(userID.(int64), role.(string), &req.Product); err != nil {
+		failWithStoreScope(c, "发布失败: ", err)
 		return
 	}
 	response.Success(c, req.Product)
 }
 
-// Update 修改商品 (Admin)
+// Update 修改商品 (Admin/Store)
 func (h *ProductHandler) Update(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	var req ProductReq
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Fail(c, "参数错误")
 		return
 	}
-	if err := h.Service.Update(&req.Product); err != nil {
-		response.Fail(c, "更新失败")
+	if err := h.Service.Update(userID.(int64), role.(string), &req.Product); err != nil {
+		failWithStoreScope(c, "更新失败: ", err)
 		return
 	}
 	response.Success(c, nil)
 }
 
-// Delete 删除商品 (Admin)
+// Delete 删除商品 (Admin/Store)
 func (h *ProductHandler) Delete(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	idStr := c.Param("id")
 	id, _ := strconv.ParseInt(idStr, 10, 64)
-	if err := h.Service.Delete(id); err != nil {
-		response.Fail(c, "删除失败")
+	if err := h.Service.Delete(userID.(int64), role.(string), id); err != nil {
+		failWithStoreScope(c, "删除失败: ", err)
 		return
 	}
 	response.Success(c, nil)
 }
 
+// ListManaged 后台商品列表 (Admin/Store)
+func (h *ProductHandler) ListManaged(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListManaged(userID.(int64), role.(string), page, size, c.Query("name"))
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
 // GetRank 获取销量排行
 func (h *ProductHandler) GetRank(c *gin.Context) {
 	list, err := h.Service.GetSalesRank()
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"strconv"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PropertyHandler struct {
+	Service service.PropertyBillingService
+}
+
+// SaveHousehold 新增/修改房屋 (Admin/Property)
+func (h *PropertyHandler) SaveHousehold(c *gin.Context) {
+	var req model.Household
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.SaveHousehold(&req); err != nil {
+		response.Fail(c, "保存失败: "+err.Error())
+		return
+	}
+	response.Success(c, req)
+}
+
+// DeleteHousehold 删除房屋 (Admin/Property)
+func (h *PropertyHandler) DeleteHousehold(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := h.Service.DeleteHousehold(id); err != nil {
+		response.Fail(c, "删除失败")
+		return
+	}
+	response.Success(c, nil)
+}
+
+// ListHouseholds 房屋列表 (Admin/Property)
+func (h *PropertyHandler) ListHouseholds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	list, total, err := h.Service.ListHouseholds(c.Query("building"), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// SaveRate 新增/修改收费标准 (Admin/Property)
+func (h *PropertyHandler) SaveRate(c *gin.Context) {
+	var req model.PropertyFeeRate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failWithBindError(c, "参数错误", err)
+		return
+	}
+	if err := h.Service.SaveRate(&req); err != nil {
+		response.Fail(c, "保存失败: "+err.Error())
+		return
+	}
+	response.Success(c, req)
+}
+
+// DeleteRate 删除收费标准 (Admin/Property)
+func (h *PropertyHandler) DeleteRate(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := h.Service.DeleteRate(id); err != nil {
+		response.Fail(c, "删除失败")
+		return
+	}
+	response.Success(c, nil)
+}
+
+// ListRates 收费标准列表 (Admin/Property)
+func (h *PropertyHandler) ListRates(c *gin.Context) {
+	list, err := h.Service.ListRates()
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// RunBilling 批量出账，dry_run 为 true 时仅预览 (Admin/Property)
+func (h *PropertyHandler) RunBilling(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		Month    string `json:"month"`    // 2006-01
+		Building string `json:"building"` // 为空时全小区出账
+		DryRun   bool   `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+
+	summary, err := h.Service.RunBilling(userID.(int64), req.Month, req.Building, req.DryRun)
+	if err != nil {
+		response.Fail(c, "出账失败: "+err.Error())
+		return
+	}
+	response.Success(c, summary)
+}
+
+// ListBillingRuns 出账记录 (Admin/Property)
+func (h *PropertyHandler) ListBillingRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+
+	list, total, err := h.Service.ListBillingRuns(page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Arrears 欠费报表，按业主和账期汇总 (Admin/Property)
+func (h *PropertyHandler) Arrears(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 20
+	}
+	report, err := h.Service.ArrearsReport(c.Query("building"), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, report)
+}
+
+// Remind 立即执行一次逾期催缴 (Admin/Property)
+func (h *PropertyHandler) Remind(c *gin.Context) {
+	sent, err := h.Service.RemindOverduePropertyFees()
+	if err != nil {
+		response.Fail(c, "催缴失败: "+err.Error())
+		return
+	}
+	response.Success(c, gin.H{"sent": sent})
+}
@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReceiptHandler struct {
+	Service service.ReceiptService
+}
+
+type receiptActionRequest struct {
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// List 我的收据
+func (h *ReceiptHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.List(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Detail 收据详情，普通用户只能查看本人的收据
+func (h *ReceiptHandler) Detail(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	receipt, err := h.Service.Get(receiptOwnerScope(c), id)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, receipt)
+}
+
+// Download 下载收据文件，format=pdf(默认)|html
+func (h *ReceiptHandler) Download(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	format := c.DefaultQuery("format", service.ReceiptFormatPDF)
+
+	receipt, data, err := h.Service.Download(receiptOwnerScope(c), id, format)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+
+	contentType := "application/pdf"
+	if format == service.ReceiptFormatHTML {
+		contentType = "text/html; charset=utf-8"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=receipt_%s.%s", receipt.ReceiptNo, format))
+	c.Data(200, contentType, data)
+}
+
+// AdminList 后台收据列表 (Admin)
+func (h *ReceiptHandler) AdminList(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	userID, _ := strconv.ParseInt(c.Query("user_id"), 10, 64)
+	status, _ := strconv.Atoi(c.Query("status"))
+
+	list, total, err := h.Service.AdminList(userID, c.Query("biz_type"), status, c.Query("receipt_no"), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Reissue 补开收据 (Admin)
+func (h *ReceiptHandler) Reissue(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req receiptActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	receipt, err := h.Service.Reissue(userID.(int64), req.ID, req.Reason)
+	if err != nil {
+		response.Fail(c, "补开失败: "+err.Error())
+		return
+	}
+	response.Success(c, receipt)
+}
+
+// Void 作废收据 (Admin)
+func (h *ReceiptHandler) Void(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req receiptActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	receipt, err := h.Service.Void(userID.(int64), req.ID, req.Reason)
+	if err != nil {
+		response.Fail(c, "作废失败: "+err.Error())
+		return
+	}
+	response.Success(c, receipt)
+}
+
+// receiptOwnerScope 管理员可查看全部收据，其他角色只能查看本人的
+func receiptOwnerScope(c *gin.Context) int64 {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	if role == "admin" {
+		return 0
+	}
+	return userID.(int64)
+}
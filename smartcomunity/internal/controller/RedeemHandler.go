@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"strconv"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RedeemHandler struct {
+	Service service.RedeemService
+}
+
+// Catalog 积分兑换目录
+func (h *RedeemHandler) Catalog(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	itemType, _ := strconv.Atoi(c.Query("type"))
+
+	list, total, err := h.Service.ListCatalog(itemType, page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Item 兑换品详情
+func (h *RedeemHandler) Item(c *gin.Context) {
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	item, err := h.Service.GetItem(id)
+	if err != nil {
+		response.Fail(c, err.Error())
+		return
+	}
+	response.Success(c, item)
+}
+
+// Redeem 积分兑换
+func (h *RedeemHandler) Redeem(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ItemID   int64  `json:"item_id"`
+		Quantity int    `json:"quantity"`
+		Remark   string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	redemption, err := h.Service.Redeem(userID.(int64), req.ItemID, req.Quantity, req.Remark)
+	if err != nil {
+		response.Fail(c, "兑换失败: "+err.Error())
+		return
+	}
+	response.Success(c, redemption)
+}
+
+// Mine 我的兑换记录
+func (h *RedeemHandler) Mine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListMine(userID.(int64), queryStatus(c), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// CancelMine 取消待兑付的兑换
+func (h *RedeemHandler) CancelMine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.CancelMine(userID.(int64), req.ID); err != nil {
+		response.Fail(c, "取消失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// SaveItem 新增/修改兑换品 (Admin/Store)
+func (h *RedeemHandler) SaveItem(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req model.RedeemItem
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.SaveItem(userID.(int64), role.(string), &req); err != nil {
+		failWithStoreScope(c, "保存失败: ", err)
+		return
+	}
+	response.Success(c, req)
+}
+
+// DeleteItem 删除兑换品 (Admin/Store)
+func (h *RedeemHandler) DeleteItem(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	id, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err := h.Service.DeleteItem(userID.(int64), role.(string), id); err != nil {
+		failWithStoreScope(c, "删除失败: ", err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// ListItems 后台兑换品列表 (Admin/Store)
+func (h *RedeemHandler) ListItems(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListManagedItems(userID.(int64), role.(string), c.Query("name"), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// ListRecords 后台兑付单列表 (Admin/Store)
+func (h *RedeemHandler) ListRecords(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListRecords(userID.(int64), role.(string), queryStatus(c), c.Query("code"), page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Fulfil 核销兑付码完成兑付 (Admin/Store)
+func (h *RedeemHandler) Fulfil(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		ID   int64  `json:"id"`
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.Fulfil(userID.(int64), role.(string), req.ID, req.Code); err != nil {
+		failWithStoreScope(c, "兑付失败: ", err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Cancel 取消兑换并退回积分 (Admin/Store)
+func (h *RedeemHandler) Cancel(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		ID   int64  `json:"id"`
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.Cancel(userID.(int64), role.(string), req.ID, req.Note); err != nil {
+		failWithStoreScope(c, "取消失败: ", err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+func queryStatus(c *gin.Context) *int {
+	if v := c.Query("status"); v != "" {
+		if s, err := strconv.Atoi(v); err == nil {
+			return &s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"strconv"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundHandler struct {
+	Service service.RefundService
+}
+
+func (h *RefundHandler) Apply(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		OrderID int64                   `json:"order_id"`
+		Type    int                     `json:"type"`
+		Reason  string                  `json:"reason"`
+		Items   []model.RefundItemParam `json:"items"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+	if req.OrderID == 0 {
+		response.Fail(c, "missing order_id")
+		return
+	}
+
+	refund, err := h.Service.ApplyRefund(userID.(int64), req.OrderID, req.Type, req.Reason, req.Items)
+	if err != nil {
+		response.Fail(c, "apply refund failed: "+err.Error())
+		return
+	}
+	response.Success(c, refund)
+}
+
+func (h *RefundHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	list, total, err := h.Service.ListUserRefunds(userID.(int64), page, size)
+	if err != nil {
+		response.Fail(c, "failed to fetch refund list")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *RefundHandler) ListAll(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	var status *int
+	if statusStr := c.Query("status"); statusStr != "" {
+		if s, err := strconv.Atoi(statusStr); err == nil {
+			status = &s
+		}
+	}
+
+	list, total, err := h.Service.ListAllRefunds(userID.(int64), role.(string), page, size, status)
+	if err != nil {
+		response.Fail(c, "failed to fetch refund list")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+func (h *RefundHandler) Audit(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		ID      int64  `json:"id"`
+		Approve bool   `json:"approve"`
+		Remark  string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "invalid request parameters")
+		return
+	}
+
+	refund, err := h.Service.AuditRefund(userID.(int64), role.(string), req.ID, req.Approve, req.Remark)
+	if err != nil {
+		failWithStoreScope(c, "audit refund failed: ", err)
+		return
+	}
+	response.Success(c, refund)
+}
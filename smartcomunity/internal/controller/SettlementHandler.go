@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SettlementHandler struct {
+	Service service.SettlementService
+}
+
+// Generate 手动生成结算单 (Admin)
+func (h *SettlementHandler) Generate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		Start string `json:"start"` // 2006-01-02
+		End   string `json:"end"`   // 2006-01-02，不含当天
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	start, err1 := time.ParseInLocation("2006-01-02", req.Start, time.Local)
+	end, err2 := time.ParseInLocation("2006-01-02", req.End, time.Local)
+	if err1 != nil || err2 != nil {
+		response.Fail(c, "日期格式错误")
+		return
+	}
+
+	list, err := h.Service.GenerateSettlements(userID.(int64), start, end)
+	if err != nil {
+		response.Fail(c, "生成失败: "+err.Error())
+		return
+	}
+	response.Success(c, list)
+}
+
+// List 结算单列表 (Admin/Store)
+func (h *SettlementHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	storeID, _ := strconv.ParseInt(c.Query("store_id"), 10, 64)
+	var status *int
+	if statusStr := c.Query("status"); statusStr != "" {
+		if s, err := strconv.Atoi(statusStr); err == nil {
+			status = &s
+		}
+	}
+
+	list, total, err := h.Service.ListSettlements(userID.(int64), role.(string), storeID, status, page, size)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Detail 结算单详情 (Admin/Store)
+func (h *SettlementHandler) Detail(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	id, _ := strconv.ParseInt(c.Query("id"), 10, 64)
+
+	settlement, err := h.Service.GetSettlement(userID.(int64), role.(string), id)
+	if err != nil {
+		failWithStoreScope(c, "", err)
+		return
+	}
+	response.Success(c, settlement)
+}
+
+// Statement 下载结算对账单 CSV (Admin/Store)
+func (h *SettlementHandler) Statement(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	id, _ := strconv.ParseInt(c.Query("id"), 10, 64)
+
+	settlement, err := h.Service.GetSettlement(userID.(int64), role.(string), id)
+	if err != nil {
+		failWithStoreScope(c, "", err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=settlement_%s.csv", settlement.SettlementNo))
+	if err := service.WriteSettlementCSV(c.Writer, settlement); err != nil {
+		c.Error(err)
+	}
+}
+
+// Confirm 确认结算单 (Admin)
+func (h *SettlementHandler) Confirm(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.ConfirmSettlement(userID.(int64), req.ID); err != nil {
+		response.Fail(c, "操作失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Pay 标记已打款 (Admin)
+func (h *SettlementHandler) Pay(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID     int64  `json:"id"`
+		Remark string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.MarkSettlementPaid(userID.(int64), req.ID, req.Remark); err != nil {
+		response.Fail(c, "操作失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Cancel 作废结算单 (Admin)
+func (h *SettlementHandler) Cancel(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var req struct {
+		ID     int64  `json:"id"`
+		Remark string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.CancelSettlement(userID.(int64), req.ID, req.Remark); err != nil {
+		response.Fail(c, "操作失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
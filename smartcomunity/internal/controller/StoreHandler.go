@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 	"smartcommunity/internal/model"
 	"smartcommunity/internal/service"
@@ -36,15 +38,17 @@ func (h *StoreHandler) Create(c *gin.Context) {
 	response.Success(c, req)
 }
 
-// Update 修改门店 (Admin)
+// Update 修改门店 (Admin/Store)
 func (h *StoreHandler) Update(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	var req model.Store
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Fail(c, "参数错误")
 		return
 	}
-	if err := h.Service.UpdateStore(&req); err != nil {
-		response.Fail(c, "修改失败")
+	if err := h.Service.UpdateStore(userID.(int64), role.(string), &req); err != nil {
+		failWithStoreScope(c, "修改失败: ", err)
 		return
 	}
 	response.Success(c, nil)
@@ -61,8 +65,10 @@ func (h *StoreHandler) Delete(c *gin.Context) {
 	response.Success(c, nil)
 }
 
-// BindProduct 门店分配库存 (Admin)
+// BindProduct 门店分配库存 (Admin/Store)
 func (h *StoreHandler) BindProduct(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
 	var req struct {
 		StoreID   int64 `json:"store_id"`
 		ProductID int64 `json:"product_id"`
@@ -72,9 +78,154 @@ func (h *StoreHandler) BindProduct(c *gin.Context) {
 		response.Fail(c, "参数错误")
 		return
 	}
-	if err := h.Service.BindProduct(req.StoreID, req.ProductID, req.Stock); err != nil {
-		response.Fail(c, "分配失败")
+	if err := h.Service.BindProduct(userID.(int64), role.(string), req.StoreID, req.ProductID, req.Stock); err != nil {
+		failWithStoreScope(c, "分配失败: ", err)
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Stock 门店库存列表 (Admin/Store)
+func (h *StoreHandler) Stock(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	storeID, _ := strconv.ParseInt(c.Query("store_id"), 10, 64)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if storeID <= 0 {
+		response.Fail(c, "参数错误")
+		return
+	}
+
+	list, total, err := h.Service.ListStoreStock(userID.(int64), role.(string), storeID, page, size)
+	if err != nil {
+		failWithStoreScope(c, "获取失败: ", err)
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// TransferStock 门店间调拨库存 (Admin/Store)
+func (h *StoreHandler) TransferStock(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	var req struct {
+		FromStoreID int64 `json:"from_store_id"`
+		ToStoreID   int64 `json:"to_store_id"`
+		ProductID   int64 `json:"product_id"`
+		Quantity    int   `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.TransferStock(userID.(int64), role.(string), req.FromStoreID, req.ToStoreID, req.ProductID, req.Quantity); err != nil {
+		failWithStoreScope(c, "调拨失败: ", err)
 		return
 	}
 	response.Success(c, nil)
 }
+
+// StockMovements 门店库存流水 (Admin/Store)
+func (h *StoreHandler) StockMovements(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	storeID, _ := strconv.ParseInt(c.Query("store_id"), 10, 64)
+	productID, _ := strconv.ParseInt(c.Query("product_id"), 10, 64)
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "20"))
+	if storeID <= 0 {
+		response.Fail(c, "参数错误")
+		return
+	}
+
+	list, total, err := h.Service.ListStockMovements(userID.(int64), role.(string), storeID, productID, page, size)
+	if err != nil {
+		failWithStoreScope(c, "获取失败: ", err)
+		return
+	}
+	response.Success(c, gin.H{"list": list, "total": total})
+}
+
+// Mine 当前账号可管理的门店 (Admin/Store)
+func (h *StoreHandler) Mine(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	role, _ := c.Get("role")
+	list, err := h.Service.ListManagedStores(userID.(int64), role.(string))
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// BindUser 绑定门店账号 (Admin)
+func (h *StoreHandler) BindUser(c *gin.Context) {
+	var req struct {
+		UserID  int64 `json:"user_id"`
+		StoreID int64 `json:"store_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.BindUser(req.UserID, req.StoreID); err != nil {
+		response.Fail(c, "绑定失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// UnbindUser 解绑门店账号 (Admin)
+func (h *StoreHandler) UnbindUser(c *gin.Context) {
+	var req struct {
+		UserID  int64 `json:"user_id"`
+		StoreID int64 `json:"store_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.UnbindUser(req.UserID, req.StoreID); err != nil {
+		response.Fail(c, "解绑失败")
+		return
+	}
+	response.Success(c, nil)
+}
+
+// Users 门店绑定的账号 (Admin)
+func (h *StoreHandler) Users(c *gin.Context) {
+	storeID, _ := strconv.ParseInt(c.Query("store_id"), 10, 64)
+	list, err := h.Service.ListStoreUsers(storeID)
+	if err != nil {
+		response.Fail(c, "获取失败")
+		return
+	}
+	response.Success(c, list)
+}
+
+// SetCommission 设置门店佣金比例 (Admin)
+func (h *StoreHandler) SetCommission(c *gin.Context) {
+	var req struct {
+		StoreID int64   `json:"store_id"`
+		Rate    float64 `json:"rate"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, "参数错误")
+		return
+	}
+	if err := h.Service.SetCommissionRate(req.StoreID, req.Rate); err != nil {
+		response.Fail(c, "设置失败: "+err.Error())
+		return
+	}
+	response.Success(c, nil)
+}
+
+// failWithStoreScope 越权访问门店数据时返回 403，其余错误按 prefix 拼接提示
+func failWithStoreScope(c *gin.Context, prefix string, err error) {
+	if errors.Is(err, service.ErrStoreForbidden) {
+		response.FailWithCode(c, 403, err.Error())
+		return
+	}
+	response.Fail(c, prefix+err.Error())
+}
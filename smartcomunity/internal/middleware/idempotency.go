@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/service"
+	"smartcommunity/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+
+	idempotencyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLen = 128
+)
+
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等中间件
+// 请求携带 Idempotency-Key 时，同一用户同一接口的重复请求直接返回首次响应，不再执行业务；未携带时直接放行
+func Idempotency() gin.HandlerFunc {
+	idempotencyService := &service.IdempotencyService{}
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyHeader))
+		userID, exists := c.Get("userID")
+		if key == "" || !exists {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			response.FailWithCode(c, 400, "Idempotency-Key 过长")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.Fail(c, "参数错误")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := c.Request.Method + " " + c.FullPath()
+		record, replay, err := idempotencyService.Begin(userID.(int64), scope, key, service.IdempotencyHash([]byte(scope), body), idempotencyTTL)
+		switch {
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			response.FailWithCode(c, http.StatusConflict, err.Error())
+			c.Abort()
+			return
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			response.FailWithCode(c, http.StatusUnprocessableEntity, err.Error())
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("idempotency begin failed, userID=%v key=%s err=%v", userID, key, err)
+			response.FailWithCode(c, response.CodeServerBusy, "系统繁忙，请稍后重试")
+			c.Abort()
+			return
+		}
+		if replay {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.HTTPStatus, record.ContentType, []byte(record.ResponseBody))
+			c.Abort()
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := idempotencyService.Release(record); err != nil {
+				log.Printf("release idempotency key failed, id=%d err=%v", record.ID, err)
+			}
+		}()
+
+		c.Next()
+
+		// 业务失败也以 HTTP 200 返回(余额不足、系统繁忙等)，只缓存业务成功的响应，失败时释放 Key 允许重试
+		status := writer.Status()
+		if status != http.StatusOK || !writer.Written() {
+			return
+		}
+		var result struct {
+			Code int `json:"code"`
+		}
+		if err := json.Unmarshal(writer.body.Bytes(), &result); err != nil || result.Code != response.CodeSuccess {
+			return
+		}
+		if err := idempotencyService.Complete(record, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("save idempotency response failed, id=%d err=%v", record.ID, err)
+			return
+		}
+		completed = true
+	}
+}
@@ -10,6 +10,11 @@ type Cart struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Product   Product   `gorm:"foreignKey:ProductID" json:"product"`
+
+	// 以下为按当前活动计算的价格，不落库
+	Amount         Money  `gorm:"-" json:"amount"`
+	PromotionID    int64  `gorm:"-" json:"promotion_id"`
+	PromotionTitle string `gorm:"-" json:"promotion_title"`
 }
 
 type CartItemParam struct {
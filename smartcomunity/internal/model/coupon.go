@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// 优惠券类型
+const (
+	CouponTypeFixed     = 1 // 无门槛立减
+	CouponTypePercent   = 2 // 折扣券
+	CouponTypeThreshold = 3 // 满减券
+)
+
+// 用户优惠券状态
+const (
+	UserCouponUnused  = 0
+	UserCouponUsed    = 1
+	UserCouponExpired = 2
+)
+
+// Coupon 优惠券模板
+type Coupon struct {
+	ID           int64     `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"type:varchar(128)" json:"name"`
+	Type         int       `gorm:"not null" json:"type"`
+	Amount       Money     `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`       // 立减/满减金额
+	Discount     float64   `gorm:"type:decimal(4,2);not null;default:0.00" json:"discount"`      // 折扣率，如 0.85 表示 85 折
+	MinAmount    Money     `gorm:"type:decimal(10,2);not null;default:0.00" json:"min_amount"`   // 使用门槛
+	MaxDiscount  Money     `gorm:"type:decimal(10,2);not null;default:0.00" json:"max_discount"` // 折扣券最高优惠，0 不限
+	CategoryID   int64     `gorm:"column:category_id;not null;default:0" json:"category_id"`     // 0 表示全品类
+	StoreID      int64     `gorm:"column:store_id;not null;default:0" json:"store_id"`           // 0 表示全部门店
+	TotalCount   int       `gorm:"column:total_count;not null;default:0" json:"total_count"`     // 发放总量，0 不限
+	ClaimedCount int       `gorm:"column:claimed_count;not null;default:0" json:"claimed_count"`
+	PerUserLimit int       `gorm:"column:per_user_limit;not null;default:1" json:"per_user_limit"`
+	StartAt      time.Time `gorm:"column:start_at" json:"start_at"`
+	EndAt        time.Time `gorm:"column:end_at" json:"end_at"`
+	ValidDays    int       `gorm:"column:valid_days;not null;default:0" json:"valid_days"` // 领取后有效天数，0 表示到 EndAt 失效
+	Status       int       `gorm:"not null;default:1" json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (Coupon) TableName() string {
+	return "sms_coupon"
+}
+
+// UserCoupon 用户领取的优惠券
+type UserCoupon struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	CouponID  int64      `gorm:"column:coupon_id;index;not null" json:"coupon_id"`
+	UserID    int64      `gorm:"column:user_id;index;not null" json:"user_id"`
+	Status    int        `gorm:"not null;default:0" json:"status"`
+	OrderID   int64      `gorm:"column:order_id;not null;default:0" json:"order_id"`
+	ExpireAt  time.Time  `gorm:"column:expire_at" json:"expire_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	Coupon    Coupon     `gorm:"foreignKey:CouponID" json:"coupon"`
+}
+
+func (UserCoupon) TableName() string {
+	return "sms_user_coupon"
+}
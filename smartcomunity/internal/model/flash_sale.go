@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// 秒杀活动状态
+const (
+	FlashSaleStatusActive = 1 // 库存已预热到 Redis，进行中
+	FlashSaleStatusEnded  = 2 // 已结束，剩余库存已归还商品
+)
+
+// FlashSale 秒杀活动，库存从商品库存中划拨并预热到 Redis
+type FlashSale struct {
+	ID            int64      `gorm:"primaryKey" json:"id"`
+	Title         string     `gorm:"type:varchar(128)" json:"title"`
+	ProductID     int64      `gorm:"column:product_id;index;not null" json:"product_id"`
+	StoreID       int64      `gorm:"column:store_id;not null;default:0" json:"store_id"`
+	Price         Money      `gorm:"type:decimal(10,2);not null;default:0.00" json:"price"`
+	Stock         int        `gorm:"not null;default:0" json:"stock"`                                // 划拨的秒杀库存
+	SoldCount     int        `gorm:"column:sold_count;not null;default:0" json:"sold_count"`         // 已落库的订单数量
+	ReturnedStock int        `gorm:"column:returned_stock;not null;default:0" json:"returned_stock"` // 结束时归还商品的库存
+	PerUserLimit  int        `gorm:"column:per_user_limit;not null;default:1" json:"per_user_limit"` // 每人限购，0 不限
+	StoreStock    bool       `gorm:"column:store_stock;not null;default:false" json:"store_stock"`   // 创建时从门店库存划拨(否则为商品总库存)，归还时按此退回
+	StartAt       time.Time  `gorm:"column:start_at" json:"start_at"`
+	EndAt         time.Time  `gorm:"column:end_at" json:"end_at"`
+	Status        int        `gorm:"not null;default:1" json:"status"`
+	EndedAt       *time.Time `gorm:"column:ended_at" json:"ended_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Product       Product    `gorm:"foreignKey:ProductID" json:"product"`
+
+	Remaining *int `gorm:"-" json:"remaining,omitempty"` // Redis 中的剩余库存
+}
+
+func (FlashSale) TableName() string {
+	return "sms_flash_sale"
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+// 垃圾分类照片审核状态
+const (
+	GarbageUploadPending  = 0 // 命中风控，待人工审核，积分暂不发放
+	GarbageUploadApproved = 1 // 已发放积分
+	GarbageUploadRejected = 2 // 审核驳回
+)
+
+// 风控标记
+const (
+	GarbageFlagDuplicate   = "duplicate"    // 与已上传图片相同或近似
+	GarbageFlagStalePhoto  = "stale_photo"  // EXIF 拍摄时间过早
+	GarbageFlagFuturePhoto = "future_photo" // EXIF 拍摄时间晚于上传时间
+	GarbageFlagNoExif      = "no_exif"      // 缺少拍摄时间
+	GarbageFlagUndecodable = "undecodable"  // 无法解析图片，无法查重
+	GarbageFlagDailyCap    = "daily_cap"    // 触达每日积分上限，按上限削减
+)
+
+// GarbageUpload 垃圾分类拍照上传记录，用于查重、风控和人工审核
+type GarbageUpload struct {
+	ID            int64      `gorm:"primaryKey" json:"id"`
+	UserID        int64      `gorm:"column:user_id;index;not null" json:"user_id"`
+	ImageURL      string     `gorm:"column:image_url;type:varchar(512)" json:"image_url"`
+	ObjectKey     string     `gorm:"column:object_key;type:varchar(255)" json:"object_key"`
+	SHA256        string     `gorm:"column:sha256;type:char(64);index" json:"-"`
+	ImageHash     *uint64    `gorm:"column:image_hash" json:"-"` // 感知哈希，无法解析的图片为空
+	DuplicateOf   int64      `gorm:"column:duplicate_of;not null;default:0" json:"duplicate_of"`
+	TakenAt       *time.Time `gorm:"column:taken_at" json:"taken_at"`
+	Flags         string     `gorm:"type:varchar(255)" json:"flags"`                                 // 逗号分隔的风控标记
+	SuggestPoints int        `gorm:"column:suggest_points;not null;default:0" json:"suggest_points"` // 识别模型给出的积分
+	AwardPoints   int        `gorm:"column:award_points;not null;default:0" json:"award_points"`     // 实际发放的积分
+	Reason        string     `gorm:"type:varchar(255)" json:"reason"`
+	Status        int        `gorm:"index;not null;default:0" json:"status"`
+	ReviewerID    int64      `gorm:"column:reviewer_id;not null;default:0" json:"reviewer_id"`
+	ReviewNote    string     `gorm:"column:review_note;type:varchar(255)" json:"review_note"`
+	ReviewedAt    *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+
+	SysUser *SysUser `gorm:"foreignKey:UserID" json:"sys_user,omitempty"`
+}
+
+func (GarbageUpload) TableName() string {
+	return "green_garbage_upload"
+}
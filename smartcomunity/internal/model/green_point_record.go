@@ -13,3 +13,19 @@ type GreenPointRecord struct {
 func (GreenPointRecord) TableName() string {
 	return "green_point_record"
 }
+
+// GreenPointBatch 一笔获得的积分及其有效期，消费时按过期时间先到先用
+type GreenPointBatch struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	UserID    int64     `gorm:"index:idx_point_batch_user;not null" json:"user_id"`
+	Source    string    `gorm:"size:64;not null" json:"source"` // 来源，与 GreenPointRecord.Action 一致；legacy 为启用有效期前的存量积分
+	SourceID  int64     `gorm:"not null;default:0" json:"source_id"`
+	Points    int       `gorm:"not null" json:"points"`
+	Remaining int       `gorm:"index:idx_point_batch_user;not null" json:"remaining"`
+	ExpireAt  time.Time `gorm:"index" json:"expire_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (GreenPointBatch) TableName() string {
+	return "green_point_batch"
+}
@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// 团购状态
+const (
+	GroupBuyStatusOpen    = 0 // 拼团中
+	GroupBuyStatusSuccess = 1 // 已成团
+	GroupBuyStatusFailed  = 2 // 到期未成团，已退款
+)
+
+// 团员状态
+const (
+	GroupMemberPending   = 0 // 已参团待支付
+	GroupMemberPaid      = 1 // 已支付
+	GroupMemberRefunded  = 2 // 未成团已退款
+	GroupMemberCancelled = 3 // 未支付已取消
+)
+
+// GroupBuy 社区团购，团长发起，达到目标人数前到期则全部退款
+type GroupBuy struct {
+	ID          int64            `gorm:"primaryKey" json:"id"`
+	GroupNo     string           `gorm:"column:group_no;type:varchar(64);uniqueIndex" json:"group_no"`
+	LeaderID    int64            `gorm:"column:leader_id;index;not null" json:"leader_id"`
+	ProductID   int64            `gorm:"column:product_id;index;not null" json:"product_id"`
+	StoreID     int64            `gorm:"column:store_id;not null;default:0" json:"store_id"`
+	Price       Money            `gorm:"type:decimal(10,2);not null;default:0.00" json:"price"`
+	TargetCount int              `gorm:"column:target_count;not null" json:"target_count"` // 成团人数
+	PaidCount   int              `gorm:"column:paid_count;not null;default:0" json:"paid_count"`
+	Deadline    time.Time        `gorm:"column:deadline;index" json:"deadline"`
+	Status      int              `gorm:"not null;default:0" json:"status"`
+	FinishedAt  *time.Time       `gorm:"column:finished_at" json:"finished_at"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Product     Product          `gorm:"foreignKey:ProductID" json:"product"`
+	Members     []GroupBuyMember `gorm:"foreignKey:GroupBuyID" json:"members,omitempty"`
+}
+
+func (GroupBuy) TableName() string {
+	return "oms_group_buy"
+}
+
+// GroupBuyMember 参团记录，每次参团对应一笔订单
+type GroupBuyMember struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	GroupBuyID int64      `gorm:"column:group_buy_id;index;not null" json:"group_buy_id"`
+	UserID     int64      `gorm:"column:user_id;index;not null" json:"user_id"`
+	OrderID    int64      `gorm:"column:order_id;index;not null" json:"order_id"`
+	Quantity   int        `gorm:"not null;default:1" json:"quantity"`
+	IsLeader   bool       `gorm:"column:is_leader;not null;default:false" json:"is_leader"`
+	Status     int        `gorm:"not null;default:0" json:"status"`
+	PaidAt     *time.Time `gorm:"column:paid_at" json:"paid_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	SysUser    SysUser    `gorm:"foreignKey:UserID" json:"sys_user"`
+}
+
+func (GroupBuyMember) TableName() string {
+	return "oms_group_buy_member"
+}
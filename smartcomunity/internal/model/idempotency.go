@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 幂等记录状态
+const (
+	IdempotencyStatusProcessing = 0 // 处理中
+	IdempotencyStatusCompleted  = 1 // 已完成，重复请求直接回放响应
+)
+
+// IdempotencyRecord 幂等键记录，同一用户同一接口下 Key 唯一
+type IdempotencyRecord struct {
+	ID           int64     `gorm:"primaryKey" json:"id"`
+	UserID       int64     `gorm:"column:user_id;uniqueIndex:uk_idempotency_key;not null" json:"user_id"`
+	Scope        string    `gorm:"type:varchar(128);uniqueIndex:uk_idempotency_key;not null" json:"scope"`
+	IdemKey      string    `gorm:"column:idem_key;type:varchar(128);uniqueIndex:uk_idempotency_key;not null" json:"idem_key"`
+	RequestHash  string    `gorm:"column:request_hash;type:varchar(64)" json:"request_hash"`
+	Status       int       `gorm:"not null;default:0" json:"status"`
+	HTTPStatus   int       `gorm:"column:http_status;not null;default:0" json:"http_status"`
+	ContentType  string    `gorm:"column:content_type;type:varchar(128)" json:"content_type"`
+	ResponseBody string    `gorm:"column:response_body;type:mediumtext" json:"response_body"`
+	ExpiresAt    time.Time `gorm:"column:expires_at;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "sys_idempotency_record"
+}
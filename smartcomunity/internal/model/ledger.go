@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+// 账户类型
+const (
+	LedgerAccountUserWallet      = "user_wallet"      // 用户余额，OwnerID 为用户ID
+	LedgerAccountUserPoints      = "user_points"      // 用户绿色积分，OwnerID 为用户ID
+	LedgerAccountPlatformRevenue = "platform_revenue" // 平台收入(自营订单、佣金，承担积分抵扣)
+	LedgerAccountPropertyIncome  = "property_income"  // 物业费收入
+	LedgerAccountStorePayable    = "store_payable"    // 应付门店，OwnerID 为门店ID
+	LedgerAccountCashClearing    = "cash_clearing"    // 外部资金清算(充值、打款、人工调账)
+	LedgerAccountPointsPool      = "points_pool"      // 积分发行与回收
+	LedgerAccountTransferPending = "transfer_pending" // 撤销期内尚未到账的转账
+)
+
+// 记账单位
+const (
+	LedgerUnitCNY   = "CNY"   // 金额，以分为单位
+	LedgerUnitPoint = "POINT" // 积分
+)
+
+// LedgerAccount 账户，Balance 为全部分录金额之和，用户账户同步到 sys_user 的余额与积分
+type LedgerAccount struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"code"`
+	Type      string    `gorm:"type:varchar(32);index:idx_ledger_account_owner;not null" json:"type"`
+	OwnerID   int64     `gorm:"column:owner_id;index:idx_ledger_account_owner;not null;default:0" json:"owner_id"`
+	Unit      string    `gorm:"type:varchar(8);not null" json:"unit"`
+	Balance   int64     `gorm:"not null;default:0" json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (LedgerAccount) TableName() string {
+	return "sys_ledger_account"
+}
+
+// LedgerEntry 记账凭证，同一记账单位下所有分录金额之和必须为 0
+type LedgerEntry struct {
+	ID        int64        `gorm:"primaryKey" json:"id"`
+	EntryNo   string       `gorm:"column:entry_no;type:varchar(64);uniqueIndex" json:"entry_no"`
+	BizType   string       `gorm:"column:biz_type;type:varchar(32);index:idx_ledger_entry_biz;not null" json:"biz_type"`
+	BizID     int64        `gorm:"column:biz_id;index:idx_ledger_entry_biz;not null;default:0" json:"biz_id"`
+	Remark    string       `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt time.Time    `gorm:"index" json:"created_at"`
+	Lines     []LedgerLine `gorm:"foreignKey:EntryID" json:"lines,omitempty"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "sys_ledger_entry"
+}
+
+// LedgerLine 分录，Amount 为账户余额的变动(分或积分)，正数增加、负数减少
+type LedgerLine struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	EntryID   int64     `gorm:"column:entry_id;index;not null" json:"entry_id"`
+	AccountID int64     `gorm:"column:account_id;index;not null" json:"account_id"`
+	Unit      string    `gorm:"type:varchar(8);not null" json:"unit"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (LedgerLine) TableName() string {
+	return "sys_ledger_line"
+}
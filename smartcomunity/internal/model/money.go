@@ -0,0 +1,179 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrSubCentAmount 金额精度超过分
+var ErrSubCentAmount = errors.New("金额最多保留两位小数")
+
+// Money 金额，内部以分为单位的整数保存，避免浮点误差。
+// 数据库列保持 decimal(x,2)，读写时按字符串精确转换，已有数据无需迁移；
+// JSON 仍输出为两位小数的数字(如 12.30)，前端协议不变。
+type Money int64
+
+// Yuan 由元构造金额，四舍五入到分，仅用于常量或比例计算的结果
+func Yuan(amount float64) Money {
+	return Money(math.Round(amount * 100))
+}
+
+// ParseMoney 精确解析十进制金额字符串，拒绝超过两位小数的输入
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("金额不能为空")
+	}
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasFrac := strings.Cut(s, ".")
+	// 只接受数字，避免 ParseInt 接受的 "--5"、"1.+5" 之类被误读
+	if (intPart == "" && fracPart == "") || !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("金额格式错误: %s", s)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if hasFrac {
+		// 数据库 decimal 列可能带有多余的 0，如 decimal(12,4)
+		trimmed := strings.TrimRight(fracPart, "0")
+		if len(trimmed) > 2 {
+			return 0, ErrSubCentAmount
+		}
+		fracPart = trimmed
+	}
+	for len(fracPart) < 2 {
+		fracPart += "0"
+	}
+
+	yuan, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("金额格式错误: %s", s)
+	}
+	cents, err := strconv.ParseInt(fracPart, 10, 64)
+	if err != nil || cents < 0 {
+		return 0, fmt.Errorf("金额格式错误: %s", s)
+	}
+	if yuan > math.MaxInt64/100-1 {
+		return 0, errors.New("金额超出范围")
+	}
+
+	m := Money(yuan*100 + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cents 以分为单位的整数值
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Float 转为元，仅用于展示或统计，不得再参与金额计算
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+// MulRate 按比例计算(折扣、佣金等)，四舍五入到分
+func (m Money) MulRate(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON 兼容数字与字符串两种写法，超过两位小数时报错
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		return nil
+	}
+	s = strings.Trim(s, `"`)
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("金额格式错误: %s", s)
+		}
+		s = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value 以十进制字符串写入 decimal 列，由数据库精确转换
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		if v > math.MaxInt64/100 || v < math.MinInt64/100 {
+			return errors.New("金额超出范围")
+		}
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		*m = Yuan(v)
+		return nil
+	}
+	return fmt.Errorf("unsupported money value %T", src)
+}
+
+// GormDataType 未显式声明 type 的金额字段也按 decimal 建列
+func (Money) GormDataType() string {
+	return "decimal(12,2)"
+}
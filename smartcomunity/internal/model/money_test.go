@@ -0,0 +1,175 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error // 需要特定错误时设置，只要求报错时用 anyErr
+		anyErr  bool
+	}{
+		{in: "0", want: 0},
+		{in: "12", want: 1200},
+		{in: "12.3", want: 1230},
+		{in: "12.30", want: 1230},
+		{in: "12.300", want: 1230},
+		{in: "1.2300", want: 123},
+		{in: " 3.10 ", want: 310},
+		{in: ".5", want: 50},
+		{in: "5.", want: 500},
+		{in: "+1.20", want: 120},
+		{in: "-1.20", want: -120},
+		{in: "-.5", want: -50},
+		{in: "-0", want: 0},
+		{in: "0.01", want: 1},
+		{in: "92233720368547757.99", want: 9223372036854775799},
+
+		{in: "1.005", wantErr: ErrSubCentAmount},
+		{in: "0.001", wantErr: ErrSubCentAmount},
+		{in: "-0.129", wantErr: ErrSubCentAmount},
+
+		{in: "", anyErr: true},
+		{in: "-", anyErr: true},
+		{in: ".", anyErr: true},
+		{in: "--5", anyErr: true},
+		{in: "+-5", anyErr: true},
+		{in: "1.+5", anyErr: true},
+		{in: "1.-5", anyErr: true},
+		{in: "1,000", anyErr: true},
+		{in: "abc", anyErr: true},
+		{in: "1e2", anyErr: true},
+		{in: "1.5E1", anyErr: true},
+		{in: "92233720368547758", anyErr: true},
+		{in: "99999999999999999999", anyErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		switch {
+		case tt.wantErr != nil:
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseMoney(%q) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+		case tt.anyErr:
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %d, want error", tt.in, got)
+			}
+		default:
+			if err != nil {
+				t.Errorf("ParseMoney(%q) unexpected error: %v", tt.in, err)
+			} else if got != tt.want {
+				t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+		anyErr  bool
+	}{
+		{in: `12.3`, want: 1230},
+		{in: `"12.30"`, want: 1230},
+		{in: `-0.5`, want: -50},
+		{in: `1.5e1`, want: 1500},
+		{in: `"2E2"`, want: 20000},
+		{in: `1e-2`, want: 1},
+		{in: `1e-3`, wantErr: ErrSubCentAmount},
+		{in: `1.005`, wantErr: ErrSubCentAmount},
+		{in: `1e300`, anyErr: true},
+		{in: `"abc"`, anyErr: true},
+		{in: `""`, anyErr: true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := m.UnmarshalJSON([]byte(tt.in))
+		switch {
+		case tt.wantErr != nil:
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UnmarshalJSON(%s) error = %v, want %v", tt.in, err, tt.wantErr)
+			}
+		case tt.anyErr:
+			if err == nil {
+				t.Errorf("UnmarshalJSON(%s) = %d, want error", tt.in, m)
+			}
+		default:
+			if err != nil {
+				t.Errorf("UnmarshalJSON(%s) unexpected error: %v", tt.in, err)
+			} else if m != tt.want {
+				t.Errorf("UnmarshalJSON(%s) = %d, want %d", tt.in, m, tt.want)
+			}
+		}
+	}
+
+	m := Money(123)
+	if err := m.UnmarshalJSON([]byte("null")); err != nil || m != 123 {
+		t.Errorf("UnmarshalJSON(null) = %d, %v; want value unchanged", m, err)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    Money
+		wantErr bool
+	}{
+		{name: "nil", src: nil, want: 0},
+		{name: "bytes", src: []byte("12.30"), want: 1230},
+		{name: "bytes with extra scale", src: []byte("1.0000"), want: 100},
+		{name: "bytes negative", src: []byte("-0.05"), want: -5},
+		{name: "bytes sub-cent", src: []byte("0.001"), wantErr: true},
+		{name: "bytes invalid", src: []byte("x"), wantErr: true},
+		{name: "string", src: "8.8", want: 880},
+		{name: "int64", src: int64(12), want: 1200},
+		{name: "int64 negative", src: int64(-3), want: -300},
+		{name: "int64 overflow", src: int64(math.MaxInt64 / 10), wantErr: true},
+		{name: "float64", src: 12.3, want: 1230},
+		{name: "float64 rounding", src: 0.1 + 0.2, want: 30},
+		{name: "unsupported", src: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		var m Money
+		err := m.Scan(tt.src)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Scan(%v) = %d, want error", tt.name, tt.src, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Scan(%v) unexpected error: %v", tt.name, tt.src, err)
+		} else if m != tt.want {
+			t.Errorf("%s: Scan(%v) = %d, want %d", tt.name, tt.src, m, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{1230, "12.30"},
+		{-120, "-1.20"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+		parsed, err := ParseMoney(tt.in.String())
+		if err != nil || parsed != tt.in {
+			t.Errorf("ParseMoney(%q) = %d, %v; want round trip to %d", tt.in.String(), parsed, err, tt.in)
+		}
+	}
+}
@@ -26,3 +26,19 @@ type NoticeRead struct {
 func (NoticeRead) TableName() string {
 	return "cms_notice_read"
 }
+
+// UserNotice 站内信，发给指定用户的业务提醒
+type UserNotice struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	UserID    int64     `gorm:"column:user_id;index;not null" json:"user_id"`
+	Title     string    `gorm:"type:varchar(128)" json:"title"`
+	Content   string    `gorm:"type:text" json:"content"`
+	BizType   string    `gorm:"column:biz_type;type:varchar(32)" json:"biz_type"` // 如 order_pickup
+	BizID     int64     `gorm:"column:biz_id;not null;default:0" json:"biz_id"`
+	IsRead    bool      `gorm:"column:is_read;not null;default:false" json:"is_read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (UserNotice) TableName() string {
+	return "cms_user_notice"
+}
@@ -2,20 +2,51 @@ package model
 
 import "time"
 
+// 订单状态
+const (
+	OrderStatusPending   = 0  // 待支付
+	OrderStatusPaid      = 1  // 已支付
+	OrderStatusShipped   = 2  // 已发货
+	OrderStatusReceived  = 3  // 已收货
+	OrderStatusCancelled = 40 // 已取消
+	OrderStatusRefunding = 50 // 售后处理中
+	OrderStatusRefunded  = 60 // 已全额退款
+	OrderStatusClosed    = 70 // 交易关闭(售后期结束)
+)
+
+// 配送方式
+const (
+	DeliveryTypeExpress = 1 // 配送上门
+	DeliveryTypePickup  = 2 // 到店自提
+)
+
 type Order struct {
-	ID          int64       `gorm:"primaryKey" json:"id"`
-	OrderNo     string      `gorm:"column:order_no;type:varchar(64)" json:"order_no"`
-	UserID      int64       `json:"user_id"`
-	StoreID     int64       `json:"store_id"`
-	TotalAmount float64     `gorm:"type:decimal(10,2);not null;default:0.00" json:"total_amount"`
-	UsedPoints  int         `gorm:"column:used_points;not null;default:0" json:"used_points"`
-	UsedBalance float64     `gorm:"column:used_balance;type:decimal(10,2);not null;default:0.00" json:"used_balance"`
-	Status      int         `json:"status"`
-	PaidAt      *time.Time  `gorm:"column:paid_at" json:"paid_at"`
-	CreatedAt   time.Time   `json:"created_at"`
-	Items       []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
-	Store       Store       `gorm:"foreignKey:StoreID" json:"store"`
-	SysUser     SysUser     `gorm:"foreignKey:UserID" json:"sys_user"`
+	ID              int64       `gorm:"primaryKey" json:"id"`
+	OrderNo         string      `gorm:"column:order_no;type:varchar(64)" json:"order_no"`
+	UserID          int64       `json:"user_id"`
+	StoreID         int64       `json:"store_id"`
+	TotalAmount     Money       `gorm:"type:decimal(10,2);not null;default:0.00" json:"total_amount"` // 优惠后应付金额
+	DiscountAmount  Money       `gorm:"column:discount_amount;type:decimal(10,2);not null;default:0.00" json:"discount_amount"`
+	UserCouponID    int64       `gorm:"column:user_coupon_id;not null;default:0" json:"user_coupon_id"`
+	FlashSaleID     int64       `gorm:"column:flash_sale_id;not null;default:0" json:"flash_sale_id"`
+	GroupBuyID      int64       `gorm:"column:group_buy_id;not null;default:0" json:"group_buy_id"`
+	UsedPoints      int         `gorm:"column:used_points;not null;default:0" json:"used_points"`
+	UsedBalance     Money       `gorm:"column:used_balance;type:decimal(10,2);not null;default:0.00" json:"used_balance"`
+	RefundedPoints  int         `gorm:"column:refunded_points;not null;default:0" json:"refunded_points"`
+	RefundedBalance Money       `gorm:"column:refunded_balance;type:decimal(10,2);not null;default:0.00" json:"refunded_balance"`
+	Status          int         `json:"status"`
+	DeliveryType    int         `gorm:"column:delivery_type;not null;default:1" json:"delivery_type"`
+	PickupCode      string      `gorm:"column:pickup_code;type:varchar(16);index" json:"pickup_code,omitempty"` // 支付后生成的自提码
+	PickupDeadline  *time.Time  `gorm:"column:pickup_deadline" json:"pickup_deadline,omitempty"`
+	PickupReminded  bool        `gorm:"column:pickup_reminded;not null;default:false" json:"-"`
+	PickedUpAt      *time.Time  `gorm:"column:picked_up_at" json:"picked_up_at,omitempty"`
+	PaidAt          *time.Time  `gorm:"column:paid_at" json:"paid_at"`
+	CreatedAt       time.Time   `json:"created_at"`
+	Items           []OrderItem `gorm:"foreignKey:OrderID" json:"items"`
+	Store           Store       `gorm:"foreignKey:StoreID" json:"store"`
+	SysUser         SysUser     `gorm:"foreignKey:UserID" json:"sys_user"`
+
+	StatusLogs []OrderStatusLog `gorm:"foreignKey:OrderID" json:"status_logs,omitempty"`
 }
 
 func (Order) TableName() string {
@@ -23,14 +54,35 @@ func (Order) TableName() string {
 }
 
 type OrderItem struct {
-	ID        int64   `gorm:"primaryKey" json:"id"`
-	OrderID   int64   `json:"order_id"`
-	ProductID int64   `json:"product_id"`
-	Price     float64 `json:"price"`
-	Quantity  int     `json:"quantity"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"product"`
+	ID             int64   `gorm:"primaryKey" json:"id"`
+	OrderID        int64   `json:"order_id"`
+	ProductID      int64   `json:"product_id"`
+	Price          Money   `json:"price"`
+	Quantity       int     `json:"quantity"`
+	Amount         Money   `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"` // 活动价计算后的小计
+	PromotionID    int64   `gorm:"column:promotion_id;not null;default:0" json:"promotion_id"`
+	PromotionTitle string  `gorm:"column:promotion_title;type:varchar(128)" json:"promotion_title"`
+	RefundedQty    int     `gorm:"column:refunded_qty;not null;default:0" json:"refunded_qty"`   // 已售后退款的数量
+	StoreStock     bool    `gorm:"column:store_stock;not null;default:false" json:"store_stock"` // 下单时占用的是门店库存(否则为商品总库存)，归还时按此退回
+	Product        Product `gorm:"foreignKey:ProductID" json:"product"`
 }
 
 func (OrderItem) TableName() string {
 	return "oms_order_item"
 }
+
+// OrderStatusLog 订单状态流转记录
+type OrderStatusLog struct {
+	ID           int64     `gorm:"primaryKey" json:"id"`
+	OrderID      int64     `gorm:"column:order_id;index;not null" json:"order_id"`
+	FromStatus   int       `gorm:"column:from_status;not null" json:"from_status"`
+	ToStatus     int       `gorm:"column:to_status;not null" json:"to_status"`
+	OperatorID   int64     `gorm:"column:operator_id;not null;default:0" json:"operator_id"`
+	OperatorRole string    `gorm:"column:operator_role;type:varchar(32)" json:"operator_role"`
+	Reason       string    `gorm:"type:varchar(255)" json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OrderStatusLog) TableName() string {
+	return "oms_order_status_log"
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// 支付单状态
+const (
+	ChargeStatusPending   = 0 // 待支付
+	ChargeStatusSucceeded = 1 // 已支付并入账
+	ChargeStatusFailed    = 2 // 支付失败
+	ChargeStatusClosed    = 3 // 超时关闭
+	ChargeStatusRefunded  = 4 // 已退款
+	ChargeStatusRefunding = 5 // 退款中：已从余额扣回，等待渠道退款完成
+)
+
+// 支付单业务类型
+const (
+	ChargeBizRecharge = "recharge" // 余额充值
+)
+
+// PaymentCharge 向第三方渠道发起的支付单，只有收到验签通过的回调后才入账
+type PaymentCharge struct {
+	ID              int64      `gorm:"primaryKey" json:"id"`
+	ChargeNo        string     `gorm:"column:charge_no;type:varchar(64);uniqueIndex" json:"charge_no"`
+	UserID          int64      `gorm:"column:user_id;index;not null" json:"user_id"`
+	BizType         string     `gorm:"column:biz_type;type:varchar(32);not null" json:"biz_type"`
+	Amount          Money      `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`
+	Provider        string     `gorm:"type:varchar(32);not null" json:"provider"`
+	ProviderTradeNo string     `gorm:"column:provider_trade_no;type:varchar(64);index" json:"provider_trade_no"`
+	PayURL          string     `gorm:"column:pay_url;type:varchar(512)" json:"pay_url"`
+	Status          int        `gorm:"not null;default:0;index" json:"status"`
+	FailReason      string     `gorm:"column:fail_reason;type:varchar(255)" json:"fail_reason"`
+	NotifyPayload   string     `gorm:"column:notify_payload;type:text" json:"-"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at" json:"expires_at"`
+	PaidAt          *time.Time `gorm:"column:paid_at" json:"paid_at"`
+	RefundNo        string     `gorm:"column:refund_no;type:varchar(64)" json:"refund_no"` // 渠道退款单号，重试时沿用保证幂等
+	RefundedAt      *time.Time `gorm:"column:refunded_at" json:"refunded_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (PaymentCharge) TableName() string {
+	return "sys_payment_charge"
+}
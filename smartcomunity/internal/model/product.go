@@ -7,8 +7,8 @@ type Product struct {
 	CategoryName  string    `gorm:"column:category_name;type:varchar(64)" json:"category_name"`
 	Name          string    `gorm:"type:varchar(128)" json:"name"`
 	Description   string    `gorm:"type:text" json:"description"`
-	Price         float64   `gorm:"type:decimal(10,2);not null;default:0.00" json:"price"`
-	OriginalPrice float64   `gorm:"type:decimal(10,2);not null;default:0.00" json:"original_price"`
+	Price         Money     `gorm:"type:decimal(10,2);not null;default:0.00" json:"price"`
+	OriginalPrice Money     `gorm:"type:decimal(10,2);not null;default:0.00" json:"original_price"`
 	Stock         int       `json:"stock"`
 	ImageURL      string    `gorm:"column:image_url;type:varchar(255)" json:"image_url"`
 	IsPromotion   int       `json:"is_promotion"`
@@ -16,6 +16,10 @@ type Product struct {
 	Status        int       `json:"status"`
 	CreatedAt     time.Time `json:"created_at"`
 	CategoryID    int64     `json:"category_id"`
+	StoreID       int64     `gorm:"column:store_id;not null;default:0;index" json:"store_id"` // 所属门店，0 为平台商品
+
+	StoreStock *int        `gorm:"-" json:"store_stock,omitempty"` // 按门店查询时的门店库存
+	Promotions []Promotion `gorm:"-" json:"promotions,omitempty"`  // 当前生效的活动
 }
 
 func (Product) TableName() string {
@@ -33,14 +37,35 @@ func (ProductCategory) TableName() string {
 	return "pms_product_category"
 }
 
+// 活动类型
+const (
+	PromotionTypeFlashSale = 1 // 限时特价：活动期间按 PromoPrice 计价
+	PromotionTypeMarkdown  = 2 // 商品降价(原价>现价)，仅用于展示
+	PromotionTypeBuyNGetM  = 3 // 买 N 送 M
+	PromotionTypeBundle    = 4 // N 件一口价
+	PromotionTypeTiered    = 5 // 阶梯折扣，按 Tiers 配置
+)
+
 type Promotion struct {
-	ID        int64     `gorm:"primaryKey" json:"id"`
-	Title     string    `gorm:"type:varchar(128)" json:"title"`
-	Type      int       `json:"type"`
-	StartDate time.Time `json:"start_date"`
-	EndDate   time.Time `json:"end_date"`
-	Status    int       `json:"status"`
-	ProductID int64     `json:"product_id"`
+	ID             int64     `gorm:"primaryKey" json:"id"`
+	Title          string    `gorm:"type:varchar(128)" json:"title"`
+	Type           int       `json:"type"`
+	StartDate      time.Time `json:"start_date"`
+	EndDate        time.Time `json:"end_date"`
+	Status         int       `json:"status"`
+	ProductID      int64     `json:"product_id"`
+	PromoPrice     Money     `gorm:"column:promo_price;type:decimal(10,2);not null;default:0.00" json:"promo_price"`
+	BuyQuantity    int       `gorm:"column:buy_quantity;not null;default:0" json:"buy_quantity"`
+	FreeQuantity   int       `gorm:"column:free_quantity;not null;default:0" json:"free_quantity"`
+	BundleQuantity int       `gorm:"column:bundle_quantity;not null;default:0" json:"bundle_quantity"`
+	BundlePrice    Money     `gorm:"column:bundle_price;type:decimal(10,2);not null;default:0.00" json:"bundle_price"`
+	Tiers          string    `gorm:"type:varchar(512)" json:"tiers"` // JSON: [{"min_qty":2,"discount":0.9}]
+}
+
+// PromotionTier 阶梯折扣的一档
+type PromotionTier struct {
+	MinQty   int     `json:"min_qty"`
+	Discount float64 `json:"discount"`
 }
 
 func (Promotion) TableName() string {
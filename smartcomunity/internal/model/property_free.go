@@ -3,16 +3,85 @@ package model
 import "time"
 
 type PropertyFee struct {
-	ID          int64      `gorm:"primaryKey" json:"id"`
-	UserID      int64      `json:"user_id"`
-	Month       string     `gorm:"type:varchar(20)" json:"month"`
-	Amount      float64    `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`
-	UsedPoints  int        `gorm:"column:used_points;not null;default:0" json:"used_points"`
-	UsedBalance float64    `gorm:"column:used_balance;type:decimal(10,2);not null;default:0.00" json:"used_balance"`
-	Status      int        `json:"status"`
-	PayTime     *time.Time `json:"pay_time"`
+	ID           int64      `gorm:"primaryKey" json:"id"`
+	UserID       int64      `json:"user_id"`
+	HouseholdID  int64      `gorm:"column:household_id;index;not null;default:0" json:"household_id"` // 0 表示手工录入的历史账单
+	BillingRunID int64      `gorm:"column:billing_run_id;index;not null;default:0" json:"billing_run_id"`
+	Month        string     `gorm:"type:varchar(20)" json:"month"`
+	Amount       Money      `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`
+	Detail       string     `gorm:"type:varchar(512)" json:"detail"` // 计费明细，如 "物业管理费 2.50/㎡ x 89.00㎡ = 222.50"
+	DueDate      *time.Time `gorm:"column:due_date;index" json:"due_date"`
+	LateFee      Money      `gorm:"column:late_fee;type:decimal(10,2);not null;default:0.00" json:"late_fee"` // 缴费时实收的滞纳金
+	UsedPoints   int        `gorm:"column:used_points;not null;default:0" json:"used_points"`
+	UsedBalance  Money      `gorm:"column:used_balance;type:decimal(10,2);not null;default:0.00" json:"used_balance"`
+	Status       int        `json:"status"`
+	PayTime      *time.Time `json:"pay_time"`
+	RemindStage  int        `gorm:"column:remind_stage;not null;default:0" json:"remind_stage"` // 已发送的催缴次数
+	RemindedAt   *time.Time `gorm:"column:reminded_at" json:"reminded_at"`
+
+	// 以下按当前时间计算，不落库
+	OverdueDays    int   `gorm:"-" json:"overdue_days"`
+	AccruedLateFee Money `gorm:"-" json:"accrued_late_fee"`
+	PayableAmount  Money `gorm:"-" json:"payable_amount"`
 }
 
 func (PropertyFee) TableName() string {
 	return "cms_property_fee"
 }
+
+// Household 房屋，物业费按房屋出账，由 UserID 对应的业主缴纳
+type Household struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Building  string    `gorm:"type:varchar(32);uniqueIndex:uk_household_room;not null" json:"building"` // 楼栋，如 "3号楼"
+	Unit      string    `gorm:"type:varchar(16);uniqueIndex:uk_household_room;not null" json:"unit"`
+	RoomNo    string    `gorm:"column:room_no;type:varchar(16);uniqueIndex:uk_household_room;not null" json:"room_no"`
+	Area      float64   `gorm:"type:decimal(8,2);not null;default:0.00" json:"area"`    // 建筑面积，平方米
+	UserID    int64     `gorm:"column:user_id;index;not null;default:0" json:"user_id"` // 0 表示未绑定业主
+	Status    int       `gorm:"not null;default:1" json:"status"`                       // 1 正常出账 0 停止出账
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	SysUser   SysUser   `gorm:"foreignKey:UserID" json:"sys_user,omitempty"`
+}
+
+func (Household) TableName() string {
+	return "cms_household"
+}
+
+// 收费标准计费方式
+const (
+	FeeRateTypePerSqm = 1 // 按建筑面积，UnitPrice 为每平方米单价
+	FeeRateTypeFixed  = 2 // 每户固定金额
+)
+
+// PropertyFeeRate 物业收费标准；同名标准中指定楼栋的优先于全小区通用的(Building 为空)
+type PropertyFeeRate struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(64);not null" json:"name"`
+	Type      int       `gorm:"not null" json:"type"`
+	UnitPrice Money     `gorm:"column:unit_price;type:decimal(10,2);not null;default:0.00" json:"unit_price"`
+	Building  string    `gorm:"type:varchar(32);index" json:"building"`
+	Status    int       `gorm:"not null;default:1" json:"status"` // 1 启用 0 停用
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PropertyFeeRate) TableName() string {
+	return "cms_property_fee_rate"
+}
+
+// PropertyBillingRun 批量出账记录
+type PropertyBillingRun struct {
+	ID             int64     `gorm:"primaryKey" json:"id"`
+	Month          string    `gorm:"type:varchar(20);index" json:"month"`
+	OperatorID     int64     `gorm:"column:operator_id;not null;default:0" json:"operator_id"`
+	HouseholdCount int       `gorm:"column:household_count;not null;default:0" json:"household_count"`
+	CreatedCount   int       `gorm:"column:created_count;not null;default:0" json:"created_count"`
+	SkippedCount   int       `gorm:"column:skipped_count;not null;default:0" json:"skipped_count"`
+	TotalAmount    Money     `gorm:"column:total_amount;type:decimal(12,2);not null;default:0.00" json:"total_amount"`
+	Summary        string    `gorm:"type:text" json:"summary"` // 按楼栋汇总的 JSON
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (PropertyBillingRun) TableName() string {
+	return "cms_property_billing_run"
+}
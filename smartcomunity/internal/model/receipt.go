@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// 电子收据状态
+const (
+	ReceiptStatusValid = 1 // 有效
+	ReceiptStatusVoid  = 2 // 已作废
+)
+
+// 收据对应的业务类型
+const (
+	ReceiptBizOrder       = "order"
+	ReceiptBizPropertyFee = "property_fee"
+)
+
+// Receipt 支付成功后开具的电子收据，开具时对付款人和明细做快照，文件存于对象存储
+type Receipt struct {
+	ID           int64         `gorm:"primaryKey" json:"id"`
+	ReceiptNo    string        `gorm:"column:receipt_no;type:varchar(32);uniqueIndex;not null" json:"receipt_no"`
+	UserID       int64         `gorm:"column:user_id;index;not null" json:"user_id"`
+	BizType      string        `gorm:"column:biz_type;type:varchar(32);index:idx_receipt_biz;not null" json:"biz_type"`
+	BizID        int64         `gorm:"column:biz_id;index:idx_receipt_biz;not null" json:"biz_id"`
+	Title        string        `gorm:"type:varchar(128)" json:"title"` // 如 "物业费 2026-10"、"商城订单 xxx"
+	PayerName    string        `gorm:"column:payer_name;type:varchar(64)" json:"payer_name"`
+	PayerMobile  string        `gorm:"column:payer_mobile;type:varchar(32)" json:"payer_mobile"`
+	Items        string        `gorm:"type:text" json:"-"`
+	ItemList     []ReceiptItem `gorm:"-" json:"items"`
+	TotalAmount  Money         `gorm:"column:total_amount;type:decimal(12,2);not null;default:0.00" json:"total_amount"`
+	LateFee      Money         `gorm:"column:late_fee;type:decimal(12,2);not null;default:0.00" json:"late_fee"`
+	UsedPoints   int           `gorm:"column:used_points;not null;default:0" json:"used_points"`
+	PointsDeduct Money         `gorm:"column:points_deduct;type:decimal(12,2);not null;default:0.00" json:"points_deduct"` // 积分抵扣的金额
+	UsedBalance  Money         `gorm:"column:used_balance;type:decimal(12,2);not null;default:0.00" json:"used_balance"`
+	PaidAt       time.Time     `gorm:"column:paid_at" json:"paid_at"`
+	HTMLKey      string        `gorm:"column:html_key;type:varchar(255)" json:"-"`
+	PDFKey       string        `gorm:"column:pdf_key;type:varchar(255)" json:"-"`
+	Status       int           `gorm:"not null;default:1" json:"status"`
+	ReissuedFrom int64         `gorm:"column:reissued_from;not null;default:0" json:"reissued_from"` // 补开时指向被作废的原收据
+	VoidReason   string        `gorm:"column:void_reason;type:varchar(255)" json:"void_reason"`
+	VoidedBy     int64         `gorm:"column:voided_by;not null;default:0" json:"voided_by"`
+	VoidedAt     *time.Time    `gorm:"column:voided_at" json:"voided_at"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+func (Receipt) TableName() string {
+	return "sys_receipt"
+}
+
+// ReceiptItem 收据明细行
+type ReceiptItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Price    Money  `json:"price"`
+	Amount   Money  `json:"amount"`
+}
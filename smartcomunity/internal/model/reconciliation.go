@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// 对账差异字段
+const (
+	DiscrepancyFieldBalance     = "balance"      // 余额，金额单位为分
+	DiscrepancyFieldGreenPoints = "green_points" // 绿色积分
+)
+
+// 差异处理状态
+const (
+	DiscrepancyStatusOpen      = 0 // 待处理
+	DiscrepancyStatusCorrected = 1 // 已冲正
+	DiscrepancyStatusIgnored   = 2 // 已忽略
+)
+
+// WalletReconciliation 钱包对账批次，用流水重算每个用户的余额与积分
+type WalletReconciliation struct {
+	ID            int64               `gorm:"primaryKey" json:"id"`
+	RunNo         string              `gorm:"column:run_no;type:varchar(64);uniqueIndex" json:"run_no"`
+	OperatorID    int64               `gorm:"column:operator_id;not null;default:0" json:"operator_id"` // 0 表示定时任务
+	CheckedUsers  int64               `gorm:"column:checked_users;not null;default:0" json:"checked_users"`
+	MismatchCount int                 `gorm:"column:mismatch_count;not null;default:0" json:"mismatch_count"`
+	StartedAt     time.Time           `gorm:"column:started_at" json:"started_at"`
+	FinishedAt    *time.Time          `gorm:"column:finished_at" json:"finished_at"`
+	Discrepancies []WalletDiscrepancy `gorm:"foreignKey:ReconciliationID" json:"discrepancies,omitempty"`
+}
+
+func (WalletReconciliation) TableName() string {
+	return "sys_wallet_reconciliation"
+}
+
+// WalletDiscrepancy 对账差异，Actual 为用户表当前值，Expected 为流水合计
+type WalletDiscrepancy struct {
+	ID               int64      `gorm:"primaryKey" json:"id"`
+	ReconciliationID int64      `gorm:"column:reconciliation_id;index;not null" json:"reconciliation_id"`
+	UserID           int64      `gorm:"column:user_id;index;not null" json:"user_id"`
+	Field            string     `gorm:"type:varchar(32);not null" json:"field"`
+	Actual           int64      `gorm:"not null" json:"actual"`
+	Expected         int64      `gorm:"not null" json:"expected"`
+	Difference       int64      `gorm:"not null" json:"difference"` // Actual - Expected
+	Status           int        `gorm:"not null;default:0" json:"status"`
+	Resolution       string     `gorm:"type:varchar(32)" json:"resolution"` // balance: 按流水修正余额 history: 补记流水 ignore: 忽略
+	ResolvedBy       int64      `gorm:"column:resolved_by;not null;default:0" json:"resolved_by"`
+	ResolvedAt       *time.Time `gorm:"column:resolved_at" json:"resolved_at"`
+	Remark           string     `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt        time.Time  `json:"created_at"`
+	SysUser          SysUser    `gorm:"foreignKey:UserID" json:"sys_user,omitempty"`
+}
+
+func (WalletDiscrepancy) TableName() string {
+	return "sys_wallet_discrepancy"
+}
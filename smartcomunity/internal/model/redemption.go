@@ -0,0 +1,71 @@
+package model
+
+import "time"
+
+// 兑换商品类型
+const (
+	RedeemItemGoods   = 1 // 实物，到店领取或配送
+	RedeemItemService = 2 // 服务，如家政、维修工时
+)
+
+// 兑换记录状态
+const (
+	RedemptionPending   = 0 // 待兑付
+	RedemptionFulfilled = 1 // 已兑付
+	RedemptionCancelled = 2 // 已取消，积分与库存已退回
+)
+
+// RedeemItem 积分兑换目录中的商品或服务，只能用积分兑换
+type RedeemItem struct {
+	ID           int64      `gorm:"primaryKey" json:"id"`
+	Name         string     `gorm:"type:varchar(128)" json:"name"`
+	Description  string     `gorm:"type:text" json:"description"`
+	ImageURL     string     `gorm:"column:image_url;type:varchar(255)" json:"image_url"`
+	Type         int        `gorm:"not null;default:1" json:"type"`
+	ProductID    int64      `gorm:"column:product_id;not null;default:0" json:"product_id"`         // 关联商城商品，0 表示独立的兑换品
+	StoreID      int64      `gorm:"column:store_id;not null;default:0;index" json:"store_id"`       // 负责兑付的门店，0 为平台/物业
+	Points       int        `gorm:"not null" json:"points"`                                         // 兑换所需积分
+	Stock        int        `gorm:"not null;default:0" json:"stock"`                                // 剩余可兑换数量
+	PerUserLimit int        `gorm:"column:per_user_limit;not null;default:0" json:"per_user_limit"` // 每人累计可兑换数量，0 不限
+	StartAt      *time.Time `gorm:"column:start_at" json:"start_at"`
+	EndAt        *time.Time `gorm:"column:end_at" json:"end_at"`
+	Sort         int        `gorm:"not null;default:0" json:"sort"`
+	Status       int        `gorm:"not null;default:1" json:"status"` // 1 上架 0 下架
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	Product *Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Store   *Store   `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+}
+
+func (RedeemItem) TableName() string {
+	return "sms_redeem_item"
+}
+
+// Redemption 积分兑换记录，同时作为兑付单，兑付时核销 Code
+type Redemption struct {
+	ID          int64      `gorm:"primaryKey" json:"id"`
+	RedeemNo    string     `gorm:"column:redeem_no;type:varchar(64);uniqueIndex;not null" json:"redeem_no"`
+	UserID      int64      `gorm:"column:user_id;index:idx_redemption_user_item;not null" json:"user_id"`
+	ItemID      int64      `gorm:"column:item_id;index:idx_redemption_user_item;not null" json:"item_id"`
+	ItemName    string     `gorm:"column:item_name;type:varchar(128)" json:"item_name"`
+	ItemType    int        `gorm:"column:item_type;not null;default:1" json:"item_type"`
+	StoreID     int64      `gorm:"column:store_id;not null;default:0;index" json:"store_id"`
+	Quantity    int        `gorm:"not null;default:1" json:"quantity"`
+	Points      int        `gorm:"not null" json:"points"` // 本次消耗的积分合计
+	Code        string     `gorm:"type:varchar(16);index" json:"code"`
+	Remark      string     `gorm:"type:varchar(255)" json:"remark"` // 用户留言，如预约时间、收货地址
+	Status      int        `gorm:"not null;default:0" json:"status"`
+	OperatorID  int64      `gorm:"column:operator_id;not null;default:0" json:"operator_id"`
+	CancelNote  string     `gorm:"column:cancel_note;type:varchar(255)" json:"cancel_note"`
+	FulfilledAt *time.Time `gorm:"column:fulfilled_at" json:"fulfilled_at"`
+	CancelledAt *time.Time `gorm:"column:cancelled_at" json:"cancelled_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	Item    *RedeemItem `gorm:"foreignKey:ItemID" json:"item,omitempty"`
+	SysUser *SysUser    `gorm:"foreignKey:UserID" json:"sys_user,omitempty"`
+}
+
+func (Redemption) TableName() string {
+	return "sms_redemption"
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// OrderRefund 售后退款/退货申请
+type OrderRefund struct {
+	ID                int64             `gorm:"primaryKey" json:"id"`
+	RefundNo          string            `gorm:"column:refund_no;type:varchar(64);uniqueIndex" json:"refund_no"`
+	OrderID           int64             `gorm:"column:order_id;index;not null" json:"order_id"`
+	UserID            int64             `gorm:"column:user_id;index;not null" json:"user_id"`
+	StoreID           int64             `gorm:"column:store_id;index" json:"store_id"`
+	Type              int               `gorm:"not null;default:1" json:"type"` // 1:仅退款 2:退货退款
+	Reason            string            `gorm:"type:varchar(255)" json:"reason"`
+	Amount            Money             `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`
+	RefundPoints      int               `gorm:"column:refund_points;not null;default:0" json:"refund_points"`
+	RefundBalance     Money             `gorm:"column:refund_balance;type:decimal(10,2);not null;default:0.00" json:"refund_balance"`
+	Status            int               `gorm:"not null;default:0" json:"status"` // 0:待审核 1:已退款 2:已拒绝
+	OrderStatusBefore int               `gorm:"column:order_status_before;not null;default:0" json:"order_status_before"`
+	AuditorID         int64             `gorm:"column:auditor_id;not null;default:0" json:"auditor_id"`
+	AuditRemark       string            `gorm:"column:audit_remark;type:varchar(255)" json:"audit_remark"`
+	AuditedAt         *time.Time        `gorm:"column:audited_at" json:"audited_at"`
+	CreatedAt         time.Time         `json:"created_at"`
+	Items             []OrderRefundItem `gorm:"foreignKey:RefundID" json:"items"`
+	Order             Order             `gorm:"foreignKey:OrderID" json:"order"`
+}
+
+func (OrderRefund) TableName() string {
+	return "oms_order_refund"
+}
+
+// OrderRefundItem 退款申请中的商品明细
+type OrderRefundItem struct {
+	ID          int64   `gorm:"primaryKey" json:"id"`
+	RefundID    int64   `gorm:"column:refund_id;index;not null" json:"refund_id"`
+	OrderItemID int64   `gorm:"column:order_item_id;not null" json:"order_item_id"`
+	ProductID   int64   `gorm:"column:product_id;not null" json:"product_id"`
+	Quantity    int     `gorm:"not null" json:"quantity"`
+	Amount      Money   `gorm:"type:decimal(10,2);not null;default:0.00" json:"amount"`
+	Product     Product `gorm:"foreignKey:ProductID" json:"product"`
+}
+
+func (OrderRefundItem) TableName() string {
+	return "oms_order_refund_item"
+}
+
+// RefundItemParam 申请退款时提交的商品及数量
+type RefundItemParam struct {
+	OrderItemID int64 `json:"order_item_id"`
+	Quantity    int   `json:"quantity"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// 结算单状态
+const (
+	SettlementStatusPending   = 0 // 待确认
+	SettlementStatusConfirmed = 1 // 已确认，待打款
+	SettlementStatusPaid      = 2 // 已打款
+	SettlementStatusCancelled = 3 // 已作废，明细释放后可重新结算
+)
+
+// 结算明细类型
+const (
+	SettlementItemOrder  = "order"  // 订单收入
+	SettlementItemRefund = "refund" // 售后退款扣减
+)
+
+// StoreSettlement 门店结算单，一个门店一个结算周期一张
+type StoreSettlement struct {
+	ID               int64                 `gorm:"primaryKey" json:"id"`
+	SettlementNo     string                `gorm:"column:settlement_no;type:varchar(64);uniqueIndex" json:"settlement_no"`
+	StoreID          int64                 `gorm:"column:store_id;index;not null" json:"store_id"`
+	PeriodStart      time.Time             `gorm:"column:period_start" json:"period_start"`
+	PeriodEnd        time.Time             `gorm:"column:period_end" json:"period_end"`
+	OrderCount       int                   `gorm:"column:order_count;not null;default:0" json:"order_count"`
+	GrossAmount      Money                 `gorm:"column:gross_amount;type:decimal(12,2);not null;default:0.00" json:"gross_amount"`           // 订单实收
+	RefundAmount     Money                 `gorm:"column:refund_amount;type:decimal(12,2);not null;default:0.00" json:"refund_amount"`         // 退款扣减
+	CommissionRate   float64               `gorm:"column:commission_rate;type:decimal(5,4);not null;default:0.0000" json:"commission_rate"`    // 结算时的佣金比例
+	CommissionAmount Money                 `gorm:"column:commission_amount;type:decimal(12,2);not null;default:0.00" json:"commission_amount"` // 平台佣金
+	NetAmount        Money                 `gorm:"column:net_amount;type:decimal(12,2);not null;default:0.00" json:"net_amount"`               // 应付门店
+	Status           int                   `gorm:"not null;default:0" json:"status"`
+	OperatorID       int64                 `gorm:"column:operator_id;not null;default:0" json:"operator_id"`
+	Remark           string                `gorm:"type:varchar(255)" json:"remark"`
+	ConfirmedAt      *time.Time            `gorm:"column:confirmed_at" json:"confirmed_at"`
+	PaidAt           *time.Time            `gorm:"column:paid_at" json:"paid_at"`
+	CreatedAt        time.Time             `json:"created_at"`
+	Store            Store                 `gorm:"foreignKey:StoreID" json:"store"`
+	Items            []StoreSettlementItem `gorm:"foreignKey:SettlementID" json:"items,omitempty"`
+}
+
+func (StoreSettlement) TableName() string {
+	return "oms_store_settlement"
+}
+
+// StoreSettlementItem 结算明细，同一订单/退款只能进入一张有效结算单
+type StoreSettlementItem struct {
+	ID           int64     `gorm:"primaryKey" json:"id"`
+	SettlementID int64     `gorm:"column:settlement_id;index;not null" json:"settlement_id"`
+	ItemType     string    `gorm:"column:item_type;type:varchar(16);uniqueIndex:idx_settlement_related" json:"item_type"`
+	RelatedID    int64     `gorm:"column:related_id;uniqueIndex:idx_settlement_related" json:"related_id"`
+	OrderID      int64     `gorm:"column:order_id;index" json:"order_id"`
+	OrderNo      string    `gorm:"column:order_no;type:varchar(64)" json:"order_no"`
+	Amount       Money     `gorm:"type:decimal(12,2);not null;default:0.00" json:"amount"` // 退款为负数
+	OccurredAt   time.Time `gorm:"column:occurred_at" json:"occurred_at"`
+}
+
+func (StoreSettlementItem) TableName() string {
+	return "oms_store_settlement_item"
+}
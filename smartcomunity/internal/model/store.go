@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type Store struct {
 	ID            int64  `gorm:"primaryKey" json:"id"`
 	Name          string `gorm:"type:varchar(128)" json:"name"`
@@ -7,6 +9,8 @@ type Store struct {
 	Phone         string `gorm:"type:varchar(32)" json:"phone"`
 	Region        string `gorm:"type:varchar(128)" json:"region"`
 	BusinessHours string `gorm:"column:business_hours;type:varchar(64)" json:"business_hours"`
+
+	CommissionRate float64 `gorm:"column:commission_rate;type:decimal(5,4);not null;default:0.0000" json:"commission_rate"` // 平台佣金比例，如 0.05
 }
 
 func (Store) TableName() string {
@@ -14,12 +18,57 @@ func (Store) TableName() string {
 }
 
 type StoreProduct struct {
-	ID        int64 `gorm:"primaryKey" json:"id"`
-	StoreID   int64 `json:"store_id"`
-	ProductID int64 `json:"product_id"`
-	Stock     int   `json:"stock"`
+	ID        int64   `gorm:"primaryKey" json:"id"`
+	StoreID   int64   `gorm:"index:idx_store_product,unique" json:"store_id"`
+	ProductID int64   `gorm:"index:idx_store_product,unique" json:"product_id"`
+	Stock     int     `json:"stock"`
+	Product   Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 }
 
 func (StoreProduct) TableName() string {
 	return "pms_store_product"
 }
+
+// 门店库存流水类型
+const (
+	StockMovementAdjust      = "adjust"       // 后台设置库存
+	StockMovementOrder       = "order"        // 下单扣减
+	StockMovementOrderCancel = "order_cancel" // 取消订单归还
+	StockMovementRefund      = "refund"       // 售后退款归还
+	StockMovementTransferIn  = "transfer_in"  // 调拨转入
+	StockMovementTransferOut = "transfer_out" // 调拨转出
+	StockMovementFlashSale   = "flash_sale"   // 划拨秒杀库存
+	StockMovementFlashReturn = "flash_return" // 秒杀结束归还
+)
+
+// StoreStockMovement 门店库存流水
+type StoreStockMovement struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	StoreID    int64     `gorm:"column:store_id;index;not null" json:"store_id"`
+	ProductID  int64     `gorm:"column:product_id;index;not null" json:"product_id"`
+	Type       string    `gorm:"type:varchar(32);not null" json:"type"`
+	Change     int       `gorm:"not null" json:"change"`
+	StockAfter int       `gorm:"column:stock_after;not null" json:"stock_after"`
+	RelatedID  int64     `gorm:"column:related_id;not null;default:0" json:"related_id"`
+	OperatorID int64     `gorm:"column:operator_id;not null;default:0" json:"operator_id"`
+	Remark     string    `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (StoreStockMovement) TableName() string {
+	return "pms_store_stock_movement"
+}
+
+// SysUserStore 门店账号与门店的绑定，store 角色只能管理已绑定的门店
+type SysUserStore struct {
+	ID        int64     `gorm:"primaryKey" json:"id"`
+	UserID    int64     `gorm:"index:idx_user_store,unique;not null" json:"user_id"`
+	StoreID   int64     `gorm:"index:idx_user_store,unique;index;not null" json:"store_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Store     Store     `gorm:"foreignKey:StoreID" json:"store,omitempty"`
+	SysUser   SysUser   `gorm:"foreignKey:UserID" json:"sys_user,omitempty"`
+}
+
+func (SysUserStore) TableName() string {
+	return "sys_user_store"
+}
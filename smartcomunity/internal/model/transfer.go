@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// 转账状态
+const (
+	TransferStatusPending   = 0 // 撤销期内，资金已从付款人扣出但未到账
+	TransferStatusSettled   = 1 // 已到账
+	TransferStatusCancelled = 2 // 付款人已撤销，资金退回
+)
+
+// Transfer 用户间转账单，撤销期结束后由定时任务入账到收款人
+type Transfer struct {
+	ID          int64      `gorm:"primaryKey" json:"id"`
+	TransferNo  string     `gorm:"column:transfer_no;type:varchar(64);uniqueIndex;not null" json:"transfer_no"`
+	FromUserID  int64      `gorm:"column:from_user_id;index;not null" json:"from_user_id"`
+	ToUserID    int64      `gorm:"column:to_user_id;index;not null" json:"to_user_id"`
+	Amount      Money      `gorm:"type:decimal(12,2);not null;default:0.00" json:"amount"`
+	Remark      string     `gorm:"type:varchar(255)" json:"remark"`
+	AuthType    string     `gorm:"column:auth_type;type:varchar(16)" json:"auth_type"`
+	Status      int        `gorm:"index:idx_transfer_settle;not null;default:0" json:"status"`
+	SettleAt    time.Time  `gorm:"column:settle_at;index:idx_transfer_settle" json:"settle_at"` // 预计到账时间，之前可撤销
+	SettledAt   *time.Time `gorm:"column:settled_at" json:"settled_at"`
+	CancelledAt *time.Time `gorm:"column:cancelled_at" json:"cancelled_at"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+
+	// 展示用，已脱敏
+	FromName string `gorm:"-" json:"from_name,omitempty"`
+	ToName   string `gorm:"-" json:"to_name,omitempty"`
+	ToMobile string `gorm:"-" json:"to_mobile,omitempty"`
+}
+
+func (Transfer) TableName() string {
+	return "sys_transfer"
+}
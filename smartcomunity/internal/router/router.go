@@ -16,6 +16,8 @@ func InitRouter(r *gin.Engine) {
 	noticeHandler := controller.NoticeHandler{}
 	repairHandler := controller.RepairHandler{}
 	financeHandler := controller.FinanceHandler{}
+	receiptHandler := controller.ReceiptHandler{}
+	redeemHandler := controller.RedeemHandler{}
 	securityHandler := controller.SecurityHandler{}
 	favoriteHandler := controller.FavoriteHandler{}
 	storeHandler := controller.StoreHandler{}
@@ -25,6 +27,13 @@ func InitRouter(r *gin.Engine) {
 	aiHandler := controller.AIHandler{}
 	greenPointHandler := controller.GreenPointHandler{}
 	communityMessageHandler := controller.CommunityMessageHandler{}
+	refundHandler := controller.RefundHandler{}
+	couponHandler := controller.CouponHandler{}
+	flashSaleHandler := controller.FlashSaleHandler{}
+	groupBuyHandler := controller.GroupBuyHandler{}
+	settlementHandler := controller.SettlementHandler{}
+	paymentHandler := controller.PaymentHandler{}
+	propertyHandler := controller.PropertyHandler{}
 
 	publicAPI := r.Group("/api/v1")
 	{
@@ -42,8 +51,11 @@ func InitRouter(r *gin.Engine) {
 		publicAPI.GET("/dashboard/stats", adminHandler.GetDashboardStats)
 		publicAPI.GET("/comments", commentHandler.List)
 		publicAPI.GET("/green-points/leaderboard", greenPointHandler.Leaderboard)
+		publicAPI.GET("/redeem/items", redeemHandler.Catalog)
+		publicAPI.GET("/redeem/item/:id", redeemHandler.Item)
 		publicAPI.GET("/notices", noticeHandler.List)
 		publicAPI.GET("/notice/:id", noticeHandler.Detail)
+		publicAPI.POST("/payment/notify/:provider", paymentHandler.Notify)
 	}
 
 	private := r.Group("/api/v1")
@@ -54,35 +66,100 @@ func InitRouter(r *gin.Engine) {
 		private.DELETE("/cart/:id", cartHandler.Delete)
 		private.POST("/cart/:id", cartHandler.Update)
 
-		private.POST("/order/create", orderHandler.Create)
+		private.POST("/order/create", middleware.Idempotency(), orderHandler.Create)
 		private.GET("/order/list", orderHandler.List)
 		private.GET("/order/detail", orderHandler.Detail)
-		private.POST("/order/pay", orderHandler.Pay)
+		private.POST("/order/pay", middleware.Idempotency(), orderHandler.Pay)
 		private.GET("/order/admin/list", middleware.RequireRole("admin", "store"), orderHandler.ListAll)
 		private.POST("/order/ship", middleware.RequireRole("admin", "store"), orderHandler.Ship)
+		private.POST("/order/pickup/verify", middleware.RequireRole("admin", "store"), orderHandler.VerifyPickup)
 		private.POST("/order/receive", orderHandler.Receive)
 		private.POST("/order/cancel", orderHandler.Cancel)
+		private.POST("/order/admin/close", middleware.RequireRole("admin"), orderHandler.Close)
+		private.POST("/order/refund/apply", refundHandler.Apply)
+		private.GET("/order/refund/list", refundHandler.List)
+		private.GET("/order/refund/admin/list", middleware.RequireRole("admin", "store"), refundHandler.ListAll)
+		private.POST("/order/refund/audit", middleware.RequireRole("admin", "store"), refundHandler.Audit)
 
 		private.POST("/repair/create", repairHandler.Create)
 		private.GET("/repair/list", repairHandler.List)
 
-		private.POST("/finance/pay", financeHandler.Pay)
+		private.POST("/finance/pay", middleware.Idempotency(), financeHandler.Pay)
 		private.GET("/property/list", financeHandler.ListPropertyFee)
-		private.POST("/finance/recharge", financeHandler.Recharge)
-		private.POST("/finance/transfer", financeHandler.Transfer)
+		private.POST("/finance/recharge", middleware.Idempotency(), financeHandler.Recharge)
+		private.GET("/finance/recharge/status", financeHandler.RechargeStatus)
+		private.GET("/finance/recharges", financeHandler.ListRecharges)
+		private.POST("/finance/transfer/preview", financeHandler.PreviewTransfer)
+		private.POST("/finance/transfer", middleware.Idempotency(), financeHandler.Transfer)
+		private.POST("/finance/transfer/cancel", middleware.Idempotency(), financeHandler.CancelTransfer)
+		private.GET("/finance/transfers", financeHandler.ListTransfers)
 		private.GET("/finance/transactions", financeHandler.ListTransactions)
+		private.GET("/finance/statement/export", financeHandler.ExportStatement)
+		private.GET("/finance/receipts", receiptHandler.List)
+		private.GET("/finance/receipt/:id", receiptHandler.Detail)
+		private.GET("/finance/receipt/:id/download", receiptHandler.Download)
 
 		private.POST("/green-points/upload-garbage", greenPointHandler.UploadGarbage)
-
-		private.POST("/marketing/promotion/create", marketingHandler.Create)
+		private.GET("/green-points/expiring", greenPointHandler.Expiring)
+		private.GET("/green-points/leaderboard/me", greenPointHandler.MyRank)
+		private.GET("/green-points/admin/uploads", middleware.RequireRole("admin"), greenPointHandler.AdminUploads)
+		private.POST("/green-points/admin/review", middleware.RequireRole("admin"), greenPointHandler.ReviewUpload)
+		private.POST("/green-points/admin/leaderboard/rebuild", middleware.RequireRole("admin"), greenPointHandler.RebuildLeaderboard)
+		private.POST("/redeem", middleware.Idempotency(), redeemHandler.Redeem)
+		private.GET("/redeem/mine", redeemHandler.Mine)
+		private.POST("/redeem/cancel", redeemHandler.CancelMine)
+		private.POST("/redeem/admin/item/save", middleware.RequireRole("admin", "store"), redeemHandler.SaveItem)
+		private.DELETE("/redeem/admin/item/:id", middleware.RequireRole("admin", "store"), redeemHandler.DeleteItem)
+		private.GET("/redeem/admin/items", middleware.RequireRole("admin", "store"), redeemHandler.ListItems)
+		private.GET("/redeem/admin/records", middleware.RequireRole("admin", "store"), redeemHandler.ListRecords)
+		private.POST("/redeem/admin/fulfil", middleware.RequireRole("admin", "store"), redeemHandler.Fulfil)
+		private.POST("/redeem/admin/cancel", middleware.RequireRole("admin", "store"), redeemHandler.Cancel)
+
+		private.POST("/marketing/promotion/create", middleware.RequireRole("admin"), marketingHandler.Create)
 		private.GET("/marketing/promotion/list", marketingHandler.List)
-		private.DELETE("/marketing/promotion/:id", marketingHandler.Delete)
-
-		private.POST("/store/create", middleware.RequireRole("admin", "store"), storeHandler.Create)
+		private.DELETE("/marketing/promotion/:id", middleware.RequireRole("admin"), marketingHandler.Delete)
+
+		private.GET("/coupons", couponHandler.Claimable)
+		private.POST("/coupon/claim", couponHandler.Claim)
+		private.GET("/coupon/mine", couponHandler.Mine)
+		private.POST("/coupon/admin/create", middleware.RequireRole("admin"), couponHandler.Create)
+		private.GET("/coupon/admin/list", middleware.RequireRole("admin"), couponHandler.ListAll)
+
+		private.GET("/flash-sales", flashSaleHandler.List)
+		private.POST("/flash-sale/buy", flashSaleHandler.Buy)
+		private.GET("/flash-sale/result", flashSaleHandler.Result)
+		private.POST("/flash-sale/admin/create", middleware.RequireRole("admin"), flashSaleHandler.Create)
+		private.POST("/flash-sale/admin/end", middleware.RequireRole("admin"), flashSaleHandler.End)
+		private.GET("/flash-sale/admin/list", middleware.RequireRole("admin"), flashSaleHandler.ListAll)
+
+		private.GET("/group-buys", groupBuyHandler.List)
+		private.GET("/group-buys/mine", groupBuyHandler.Mine)
+		private.GET("/group-buy/:id", groupBuyHandler.Detail)
+		private.POST("/group-buy/open", groupBuyHandler.Open)
+		private.POST("/group-buy/join", groupBuyHandler.Join)
+
+		private.POST("/store/create", middleware.RequireRole("admin"), storeHandler.Create)
 		private.POST("/store/update", middleware.RequireRole("admin", "store"), storeHandler.Update)
-		private.DELETE("/store/:id", middleware.RequireRole("admin", "store"), storeHandler.Delete)
+		private.DELETE("/store/:id", middleware.RequireRole("admin"), storeHandler.Delete)
+		private.GET("/store/mine", middleware.RequireRole("admin", "store"), storeHandler.Mine)
+		private.POST("/store/admin/bind_user", middleware.RequireRole("admin"), storeHandler.BindUser)
+		private.POST("/store/admin/unbind_user", middleware.RequireRole("admin"), storeHandler.UnbindUser)
+		private.GET("/store/admin/users", middleware.RequireRole("admin"), storeHandler.Users)
+		private.POST("/store/admin/commission", middleware.RequireRole("admin"), storeHandler.SetCommission)
+
+		private.GET("/settlement/list", middleware.RequireRole("admin", "store"), settlementHandler.List)
+		private.GET("/settlement/detail", middleware.RequireRole("admin", "store"), settlementHandler.Detail)
+		private.GET("/settlement/statement", middleware.RequireRole("admin", "store"), settlementHandler.Statement)
+		private.POST("/settlement/admin/generate", middleware.RequireRole("admin"), settlementHandler.Generate)
+		private.POST("/settlement/admin/confirm", middleware.RequireRole("admin"), settlementHandler.Confirm)
+		private.POST("/settlement/admin/pay", middleware.RequireRole("admin"), settlementHandler.Pay)
+		private.POST("/settlement/admin/cancel", middleware.RequireRole("admin"), settlementHandler.Cancel)
 		private.POST("/store/bind_product", middleware.RequireRole("admin", "store"), storeHandler.BindProduct)
+		private.GET("/store/stock", middleware.RequireRole("admin", "store"), storeHandler.Stock)
+		private.POST("/store/transfer_stock", middleware.RequireRole("admin", "store"), storeHandler.TransferStock)
+		private.GET("/store/stock/movements", middleware.RequireRole("admin", "store"), storeHandler.StockMovements)
 
+		private.GET("/product/admin/list", middleware.RequireRole("admin", "store"), productHandler.ListManaged)
 		private.POST("/product/create", middleware.RequireRole("admin", "store"), productHandler.Create)
 		private.POST("/product/update", middleware.RequireRole("admin", "store"), productHandler.Update)
 		private.DELETE("/product/:id", middleware.RequireRole("admin", "store"), productHandler.Delete)
@@ -100,6 +177,8 @@ func InitRouter(r *gin.Engine) {
 		private.POST("/notice/create", middleware.RequireRole("admin", "property"), noticeHandler.Create)
 		private.DELETE("/notice/:id", middleware.RequireRole("admin", "property"), noticeHandler.Delete)
 		private.POST("/notice/read/:id", noticeHandler.Read)
+		private.GET("/notice/mine", noticeHandler.Mine)
+		private.POST("/notice/mine/read/:id", noticeHandler.ReadMine)
 
 		private.GET("/repair/admin/list", middleware.RequireRole("admin", "property"), repairHandler.ListAll)
 		private.POST("/repair/process", middleware.RequireRole("admin", "property"), repairHandler.Process)
@@ -120,8 +199,30 @@ func InitRouter(r *gin.Engine) {
 		private.POST("/parking/admin/assign", middleware.RequireRole("admin", "property"), securityHandler.AssignParking)
 		private.POST("/parking/admin/create", middleware.RequireRole("admin", "property"), securityHandler.CreateParking)
 
-		private.POST("/property/admin/create", middleware.RequireRole("admin", "property"), financeHandler.CreatePropertyFee)
+		private.POST("/property/admin/create", middleware.RequireRole("admin", "property"), middleware.Idempotency(), financeHandler.CreatePropertyFee)
 		private.GET("/property/admin/list", middleware.RequireRole("admin", "property"), financeHandler.ListAllPropertyFees)
+		private.POST("/property/admin/household/save", middleware.RequireRole("admin", "property"), propertyHandler.SaveHousehold)
+		private.DELETE("/property/admin/household/:id", middleware.RequireRole("admin", "property"), propertyHandler.DeleteHousehold)
+		private.GET("/property/admin/households", middleware.RequireRole("admin", "property"), propertyHandler.ListHouseholds)
+		private.POST("/property/admin/rate/save", middleware.RequireRole("admin", "property"), propertyHandler.SaveRate)
+		private.DELETE("/property/admin/rate/:id", middleware.RequireRole("admin", "property"), propertyHandler.DeleteRate)
+		private.GET("/property/admin/rates", middleware.RequireRole("admin", "property"), propertyHandler.ListRates)
+		private.POST("/property/admin/billing/run", middleware.RequireRole("admin", "property"), middleware.Idempotency(), propertyHandler.RunBilling)
+		private.GET("/property/admin/billing/runs", middleware.RequireRole("admin", "property"), propertyHandler.ListBillingRuns)
+		private.GET("/property/admin/arrears", middleware.RequireRole("admin", "property"), propertyHandler.Arrears)
+		private.POST("/property/admin/remind", middleware.RequireRole("admin", "property"), propertyHandler.Remind)
+		private.GET("/finance/admin/ledger/accounts", middleware.RequireRole("admin"), financeHandler.ListLedgerAccounts)
+		private.GET("/finance/admin/ledger/entries", middleware.RequireRole("admin"), financeHandler.ListLedgerEntries)
+		private.GET("/finance/admin/ledger/check", middleware.RequireRole("admin"), financeHandler.CheckLedger)
+		private.POST("/finance/admin/recharge/refund", middleware.RequireRole("admin"), middleware.Idempotency(), financeHandler.RefundRecharge)
+		private.POST("/finance/admin/reconcile/run", middleware.RequireRole("admin"), middleware.Idempotency(), financeHandler.RunReconciliation)
+		private.GET("/finance/admin/reconcile/list", middleware.RequireRole("admin"), financeHandler.ListReconciliations)
+		private.GET("/finance/admin/reconcile/discrepancies", middleware.RequireRole("admin"), financeHandler.ListDiscrepancies)
+		private.POST("/finance/admin/reconcile/resolve", middleware.RequireRole("admin"), middleware.Idempotency(), financeHandler.ResolveDiscrepancy)
+		private.GET("/finance/admin/statement/export", middleware.RequireRole("admin"), financeHandler.AdminExportStatement)
+		private.GET("/finance/admin/receipts", middleware.RequireRole("admin"), receiptHandler.AdminList)
+		private.POST("/finance/admin/receipt/reissue", middleware.RequireRole("admin"), middleware.Idempotency(), receiptHandler.Reissue)
+		private.POST("/finance/admin/receipt/void", middleware.RequireRole("admin"), receiptHandler.Void)
 
 		private.POST("/admin/role/create", middleware.RequireRole("admin"), adminHandler.CreateRole)
 		private.GET("/admin/role/list", middleware.RequireRole("admin"), adminHandler.ListRoles)
@@ -138,7 +239,9 @@ func InitRouter(r *gin.Engine) {
 		private.GET("/admin/ai-report", middleware.RequireRole("admin"), adminHandler.GetAIReport)
 
 		private.POST("/comment/create", commentHandler.Create)
-		private.POST("/chat/send", aiHandler.Send)
+		private.GET("/comment/admin/list", middleware.RequireRole("admin", "store"), commentHandler.ListManaged)
+		private.DELETE("/comment/:id", middleware.RequireRole("admin", "store"), commentHandler.Delete)
+		private.POST("/chat/send", middleware.Idempotency(), aiHandler.Send)
 		private.GET("/chat/history", aiHandler.History)
 		private.POST("/community/message", communityMessageHandler.Send)
 		private.GET("/community/messages", communityMessageHandler.List)
@@ -24,6 +24,7 @@ const (
 	chatContextWindowSize = 10
 	maxChatHistoryLimit   = 200
 	maxAgentToolRounds    = 5
+	aiToolIdempotencyTTL  = 2 * time.Minute
 	facePayMinConfidence  = 85.0
 
 	toolGetRecentNotices   = "get_recent_notices"
@@ -45,6 +46,7 @@ type chatExecutionContext struct {
 	paymentPassword string
 	payType         string
 	faceImageURL    string
+	messageID       int64 // 本轮用户消息 ID，作为有副作用工具的幂等范围
 }
 
 type dashScopeMessage struct {
@@ -175,6 +177,7 @@ func (s *AIService) ChatWithMemory(userID int64, userContent string, paymentPass
 		paymentPassword: strings.TrimSpace(paymentPassword),
 		payType:         strings.ToLower(strings.TrimSpace(payType)),
 		faceImageURL:    strings.TrimSpace(faceImageURL),
+		messageID:       userMsg.ID,
 	}, messages)
 	if err != nil {
 		return "", err
@@ -198,7 +201,7 @@ func (s *AIService) runAgentWithTools(userID int64, execCtx chatExecutionContext
 	}
 
 	// Deterministic path: create order directly for simple "下单X瓶" instructions.
-	if reply, handled, err := s.tryHandleCreateOrderIntent(userID, messages, lastUser); handled {
+	if reply, handled, err := s.tryHandleCreateOrderIntent(userID, execCtx, messages, lastUser); handled {
 		return reply, err
 	}
 	// Deterministic path: pay latest pending order when user confirms payment / enters password.
@@ -331,7 +334,7 @@ func (s *AIService) dispatchToolCall(userID int64, execCtx chatExecutionContext,
 		}
 
 		onShelf := 1
-		list, total, err := (&ProductService{}).GetList(1, args.Limit, args.Keyword, 0, 0, "sales_desc", 0, false, &onShelf)
+		list, total, err := (&ProductService{}).GetList(1, args.Limit, args.Keyword, 0, 0, "sales_desc", 0, false, &onShelf, 0)
 		if err != nil {
 			return nil, err
 		}
@@ -352,14 +355,16 @@ func (s *AIService) dispatchToolCall(userID int64, execCtx chatExecutionContext,
 		if len(args.Items) == 0 {
 			return nil, errors.New("items cannot be empty")
 		}
-		order, err := s.createOrderFromProducts(userID, args.StoreID, args.Items)
-		if err != nil {
-			return nil, err
-		}
-		return map[string]interface{}{
-			"success": true, "order_id": order.ID, "order_no": order.OrderNo, "store_id": order.StoreID,
-			"total_amount": order.TotalAmount, "status": order.Status,
-		}, nil
+		return s.runIdempotentTool(userID, execCtx.messageID, toolCall.Function.Name, args, func() (interface{}, error) {
+			order, err := s.createOrderFromProducts(userID, args.StoreID, args.Items)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"success": true, "order_id": order.ID, "order_no": order.OrderNo, "store_id": order.StoreID,
+				"total_amount": order.TotalAmount, "status": order.Status,
+			}, nil
+		})
 
 	case toolPayOrder:
 		var args payOrderArgs
@@ -379,13 +384,52 @@ func (s *AIService) dispatchToolCall(userID int64, execCtx chatExecutionContext,
 		if faceImageURL == "" {
 			faceImageURL = strings.TrimSpace(execCtx.faceImageURL)
 		}
-		payResult, err := s.payOrderWithAIAuth(userID, orderID, authType, password, faceImageURL)
-		if err != nil {
+		// 以解析出的订单为幂等依据，同一订单重复调用支付只执行一次
+		return s.runIdempotentTool(userID, execCtx.messageID, toolCall.Function.Name, map[string]interface{}{"order_id": orderID}, func() (interface{}, error) {
+			payResult, err := s.payOrderWithAIAuth(userID, orderID, authType, password, faceImageURL)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"success": true, "order_id": orderID, "payment_result": payResult}, nil
+		})
+	}
+	return nil, fmt.Errorf("unsupported tool call: %s", toolCall.Function.Name)
+}
+
+// runIdempotentTool 模型在同一条用户消息的处理中用相同参数重复调用有副作用的工具时，直接返回首次调用的结果；
+// 幂等范围限定在消息内，用户另发消息要求再下一单时会正常执行
+func (s *AIService) runIdempotentTool(userID, messageID int64, toolName string, args interface{}, run func() (interface{}, error)) (interface{}, error) {
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	key := IdempotencyHash([]byte(toolName), []byte(strconv.FormatInt(messageID, 10)), payload)
+	idempotencyService := &IdempotencyService{}
+	record, replay, err := idempotencyService.Begin(userID, "ai_tool:"+toolName, key, key, aiToolIdempotencyTTL)
+	if err != nil {
+		return nil, err
+	}
+	if replay {
+		var result map[string]interface{}
+		if err := json.Unmarshal([]byte(record.ResponseBody), &result); err != nil {
 			return nil, err
 		}
-		return map[string]interface{}{"success": true, "order_id": orderID, "payment_result": payResult}, nil
+		result["idempotent_replay"] = true
+		return result, nil
 	}
-	return nil, fmt.Errorf("unsupported tool call: %s", toolCall.Function.Name)
+
+	result, err := run()
+	if err != nil {
+		if releaseErr := idempotencyService.Release(record); releaseErr != nil {
+			log.Printf("release AI tool idempotency key failed, tool=%s userID=%d err=%v", toolName, userID, releaseErr)
+		}
+		return nil, err
+	}
+	body, _ := json.Marshal(result)
+	if err := idempotencyService.Complete(record, http.StatusOK, "application/json", body); err != nil {
+		log.Printf("save AI tool idempotency result failed, tool=%s userID=%d err=%v", toolName, userID, err)
+	}
+	return result, nil
 }
 
 func (s *AIService) createOrderFromProducts(userID, storeID int64, rawItems []createOrderItemArg) (*model.Order, error) {
@@ -445,7 +489,7 @@ func (s *AIService) createOrderFromProducts(userID, storeID int64, rawItems []cr
 		orderItems = append(orderItems, model.CartItemParam{CartID: cart.ID, Quantity: merged[pid]})
 	}
 
-	order, err := (&OrderService{}).CreateOrder(userID, resolvedStoreID, orderItems)
+	order, err := (&OrderService{}).CreateOrder(userID, resolvedStoreID, orderItems, 0, model.DeliveryTypeExpress)
 	if err != nil {
 		s.cleanupTempCarts(userID, cartIDs)
 		return nil, err
@@ -488,7 +532,7 @@ func (s *AIService) resolveOrderIDForPayment(userID int64, args payOrderArgs) (i
 
 	// Fallback for LLM confusion: if a pending order exists, pay the newest one.
 	var pending model.Order
-	if err := global.DB.Where("user_id = ? AND status = ?", userID, 0).Order("id DESC").First(&pending).Error; err == nil {
+	if err := global.DB.Where("user_id = ? AND status = ?", userID, model.OrderStatusPending).Order("id DESC").First(&pending).Error; err == nil {
 		return pending.ID, nil
 	}
 
@@ -1004,7 +1048,7 @@ func formatCriticalToolError(toolName string, err error) error {
 	}
 }
 
-func (s *AIService) tryHandleCreateOrderIntent(userID int64, messages []dashScopeMessage, lastUser string) (string, bool, error) {
+func (s *AIService) tryHandleCreateOrderIntent(userID int64, execCtx chatExecutionContext, messages []dashScopeMessage, lastUser string) (string, bool, error) {
 	if !isCreateOrderIntent(lastUser) {
 		return "", false, nil
 	}
@@ -1019,7 +1063,7 @@ func (s *AIService) tryHandleCreateOrderIntent(userID int64, messages []dashScop
 
 	quantity := extractOrderQuantity(lastUser)
 	onShelf := 1
-	products, _, err := (&ProductService{}).GetList(1, 1, keyword, 0, 0, "sales_desc", 0, false, &onShelf)
+	products, _, err := (&ProductService{}).GetList(1, 1, keyword, 0, 0, "sales_desc", 0, false, &onShelf, 0)
 	if err != nil {
 		return fmt.Sprintf("下单失败：搜索商品失败：%v", err), true, nil
 	}
@@ -1027,21 +1071,32 @@ func (s *AIService) tryHandleCreateOrderIntent(userID int64, messages []dashScop
 		return fmt.Sprintf("下单失败：未找到商品“%s”。", keyword), true, nil
 	}
 
-	order, err := s.createOrderFromProducts(userID, 0, []createOrderItemArg{{
-		ProductID: products[0].ID,
-		Quantity:  quantity,
-	}})
+	// 与工具调用路径共用幂等范围，同一条用户消息重复处理时返回首次创建的订单
+	args := createOrderArgs{Items: []createOrderItemArg{{ProductID: products[0].ID, Quantity: quantity}}}
+	result, err := s.runIdempotentTool(userID, execCtx.messageID, toolCreateOrder, args, func() (interface{}, error) {
+		order, err := s.createOrderFromProducts(userID, args.StoreID, args.Items)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"success": true, "order_id": order.ID, "order_no": order.OrderNo,
+			"reply": fmt.Sprintf(
+				"✅ 订单已创建成功\n订单号：%s\n应付金额：￥%s\n商品：%s x%d\n\n请发送“确认支付”并输入登录密码完成支付。",
+				order.OrderNo,
+				order.TotalAmount,
+				products[0].Name,
+				quantity,
+			),
+		}, nil
+	})
 	if err != nil {
 		return fmt.Sprintf("下单失败：%v", err), true, nil
 	}
-
-	return fmt.Sprintf(
-		"✅ 订单已创建成功\n订单号：%s\n应付金额：￥%.2f\n商品：%s x%d\n\n请发送“确认支付”并输入登录密码完成支付。",
-		order.OrderNo,
-		order.TotalAmount,
-		products[0].Name,
-		quantity,
-	), true, nil
+	m, _ := result.(map[string]interface{})
+	if reply, ok := m["reply"].(string); ok && reply != "" {
+		return reply, true, nil
+	}
+	return fmt.Sprintf("✅ 订单已创建成功\n订单号：%v\n\n请发送“确认支付”并输入登录密码完成支付。", m["order_no"]), true, nil
 }
 
 func (s *AIService) tryHandlePayIntent(userID int64, execCtx chatExecutionContext, lastUser string) (string, bool, error) {
@@ -1062,7 +1117,7 @@ func (s *AIService) tryHandlePayIntent(userID int64, execCtx chatExecutionContex
 	}
 
 	var order model.Order
-	if err := global.DB.Where("user_id = ? AND status = ?", userID, 0).Order("id DESC").First(&order).Error; err != nil {
+	if err := global.DB.Where("user_id = ? AND status = ?", userID, model.OrderStatusPending).Order("id DESC").First(&order).Error; err != nil {
 		return "支付失败：未找到待支付订单。", true, nil
 	}
 
@@ -1072,7 +1127,7 @@ func (s *AIService) tryHandlePayIntent(userID int64, execCtx chatExecutionContex
 	}
 
 	return fmt.Sprintf(
-		"✅ 支付成功\n订单号：%s\n支付金额：￥%.2f\n积分抵扣：%d\n余额支付：￥%.2f",
+		"✅ 支付成功\n订单号：%s\n支付金额：￥%s\n积分抵扣：%d\n余额支付：￥%s",
 		order.OrderNo,
 		payResult.TotalAmount,
 		payResult.UsedPoints,
@@ -90,17 +90,12 @@ func (s *AdminService) AssignRole(userID int64, roleCode string) error {
 	return global.DB.Model(&model.SysUser{}).Where("id = ?", userID).Update("role", roleCode).Error
 }
 
-func (s *AdminService) UpdateUserBalance(userID int64, amount float64) error {
+func (s *AdminService) UpdateUserBalance(userID int64, amount model.Money) error {
 	if amount == 0 {
 		return nil
 	}
 
 	return global.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&model.SysUser{}).Where("id = ?", userID).
-			Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
-			return err
-		}
-
 		remark := "Admin adjusted balance"
 		if amount > 0 {
 			remark = "System balance recharge"
@@ -108,9 +103,17 @@ func (s *AdminService) UpdateUserBalance(userID int64, amount float64) error {
 			remark = "System balance deduction"
 		}
 
+		cents := amount.Cents()
+		if _, err := postLedgerEntry(tx, LedgerBizAdjust, userID, remark,
+			ledgerLeg{AccountType: model.LedgerAccountCashClearing, Amount: -cents},
+			ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: userID, Amount: cents},
+		); err != nil {
+			return err
+		}
+
 		transaction := model.SysTransaction{
 			UserID:    userID,
-			Type:      TransactionTypeTopUp,
+			Type:      TransactionTypeAdjust,
 			Amount:    amount,
 			Remark:    remark,
 			CreatedAt: time.Now(),
@@ -144,7 +147,7 @@ func (s *AdminService) GetDashboardStats() (*DashboardStats, error) {
 	monthStart := time.Now().Format("2006-01") + "-01 00:00:00"
 	var mallIncome float64
 	global.DB.Model(&model.Order{}).
-		Where("created_at >= ? AND status in (1,2,3)", monthStart).
+		Where("created_at >= ? AND status IN ?", monthStart, orderRevenueStatuses()).
 		Select("COALESCE(sum(total_amount), 0)").
 		Scan(&mallIncome)
 	stats.MonthIncome = mallIncome
@@ -182,7 +185,7 @@ func (s *AdminService) GetDashboardStats() (*DashboardStats, error) {
 		start := day + " 00:00:00"
 		end := day + " 23:59:59"
 		global.DB.Model(&model.Order{}).
-			Where("created_at BETWEEN ? AND ? AND status in (1,2,3)", start, end).
+			Where("created_at BETWEEN ? AND ? AND status IN ?", start, end, orderRevenueStatuses()).
 			Select("COALESCE(sum(total_amount), 0)").
 			Scan(&dayIncome)
 		stats.IncomeTrend = append(stats.IncomeTrend, dayIncome)
@@ -191,7 +194,7 @@ func (s *AdminService) GetDashboardStats() (*DashboardStats, error) {
 	yearStart := time.Now().Format("2006") + "-01-01 00:00:00"
 	var yearMallIncome, yearPropertyIncome float64
 	global.DB.Model(&model.Order{}).
-		Where("created_at >= ? AND status in (1,2,3)", yearStart).
+		Where("created_at >= ? AND status IN ?", yearStart, orderRevenueStatuses()).
 		Select("COALESCE(sum(total_amount), 0)").
 		Scan(&yearMallIncome)
 	global.DB.Model(&model.PropertyFee{}).
@@ -43,7 +43,28 @@ func (s *CartService) GetCartList(userID int64) ([]model.Cart, error) {
 	var list []model.Cart
 	// Preload("Product") 会自动把关联的商品信息查出来填充进去
 	err := global.DB.Preload("Product").Where("user_id = ?", userID).Find(&list).Error
-	return list, err
+	if err != nil {
+		return nil, err
+	}
+
+	// 按当前生效的活动计算每行价格，与下单时的计价保持一致
+	productIDs := make([]int64, 0, len(list))
+	for _, cart := range list {
+		productIDs = append(productIDs, cart.ProductID)
+	}
+	promotions, err := loadActivePromotions(global.DB, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		line := priceLine(list[i].Product.Price, list[i].Quantity, promotions[list[i].ProductID])
+		list[i].Amount = model.Money(line.Cents)
+		if line.Promotion != nil {
+			list[i].PromotionID = line.Promotion.ID
+			list[i].PromotionTitle = line.Promotion.Title
+		}
+	}
+	return list, nil
 }
 
 // DeleteCart 删除购物车项
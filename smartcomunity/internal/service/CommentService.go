@@ -54,3 +54,38 @@ func (s *CommentService) GetCommentsByProductID(productID int64, page, size int)
 	err := db.Preload("User").Order("created_at desc").Offset(offset).Limit(size).Find(&list).Error
 	return list, total, err
 }
+
+// ListManagedComments 后台评论列表，门店账号只能看到自己门店商品的评论
+func (s *CommentService) ListManagedComments(operatorID int64, operatorRole string, productID int64, page, size int) ([]model.ProductComment, int64, error) {
+	var list []model.ProductComment
+	var total int64
+
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := global.DB.Model(&model.ProductComment{})
+	if !scope.All {
+		db = db.Where("product_id IN (?)", scope.Apply(global.DB.Model(&model.Product{}).Select("id"), "store_id"))
+	}
+	if productID > 0 {
+		db = db.Where("product_id = ?", productID)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err = db.Preload("User").Order("created_at desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// DeleteComment 删除评论，门店账号只能删除自己门店商品的评论
+func (s *CommentService) DeleteComment(operatorID int64, operatorRole string, id int64) error {
+	var comment model.ProductComment
+	if err := global.DB.First(&comment, id).Error; err != nil {
+		return errors.New("comment not found")
+	}
+	if err := checkProductScope(operatorID, operatorRole, comment.ProductID); err != nil {
+		return err
+	}
+	return global.DB.Delete(&model.ProductComment{}, id).Error
+}
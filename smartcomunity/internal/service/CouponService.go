@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CouponService struct{}
+
+// couponLine 参与优惠计算的订单行
+type couponLine struct {
+	CategoryID int64
+	Cents      int
+}
+
+// CreateCoupon 创建/修改优惠券 (Admin)
+func (s *CouponService) CreateCoupon(c *model.Coupon) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("coupon name is required")
+	}
+	switch c.Type {
+	case model.CouponTypeFixed:
+		if c.Amount <= 0 {
+			return errors.New("coupon amount must be greater than 0")
+		}
+	case model.CouponTypePercent:
+		if c.Discount <= 0 || c.Discount >= 1 {
+			return errors.New("discount must be between 0 and 1")
+		}
+	case model.CouponTypeThreshold:
+		if c.Amount <= 0 || c.MinAmount <= c.Amount {
+			return errors.New("threshold must be greater than coupon amount")
+		}
+	default:
+		return errors.New("unsupported coupon type")
+	}
+	if !c.EndAt.After(c.StartAt) {
+		return errors.New("end time must be after start time")
+	}
+	if c.PerUserLimit <= 0 {
+		c.PerUserLimit = 1
+	}
+
+	if c.ID > 0 {
+		// 显式列出可修改的列，折扣、门槛、限额、状态等改为 0 时也要写入；已领取数量只由领取流程维护
+		return global.DB.Model(&model.Coupon{}).Where("id = ?", c.ID).
+			Select("name", "type", "amount", "discount", "min_amount", "max_discount", "category_id", "store_id",
+				"total_count", "per_user_limit", "start_at", "end_at", "valid_days", "status").
+			Updates(c).Error
+	}
+	c.ClaimedCount = 0
+	if c.Status == 0 {
+		c.Status = 1
+	}
+	return global.DB.Create(c).Error
+}
+
+// ListCoupons 优惠券列表 (Admin)
+func (s *CouponService) ListCoupons(page, size int) ([]model.Coupon, int64, error) {
+	var list []model.Coupon
+	var total int64
+	db := global.DB.Model(&model.Coupon{})
+	db.Count(&total)
+	err := db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ListClaimable 当前可领取的优惠券
+func (s *CouponService) ListClaimable() ([]model.Coupon, error) {
+	var list []model.Coupon
+	now := time.Now()
+	err := global.DB.Where("status = 1 AND start_at <= ? AND end_at > ?", now, now).
+		Where("total_count = 0 OR claimed_count < total_count").
+		Order("id desc").
+		Find(&list).Error
+	return list, err
+}
+
+// ClaimCoupon 领取优惠券
+func (s *CouponService) ClaimCoupon(userID, couponID int64) (*model.UserCoupon, error) {
+	var userCoupon *model.UserCoupon
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var coupon model.Coupon
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, couponID).Error; err != nil {
+			return errors.New("coupon not found")
+		}
+		now := time.Now()
+		if coupon.Status != 1 || now.Before(coupon.StartAt) || !now.Before(coupon.EndAt) {
+			return errors.New("coupon is not available")
+		}
+		if coupon.TotalCount > 0 && coupon.ClaimedCount >= coupon.TotalCount {
+			return errors.New("coupon has been fully claimed")
+		}
+
+		var claimed int64
+		if err := tx.Model(&model.UserCoupon{}).
+			Where("user_id = ? AND coupon_id = ?", userID, couponID).
+			Count(&claimed).Error; err != nil {
+			return err
+		}
+		if int(claimed) >= coupon.PerUserLimit {
+			return errors.New("claim limit reached")
+		}
+
+		expireAt := coupon.EndAt
+		if coupon.ValidDays > 0 {
+			if byDays := now.AddDate(0, 0, coupon.ValidDays); byDays.Before(expireAt) {
+				expireAt = byDays
+			}
+		}
+
+		userCoupon = &model.UserCoupon{
+			CouponID:  coupon.ID,
+			UserID:    userID,
+			Status:    model.UserCouponUnused,
+			ExpireAt:  expireAt,
+			CreatedAt: now,
+		}
+		if err := tx.Create(userCoupon).Error; err != nil {
+			return err
+		}
+		userCoupon.Coupon = coupon
+		return tx.Model(&model.Coupon{}).Where("id = ?", coupon.ID).
+			Update("claimed_count", gorm.Expr("claimed_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return userCoupon, nil
+}
+
+// ListUserCoupons 我的券包，status 为空时返回全部
+func (s *CouponService) ListUserCoupons(userID int64, status *int) ([]model.UserCoupon, error) {
+	// 先把已过期未使用的券标记为过期
+	global.DB.Model(&model.UserCoupon{}).
+		Where("user_id = ? AND status = ? AND expire_at <= ?", userID, model.UserCouponUnused, time.Now()).
+		Update("status", model.UserCouponExpired)
+
+	var list []model.UserCoupon
+	db := global.DB.Preload("Coupon").Where("user_id = ?", userID)
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	err := db.Order("id desc").Find(&list).Error
+	return list, err
+}
+
+// useCoupon 校验并核销用户优惠券，返回优惠金额(分)
+func useCoupon(tx *gorm.DB, userID, userCouponID, orderID, storeID int64, lines []couponLine) (int, error) {
+	var uc model.UserCoupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Coupon").
+		Where("id = ? AND user_id = ?", userCouponID, userID).
+		First(&uc).Error; err != nil {
+		return 0, errors.New("coupon not found")
+	}
+	now := time.Now()
+	if uc.Status != model.UserCouponUnused || !now.Before(uc.ExpireAt) {
+		return 0, errors.New("coupon is used or expired")
+	}
+
+	discountCents, err := calcCouponDiscount(&uc.Coupon, storeID, lines)
+	if err != nil {
+		return 0, err
+	}
+
+	result := tx.Model(&model.UserCoupon{}).
+		Where("id = ? AND status = ?", uc.ID, model.UserCouponUnused).
+		Updates(map[string]interface{}{
+			"status":   model.UserCouponUsed,
+			"order_id": orderID,
+			"used_at":  &now,
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("coupon is used or expired")
+	}
+	return discountCents, nil
+}
+
+// releaseOrderCoupon 订单取消后退回优惠券
+func releaseOrderCoupon(tx *gorm.DB, orderID int64) error {
+	return tx.Model(&model.UserCoupon{}).
+		Where("order_id = ? AND status = ?", orderID, model.UserCouponUsed).
+		Updates(map[string]interface{}{
+			"status":   model.UserCouponUnused,
+			"order_id": 0,
+			"used_at":  nil,
+		}).Error
+}
+
+func calcCouponDiscount(c *model.Coupon, storeID int64, lines []couponLine) (int, error) {
+	if c.StoreID > 0 && c.StoreID != storeID {
+		return 0, errors.New("coupon is not valid for this store")
+	}
+
+	eligibleCents := 0
+	for _, line := range lines {
+		if c.CategoryID == 0 || c.CategoryID == line.CategoryID {
+			eligibleCents += line.Cents
+		}
+	}
+	if eligibleCents <= 0 {
+		return 0, errors.New("no items in this order are eligible for the coupon")
+	}
+	if eligibleCents < int(c.MinAmount.Cents()) {
+		return 0, errors.New("order amount does not reach the coupon threshold")
+	}
+
+	discountCents := 0
+	switch c.Type {
+	case model.CouponTypeFixed, model.CouponTypeThreshold:
+		discountCents = int(c.Amount.Cents())
+	case model.CouponTypePercent:
+		discountCents = int(math.Round(float64(eligibleCents) * (1 - c.Discount)))
+		if maxCents := int(c.MaxDiscount.Cents()); maxCents > 0 && discountCents > maxCents {
+			discountCents = maxCents
+		}
+	default:
+		return 0, errors.New("unsupported coupon type")
+	}
+	return minInt(discountCents, eligibleCents), nil
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"strings"
 	"time"
 
@@ -23,6 +22,7 @@ const (
 	PayTypePropertyFee      = 2
 	TransactionTypeTopUp    = 3
 	TransactionTypeTransfer = 4
+	TransactionTypeAdjust   = 6
 	GreenPointsPerYuan      = 10
 	CentsPerGreenPoint      = 100 / GreenPointsPerYuan
 
@@ -31,13 +31,15 @@ const (
 )
 
 type MixedPaymentResult struct {
-	BusinessID           int64   `json:"business_id"`
-	PayType              int     `json:"pay_type"`
-	TotalAmount          float64 `json:"total_amount"`
-	UsedPoints           int     `json:"used_points"`
-	UsedBalance          float64 `json:"used_balance"`
-	RemainingGreenPoints int     `json:"remaining_green_points"`
-	RemainingBalance     float64 `json:"remaining_balance"`
+	BusinessID           int64       `json:"business_id"`
+	PayType              int         `json:"pay_type"`
+	TotalAmount          model.Money `json:"total_amount"`
+	UsedPoints           int         `json:"used_points"`
+	UsedBalance          model.Money `json:"used_balance"`
+	RemainingGreenPoints int         `json:"remaining_green_points"`
+	RemainingBalance     model.Money `json:"remaining_balance"`
+	ReceiptID            int64       `json:"receipt_id,omitempty"`
+	ReceiptNo            string      `json:"receipt_no,omitempty"`
 }
 
 func (s *FinanceService) UnifiedPay(userID int64, businessID int64, payType int, password string) (*MixedPaymentResult, error) {
@@ -46,25 +48,25 @@ func (s *FinanceService) UnifiedPay(userID int64, businessID int64, payType int,
 
 func (s *FinanceService) UnifiedPayWithAuth(userID int64, businessID int64, payType int, password string, authType string) (*MixedPaymentResult, error) {
 	var result *MixedPaymentResult
-	authType = strings.ToLower(strings.TrimSpace(authType))
-	if authType == "" {
-		authType = AuthTypePassword
-	}
-	if authType != AuthTypePassword && authType != AuthTypeFace {
-		return nil, errors.New("不支持的认证方式")
+	authType, err := normalizePaymentAuthType(authType)
+	if err != nil {
+		return nil, err
 	}
 
-	err := global.DB.Transaction(func(tx *gorm.DB) error {
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if payType == PayTypeOrder {
+			if err := lockOrderGroupBuy(tx, businessID); err != nil {
+				return err
+			}
+		}
+
 		var user model.SysUser
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
 			return errors.New("用户不存在")
 		}
 		if requiresPaymentPassword(payType, authType) {
-			if strings.TrimSpace(password) == "" {
-				return errors.New("请输入支付密码")
-			}
-			if !utils.CheckPasswordHash(password, user.Password) {
-				return errors.New("支付密码错误")
+			if err := checkPaymentPassword(&user, password); err != nil {
+				return err
 			}
 		}
 
@@ -82,6 +84,14 @@ func (s *FinanceService) UnifiedPayWithAuth(userID int64, businessID int64, payT
 		return nil, err
 	}
 
+	// 收据开具失败不影响支付结果，可由后台补开
+	if receipt, err := (&ReceiptService{}).IssueForPayment(userID, result); err != nil {
+		log.Printf("issue receipt failed, userID=%d businessID=%d payType=%d err=%v", userID, businessID, payType, err)
+	} else {
+		result.ReceiptID = receipt.ID
+		result.ReceiptNo = receipt.ReceiptNo
+	}
+
 	return result, nil
 }
 
@@ -92,26 +102,41 @@ func (s *FinanceService) payOrder(tx *gorm.DB, user *model.SysUser, orderID int6
 		First(&order).Error; err != nil {
 		return errors.New("未找到订单")
 	}
-	if order.Status != 0 {
+	if order.Status != model.OrderStatusPending {
 		return errors.New("订单状态不支持支付")
 	}
+	if time.Since(order.CreatedAt) > OrderPaymentTimeout() {
+		return errors.New("订单已超时，请重新下单")
+	}
 
-	paymentResult, err := s.consumeGreenPointsAndBalance(tx, user, order.TotalAmount, orderID, PayTypeOrder, "mall_consume", fmt.Sprintf("Pay order %s", order.OrderNo))
+	paymentResult, err := s.consumeGreenPointsAndBalance(tx, user, order.TotalAmount, orderID, PayTypeOrder, orderIncomeLeg(order.StoreID, 0), "mall_consume", fmt.Sprintf("Pay order %s", order.OrderNo))
 	if err != nil {
 		return err
 	}
 
 	now := time.Now()
-	if err := tx.Model(&model.Order{}).
-		Where("id = ?", order.ID).
-		Updates(map[string]interface{}{
-			"status":       1,
-			"used_points":  paymentResult.UsedPoints,
-			"used_balance": paymentResult.UsedBalance,
-			"paid_at":      &now,
-		}).Error; err != nil {
+	extra := map[string]interface{}{
+		"used_points":  paymentResult.UsedPoints,
+		"used_balance": paymentResult.UsedBalance,
+		"paid_at":      &now,
+	}
+	if order.DeliveryType == model.DeliveryTypePickup {
+		code, err := generatePickupCode(tx, order.StoreID)
+		if err != nil {
+			return err
+		}
+		deadline := now.Add(PickupDeadline())
+		extra["pickup_code"] = code
+		extra["pickup_deadline"] = &deadline
+	}
+	if err := transitOrderStatus(tx, &order, model.OrderStatusPaid, user.ID, OrderOperatorUser, "order paid", extra); err != nil {
 		return err
 	}
+	if order.GroupBuyID > 0 {
+		if err := onGroupBuyOrderPaid(tx, &order, now); err != nil {
+			return err
+		}
+	}
 
 	*result = paymentResult
 	return nil
@@ -128,7 +153,13 @@ func (s *FinanceService) payPropertyFee(tx *gorm.DB, user *model.SysUser, feeID
 		return errors.New("该物业费已缴纳")
 	}
 
-	paymentResult, err := s.consumeGreenPointsAndBalance(tx, user, fee.Amount, fee.ID, PayTypePropertyFee, "property_fee", fmt.Sprintf("Pay property fee %s", fee.Month))
+	// 逾期账单按缴费当天计算滞纳金，与本金一并计入物业收入
+	_, lateFee := propertyLateFee(&fee, time.Now())
+	remark := fmt.Sprintf("Pay property fee %s", fee.Month)
+	if lateFee > 0 {
+		remark += fmt.Sprintf(" (late fee %s)", lateFee.String())
+	}
+	paymentResult, err := s.consumeGreenPointsAndBalance(tx, user, fee.Amount+lateFee, fee.ID, PayTypePropertyFee, ledgerLeg{AccountType: model.LedgerAccountPropertyIncome}, "property_fee", remark)
 	if err != nil {
 		return err
 	}
@@ -141,6 +172,7 @@ func (s *FinanceService) payPropertyFee(tx *gorm.DB, user *model.SysUser, feeID
 			"pay_time":     &now,
 			"used_points":  paymentResult.UsedPoints,
 			"used_balance": paymentResult.UsedBalance,
+			"late_fee":     lateFee,
 		}).Error; err != nil {
 		return err
 	}
@@ -149,27 +181,33 @@ func (s *FinanceService) payPropertyFee(tx *gorm.DB, user *model.SysUser, feeID
 	return nil
 }
 
-func (s *FinanceService) consumeGreenPointsAndBalance(tx *gorm.DB, user *model.SysUser, amount float64, relatedID int64, payType int, action, remark string) (*MixedPaymentResult, error) {
-	totalCents := amountToCents(amount)
+// consumeGreenPointsAndBalance 先用积分抵扣再扣余额，全额计入 income 账户，积分抵扣部分由平台收入承担
+func (s *FinanceService) consumeGreenPointsAndBalance(tx *gorm.DB, user *model.SysUser, amount model.Money, relatedID int64, payType int, income ledgerLeg, action, remark string) (*MixedPaymentResult, error) {
+	totalCents := int(amount.Cents())
 	maxPointDeduction := totalCents / CentsPerGreenPoint
 	pointsToUse := minInt(user.GreenPoints, maxPointDeduction)
 	balanceCentsToUse := totalCents - pointsToUse*CentsPerGreenPoint
 
-	if amountToCents(user.Balance) < balanceCentsToUse {
+	if int(user.Balance.Cents()) < balanceCentsToUse {
 		return nil, errors.New("余额不足")
 	}
 
-	updates := map[string]interface{}{}
-	if pointsToUse > 0 {
-		updates["green_points"] = gorm.Expr("green_points - ?", pointsToUse)
+	bizType := LedgerBizOrderPay
+	if payType == PayTypePropertyFee {
+		bizType = LedgerBizPropertyFee
 	}
-	if balanceCentsToUse > 0 {
-		updates["balance"] = gorm.Expr("balance - ?", centsToAmount(balanceCentsToUse))
+	if err := consumePointBatches(tx, user, pointsToUse, time.Now()); err != nil {
+		return nil, err
 	}
-	if len(updates) > 0 {
-		if err := tx.Model(&model.SysUser{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
-			return nil, err
-		}
+	income.Amount = int64(totalCents)
+	if _, err := postLedgerEntry(tx, bizType, relatedID, remark,
+		ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: user.ID, Amount: -int64(balanceCentsToUse)},
+		ledgerLeg{AccountType: model.LedgerAccountPlatformRevenue, Amount: -int64(pointsToUse * CentsPerGreenPoint)},
+		income,
+		ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: user.ID, Amount: -int64(pointsToUse)},
+		ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: int64(pointsToUse)},
+	); err != nil {
+		return nil, err
 	}
 
 	if pointsToUse > 0 {
@@ -188,7 +226,7 @@ func (s *FinanceService) consumeGreenPointsAndBalance(tx *gorm.DB, user *model.S
 		transaction := model.SysTransaction{
 			UserID:    user.ID,
 			Type:      payType,
-			Amount:    -centsToAmount(balanceCentsToUse),
+			Amount:    -model.Money(balanceCentsToUse),
 			RelatedID: relatedID,
 			Remark:    remark,
 			CreatedAt: time.Now(),
@@ -199,14 +237,14 @@ func (s *FinanceService) consumeGreenPointsAndBalance(tx *gorm.DB, user *model.S
 	}
 
 	user.GreenPoints -= pointsToUse
-	user.Balance = centsToAmount(amountToCents(user.Balance) - balanceCentsToUse)
+	user.Balance -= model.Money(balanceCentsToUse)
 
 	return &MixedPaymentResult{
 		BusinessID:           relatedID,
 		PayType:              payType,
-		TotalAmount:          centsToAmount(totalCents),
+		TotalAmount:          model.Money(totalCents),
 		UsedPoints:           pointsToUse,
-		UsedBalance:          centsToAmount(balanceCentsToUse),
+		UsedBalance:          model.Money(balanceCentsToUse),
 		RemainingGreenPoints: user.GreenPoints,
 		RemainingBalance:     user.Balance,
 	}, nil
@@ -220,83 +258,18 @@ func (s *FinanceService) GetPropertyFeeList(userID int64, page, size int) ([]mod
 
 	offset := (page - 1) * size
 	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	fillPropertyFeeDue(list)
 	return list, total, err
 }
 
-func (s *FinanceService) Recharge(userID int64, amount float64) error {
-	if amount <= 0 {
-		return errors.New("recharge amount must be greater than 0")
-	}
-
-	return global.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&model.SysUser{}).Where("id = ?", userID).
-			Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
-			return err
-		}
-
-		transaction := model.SysTransaction{
-			UserID:    userID,
-			Type:      TransactionTypeTopUp,
-			Amount:    amount,
-			Remark:    "Balance recharge",
-			CreatedAt: time.Now(),
-		}
-		return tx.Create(&transaction).Error
-	})
+// Recharge 发起充值，返回待支付的充值单，支付渠道通知成功后余额才到账
+func (s *FinanceService) Recharge(userID int64, amount model.Money, extra map[string]string) (*model.PaymentCharge, error) {
+	return (&PaymentService{}).CreateRecharge(userID, amount, extra)
 }
 
-func (s *FinanceService) Transfer(fromUserID int64, targetMobile string, amount float64) error {
-	if amount <= 0 {
-		return errors.New("transfer amount must be greater than 0")
-	}
-
-	return global.DB.Transaction(func(tx *gorm.DB) error {
-		var fromUser model.SysUser
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fromUser, fromUserID).Error; err != nil {
-			return errors.New("payer not found")
-		}
-		if fromUser.Balance < amount {
-			return errors.New("insufficient balance")
-		}
-
-		var toUser model.SysUser
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("mobile = ?", targetMobile).
-			First(&toUser).Error; err != nil {
-			return errors.New("payee not found")
-		}
-		if toUser.ID == fromUserID {
-			return errors.New("cannot transfer to self")
-		}
-
-		if err := tx.Model(&model.SysUser{}).Where("id = ?", fromUserID).
-			Update("balance", gorm.Expr("balance - ?", amount)).Error; err != nil {
-			return err
-		}
-		if err := tx.Model(&model.SysUser{}).Where("id = ?", toUser.ID).
-			Update("balance", gorm.Expr("balance + ?", amount)).Error; err != nil {
-			return err
-		}
-
-		outgoing := model.SysTransaction{
-			UserID:    fromUserID,
-			Type:      TransactionTypeTransfer,
-			Amount:    -amount,
-			Remark:    "Transfer to " + toUser.Username,
-			CreatedAt: time.Now(),
-		}
-		incoming := model.SysTransaction{
-			UserID:    toUser.ID,
-			Type:      TransactionTypeTransfer,
-			Amount:    amount,
-			Remark:    "Transfer from " + fromUser.Username,
-			CreatedAt: time.Now(),
-		}
-		if err := tx.Create(&outgoing).Error; err != nil {
-			return err
-		}
-		return tx.Create(&incoming).Error
-	})
+// Transfer 发起转账，需先通过 PreviewTransfer 确认收款人，撤销期结束后才到账
+func (s *FinanceService) Transfer(fromUserID int64, req TransferRequest) (*model.Transfer, error) {
+	return (&TransferService{}).Create(fromUserID, req)
 }
 
 func (s *FinanceService) GetTransactionList(userID int64, page, size int) ([]model.SysTransaction, int64, error) {
@@ -319,9 +292,19 @@ func (s *FinanceService) CreatePropertyFee(fee *model.PropertyFee) error {
 		return errors.New("property fee for this month already exists")
 	}
 
+	if fee.DueDate == nil {
+		due, err := propertyMonthDueDate(fee.Month)
+		if err != nil {
+			return err
+		}
+		fee.DueDate = &due
+	}
 	fee.Status = 0
 	fee.UsedPoints = 0
 	fee.UsedBalance = 0
+	fee.LateFee = 0
+	fee.RemindStage = 0
+	fee.RemindedAt = nil
 	return global.DB.Create(fee).Error
 }
 
@@ -333,17 +316,10 @@ func (s *FinanceService) ListAllPropertyFees(page, size int) ([]model.PropertyFe
 
 	offset := (page - 1) * size
 	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	fillPropertyFeeDue(list)
 	return list, total, err
 }
 
-func amountToCents(amount float64) int {
-	return int(math.Round(amount * 100))
-}
-
-func centsToAmount(cents int) float64 {
-	return float64(cents) / 100
-}
-
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -351,6 +327,28 @@ func minInt(a, b int) int {
 	return b
 }
 
+// normalizePaymentAuthType 校验认证方式，未指定时默认支付密码
+func normalizePaymentAuthType(authType string) (string, error) {
+	authType = strings.ToLower(strings.TrimSpace(authType))
+	if authType == "" {
+		authType = AuthTypePassword
+	}
+	if authType != AuthTypePassword && authType != AuthTypeFace {
+		return "", errors.New("不支持的认证方式")
+	}
+	return authType, nil
+}
+
+func checkPaymentPassword(user *model.SysUser, password string) error {
+	if strings.TrimSpace(password) == "" {
+		return errors.New("请输入支付密码")
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return errors.New("支付密码错误")
+	}
+	return nil
+}
+
 func requiresPaymentPassword(payType int, authType string) bool {
 	if payType != PayTypeOrder && payType != PayTypePropertyFee {
 		return false
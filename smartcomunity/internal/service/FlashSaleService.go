@@ -0,0 +1,557 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	flashSaleQueueKey      = "flash_sale:queue"      // 待落库的预占记录
+	flashSaleProcessingKey = "flash_sale:processing" // 正在落库的预占记录，异常退出后重新入队
+	flashSaleResultTTL     = time.Hour
+)
+
+// flashSaleReserveScript 原子地校验库存与限购、预占库存并写入落库队列
+// 返回值: 1 成功, -1 活动未开始或已结束, -2 超出限购, -3 库存不足
+var flashSaleReserveScript = redis.NewScript(`
+local stock = tonumber(redis.call('GET', KEYS[1]))
+if not stock then
+	return -1
+end
+local qty = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+if limit > 0 then
+	local bought = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+	if bought + qty > limit then
+		return -2
+	end
+end
+if stock < qty then
+	return -3
+end
+redis.call('DECRBY', KEYS[1], qty)
+redis.call('HINCRBY', KEYS[2], ARGV[1], qty)
+redis.call('LPUSH', KEYS[3], ARGV[4])
+return 1
+`)
+
+type FlashSaleService struct{}
+
+// flashReservation 秒杀预占记录，由 worker 异步落库为订单
+type flashReservation struct {
+	OrderNo     string    `json:"order_no"`
+	FlashSaleID int64     `json:"flash_sale_id"`
+	UserID      int64     `json:"user_id"`
+	Quantity    int       `json:"quantity"`
+	ReservedAt  time.Time `json:"reserved_at"`
+}
+
+// FlashSaleResult 秒杀下单结果
+type FlashSaleResult struct {
+	OrderNo string       `json:"order_no"`
+	Status  string       `json:"status"` // queued / created / failed
+	Reason  string       `json:"reason,omitempty"`
+	Order   *model.Order `json:"order,omitempty"`
+}
+
+func flashSaleStockKey(id int64) string {
+	return fmt.Sprintf("flash_sale:stock:%d", id)
+}
+
+func flashSaleUserKey(id int64) string {
+	return fmt.Sprintf("flash_sale:user:%d", id)
+}
+
+func flashSaleResultKey(orderNo string) string {
+	return fmt.Sprintf("flash_sale:result:%s", orderNo)
+}
+
+// CreateFlashSale 创建秒杀活动：从商品库存划拨秒杀库存并预热到 Redis (Admin)
+func (s *FlashSaleService) CreateFlashSale(operatorID int64, sale *model.FlashSale) error {
+	if sale.ProductID == 0 {
+		return errors.New("请选择秒杀商品")
+	}
+	if sale.Price <= 0 {
+		return errors.New("秒杀价必须大于0")
+	}
+	if sale.Stock <= 0 {
+		return errors.New("秒杀库存必须大于0")
+	}
+	if sale.PerUserLimit < 0 {
+		return errors.New("限购数量不能为负数")
+	}
+	if !sale.EndAt.After(sale.StartAt) || !sale.EndAt.After(time.Now()) {
+		return errors.New("活动结束时间无效")
+	}
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		// 门店启用独立库存时从门店库存划拨，否则从商品总库存划拨
+		storeStock, err := storeManagesStock(tx, sale.StoreID)
+		if err != nil {
+			return err
+		}
+		if !storeStock {
+			result := tx.Model(&model.Product{}).
+				Where("id = ? AND stock >= ?", sale.ProductID, sale.Stock).
+				Update("stock", gorm.Expr("stock - ?", sale.Stock))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("商品不存在或库存不足")
+			}
+		}
+
+		sale.ID = 0
+		sale.StoreStock = storeStock
+		sale.SoldCount = 0
+		sale.ReturnedStock = 0
+		sale.Status = model.FlashSaleStatusActive
+		sale.EndedAt = nil
+		sale.CreatedAt = time.Now()
+		if err := tx.Create(sale).Error; err != nil {
+			return err
+		}
+
+		if storeStock {
+			return decreaseStoreStock(tx, sale.StoreID, sale.ProductID, sale.Stock, model.StockMovementFlashSale, sale.ID, operatorID, sale.Title)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 事务提交后再预热，避免 Redis 已可售而活动回滚；预热失败时结束活动并归还库存
+	ctx := context.Background()
+	global.RDB.Del(ctx, flashSaleUserKey(sale.ID))
+	if err := global.RDB.Set(ctx, flashSaleStockKey(sale.ID), sale.Stock, 0).Err(); err != nil {
+		log.Printf("warm flash sale stock failed, saleID=%d err=%v", sale.ID, err)
+		if _, endErr := s.EndFlashSale(operatorID, sale.ID); endErr != nil {
+			log.Printf("end unwarmed flash sale failed, saleID=%d err=%v", sale.ID, endErr)
+		}
+		return errors.New("秒杀库存预热失败")
+	}
+	return nil
+}
+
+// ListFlashSales 秒杀活动列表 (Admin)
+func (s *FlashSaleService) ListFlashSales(page, size int) ([]model.FlashSale, int64, error) {
+	var list []model.FlashSale
+	var total int64
+	db := global.DB.Model(&model.FlashSale{})
+	db.Count(&total)
+	err := db.Preload("Product").Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	fillFlashSaleRemaining(list)
+	return list, total, nil
+}
+
+// ListActiveFlashSales 进行中和即将开始的秒杀活动
+func (s *FlashSaleService) ListActiveFlashSales() ([]model.FlashSale, error) {
+	var list []model.FlashSale
+	err := global.DB.Preload("Product").
+		Where("status = ? AND end_at > ?", model.FlashSaleStatusActive, time.Now()).
+		Order("start_at asc").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	fillFlashSaleRemaining(list)
+	return list, nil
+}
+
+// fillFlashSaleRemaining 从 Redis 读取进行中活动的剩余库存
+func fillFlashSaleRemaining(list []model.FlashSale) {
+	ctx := context.Background()
+	for i := range list {
+		remaining := 0
+		if list[i].Status == model.FlashSaleStatusActive {
+			if n, err := global.RDB.Get(ctx, flashSaleStockKey(list[i].ID)).Int(); err == nil {
+				remaining = n
+			}
+		}
+		list[i].Remaining = &remaining
+	}
+}
+
+// Buy 秒杀下单：在 Redis 中预占库存后立即返回订单号，订单由 worker 异步落库
+func (s *FlashSaleService) Buy(userID, flashSaleID int64, quantity int) (string, error) {
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	var sale model.FlashSale
+	if err := global.DB.First(&sale, flashSaleID).Error; err != nil {
+		return "", errors.New("秒杀活动不存在")
+	}
+	now := time.Now()
+	if sale.Status != model.FlashSaleStatusActive || !now.Before(sale.EndAt) {
+		return "", errors.New("秒杀活动已结束")
+	}
+	if now.Before(sale.StartAt) {
+		return "", errors.New("秒杀活动尚未开始")
+	}
+
+	reservation := flashReservation{
+		OrderNo:     fmt.Sprintf("%d%d", now.UnixNano(), userID),
+		FlashSaleID: sale.ID,
+		UserID:      userID,
+		Quantity:    quantity,
+		ReservedAt:  now,
+	}
+	payload, err := json.Marshal(reservation)
+	if err != nil {
+		return "", err
+	}
+
+	keys := []string{flashSaleStockKey(sale.ID), flashSaleUserKey(sale.ID), flashSaleQueueKey}
+	code, err := flashSaleReserveScript.Run(context.Background(), global.RDB, keys, userID, quantity, sale.PerUserLimit, string(payload)).Int()
+	if err != nil {
+		log.Printf("flash sale reserve failed, saleID=%d userID=%d err=%v", sale.ID, userID, err)
+		return "", errors.New("系统繁忙，请稍后再试")
+	}
+	switch code {
+	case 1:
+		return reservation.OrderNo, nil
+	case -2:
+		return "", errors.New("超出限购数量")
+	case -3:
+		return "", errors.New("已抢光")
+	default:
+		return "", errors.New("秒杀活动已结束")
+	}
+}
+
+// GetBuyResult 查询秒杀订单的落库结果
+func (s *FlashSaleService) GetBuyResult(userID int64, orderNo string) (*FlashSaleResult, error) {
+	if orderNo == "" {
+		return nil, errors.New("缺少订单号")
+	}
+	result := &FlashSaleResult{OrderNo: orderNo, Status: "queued"}
+
+	var order model.Order
+	err := global.DB.Preload("Items.Product").
+		Where("order_no = ? AND user_id = ?", orderNo, userID).
+		First(&order).Error
+	if err == nil {
+		result.Status = "created"
+		result.Order = &order
+		return result, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if reason, err := global.RDB.Get(context.Background(), flashSaleResultKey(orderNo)).Result(); err == nil {
+		result.Status = "failed"
+		result.Reason = reason
+	}
+	return result, nil
+}
+
+// EndFlashSale 提前结束秒杀活动并归还剩余库存 (Admin)
+func (s *FlashSaleService) EndFlashSale(operatorID, flashSaleID int64) (*model.FlashSale, error) {
+	var sale model.FlashSale
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sale, flashSaleID).Error; err != nil {
+			return errors.New("秒杀活动不存在")
+		}
+		if sale.Status != model.FlashSaleStatusActive {
+			return errors.New("秒杀活动已结束")
+		}
+		return endFlashSale(tx, &sale, operatorID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// EndExpiredFlashSales 结束已过期的秒杀活动并对账归还库存，返回处理数量
+func (s *FlashSaleService) EndExpiredFlashSales() (int, error) {
+	var ids []int64
+	if err := global.DB.Model(&model.FlashSale{}).
+		Where("status = ? AND end_at <= ?", model.FlashSaleStatusActive, time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	ended := 0
+	for _, id := range ids {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var sale model.FlashSale
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sale, id).Error; err != nil {
+				return err
+			}
+			if sale.Status != model.FlashSaleStatusActive {
+				return nil
+			}
+			if err := endFlashSale(tx, &sale, 0); err != nil {
+				return err
+			}
+			ended++
+			return nil
+		})
+		if err != nil {
+			log.Printf("end flash sale failed, saleID=%d err=%v", id, err)
+		}
+	}
+	return ended, nil
+}
+
+// endFlashSale 取走 Redis 中的剩余库存并归还商品，调用方需持有活动行锁
+func endFlashSale(tx *gorm.DB, sale *model.FlashSale, operatorID int64) error {
+	ctx := context.Background()
+	remaining, err := global.RDB.GetDel(ctx, flashSaleStockKey(sale.ID)).Int()
+	if errors.Is(err, redis.Nil) {
+		// Redis 库存丢失时以已落库销量为准，队列中尚未落库的预占仍会生成订单，不能归还
+		pending, _, err := pendingFlashReservations(tx, sale.ID)
+		if err != nil {
+			return err
+		}
+		remaining = sale.Stock - sale.SoldCount - pending
+	} else if err != nil {
+		return err
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	if remaining > 0 {
+		if err := returnProductStock(tx, sale.StoreStock, sale.StoreID, sale.ProductID, remaining, model.StockMovementFlashReturn, sale.ID, operatorID, sale.Title); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	sale.Status = model.FlashSaleStatusEnded
+	sale.ReturnedStock = remaining
+	sale.EndedAt = &now
+	if err := tx.Model(&model.FlashSale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"status":         sale.Status,
+		"returned_stock": remaining,
+		"ended_at":       &now,
+	}).Error; err != nil {
+		return err
+	}
+	global.RDB.Del(ctx, flashSaleUserKey(sale.ID))
+	return nil
+}
+
+// persistFlashReservation 将预占记录落库为待支付订单，重复消费时直接跳过
+func persistFlashReservation(r *flashReservation) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var exists int64
+		if err := tx.Model(&model.Order{}).Where("order_no = ?", r.OrderNo).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return nil
+		}
+
+		var sale model.FlashSale
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sale, r.FlashSaleID).Error; err != nil {
+			return errors.New("秒杀活动不存在")
+		}
+		var product model.Product
+		if err := tx.First(&product, sale.ProductID).Error; err != nil {
+			return errors.New("商品不存在")
+		}
+
+		amountCents := int(sale.Price.Cents()) * r.Quantity
+		order := &model.Order{
+			OrderNo:     r.OrderNo,
+			UserID:      r.UserID,
+			StoreID:     sale.StoreID,
+			TotalAmount: model.Money(amountCents),
+			FlashSaleID: sale.ID,
+			Status:      model.OrderStatusPending,
+			Items: []model.OrderItem{{
+				ProductID:      sale.ProductID,
+				Price:          product.Price,
+				Quantity:       r.Quantity,
+				Amount:         model.Money(amountCents),
+				PromotionTitle: sale.Title,
+				StoreStock:     sale.StoreStock,
+			}},
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if err := writeOrderStatusLog(tx, order.ID, model.OrderStatusPending, model.OrderStatusPending, r.UserID, OrderOperatorUser, "flash sale order created"); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.FlashSale{}).Where("id = ?", sale.ID).
+			Update("sold_count", gorm.Expr("sold_count + ?", r.Quantity)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Product{}).Where("id = ?", sale.ProductID).
+			Update("sales", gorm.Expr("sales + ?", r.Quantity)).Error
+	})
+}
+
+// compensateFlashReservation 落库失败时归还预占：活动进行中退回 Redis，已结束则退回商品库存
+func compensateFlashReservation(r *flashReservation, reason string) {
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var sale model.FlashSale
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sale, r.FlashSaleID).Error; err != nil {
+			return err
+		}
+		if sale.Status == model.FlashSaleStatusActive {
+			return restoreFlashSaleRedisStock(sale.ID, r.UserID, r.Quantity)
+		}
+		return returnProductStock(tx, sale.StoreStock, sale.StoreID, sale.ProductID, r.Quantity, model.StockMovementFlashReturn, sale.ID, 0, r.OrderNo)
+	})
+	if err != nil {
+		log.Printf("compensate flash reservation failed, orderNo=%s err=%v", r.OrderNo, err)
+	}
+	global.RDB.Set(context.Background(), flashSaleResultKey(r.OrderNo), reason, flashSaleResultTTL)
+}
+
+// releaseFlashSaleStock 取消秒杀订单时归还库存；活动已结束时返回 false，由调用方按普通订单归还
+func releaseFlashSaleStock(tx *gorm.DB, order *model.Order, items []model.OrderItem) (bool, error) {
+	var sale model.FlashSale
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sale, order.FlashSaleID).Error; err != nil {
+		return false, err
+	}
+	if sale.Status != model.FlashSaleStatusActive {
+		return false, nil
+	}
+
+	quantity := 0
+	for _, item := range items {
+		quantity += item.Quantity
+		if err := tx.Model(&model.Product{}).Where("id = ?", item.ProductID).
+			Update("sales", gorm.Expr("GREATEST(sales - ?, 0)", item.Quantity)).Error; err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Model(&model.FlashSale{}).Where("id = ?", sale.ID).
+		Update("sold_count", gorm.Expr("GREATEST(sold_count - ?, 0)", quantity)).Error; err != nil {
+		return false, err
+	}
+	return true, restoreFlashSaleRedisStock(sale.ID, order.UserID, quantity)
+}
+
+func restoreFlashSaleRedisStock(flashSaleID, userID int64, quantity int) error {
+	ctx := context.Background()
+	pipe := global.RDB.TxPipeline()
+	pipe.IncrBy(ctx, flashSaleStockKey(flashSaleID), int64(quantity))
+	pipe.HIncrBy(ctx, flashSaleUserKey(flashSaleID), fmt.Sprint(userID), int64(-quantity))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// warmFlashSaleStock 服务启动时为缺失 Redis 数据的进行中活动重建库存与限购计数
+func warmFlashSaleStock() {
+	var list []model.FlashSale
+	if err := global.DB.Where("status = ?", model.FlashSaleStatusActive).Find(&list).Error; err != nil {
+		log.Printf("load active flash sales failed: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	for _, sale := range list {
+		pending, pendingByUser, err := pendingFlashReservations(global.DB, sale.ID)
+		if err != nil {
+			log.Printf("load pending flash reservations failed, saleID=%d err=%v", sale.ID, err)
+			continue
+		}
+		remaining := sale.Stock - sale.SoldCount - pending
+		if remaining < 0 {
+			remaining = 0
+		}
+		ok, err := global.RDB.SetNX(ctx, flashSaleStockKey(sale.ID), remaining, 0).Result()
+		if err != nil {
+			log.Printf("warm flash sale stock failed, saleID=%d err=%v", sale.ID, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		var bought []struct {
+			UserID   int64
+			Quantity int
+		}
+		if err := global.DB.Model(&model.OrderItem{}).
+			Select("oms_order.user_id AS user_id, SUM(oms_order_item.quantity) AS quantity").
+			Joins("JOIN oms_order ON oms_order.id = oms_order_item.order_id").
+			Where("oms_order.flash_sale_id = ? AND oms_order.status <> ?", sale.ID, model.OrderStatusCancelled).
+			Group("oms_order.user_id").
+			Scan(&bought).Error; err != nil {
+			log.Printf("rebuild flash sale user quota failed, saleID=%d err=%v", sale.ID, err)
+			continue
+		}
+		for _, b := range bought {
+			pendingByUser[b.UserID] += b.Quantity
+		}
+		for userID, quantity := range pendingByUser {
+			global.RDB.HSet(ctx, flashSaleUserKey(sale.ID), fmt.Sprint(userID), quantity)
+		}
+		log.Printf("flash sale %d stock rebuilt from database, remaining=%d", sale.ID, remaining)
+	}
+}
+
+// pendingFlashReservations 统计队列(含处理中)里该活动尚未落库的预占数量及按用户的汇总，已生成订单的记录不计入
+func pendingFlashReservations(db *gorm.DB, flashSaleID int64) (int, map[int64]int, error) {
+	ctx := context.Background()
+	var reservations []flashReservation
+	for _, key := range []string{flashSaleQueueKey, flashSaleProcessingKey} {
+		payloads, err := global.RDB.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, payload := range payloads {
+			var r flashReservation
+			if err := json.Unmarshal([]byte(payload), &r); err != nil || r.FlashSaleID != flashSaleID {
+				continue
+			}
+			reservations = append(reservations, r)
+		}
+	}
+
+	byUser := make(map[int64]int)
+	if len(reservations) == 0 {
+		return 0, byUser, nil
+	}
+	orderNos := make([]string, 0, len(reservations))
+	for _, r := range reservations {
+		orderNos = append(orderNos, r.OrderNo)
+	}
+	var persisted []string
+	if err := db.Model(&model.Order{}).Where("order_no IN ?", orderNos).Pluck("order_no", &persisted).Error; err != nil {
+		return 0, nil, err
+	}
+	skip := make(map[string]bool, len(persisted))
+	for _, no := range persisted {
+		skip[no] = true
+	}
+
+	total := 0
+	for _, r := range reservations {
+		// 处理中的记录重启时会被重新入队，同一订单号只计一次
+		if skip[r.OrderNo] {
+			continue
+		}
+		skip[r.OrderNo] = true
+		total += r.Quantity
+		byUser[r.UserID] += r.Quantity
+	}
+	return total, byUser, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"smartcommunity/internal/global"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const flashSaleReconcileInterval = time.Minute
+
+// StartFlashSaleWorker 异步将秒杀预占落库为订单，并定期结束过期活动、归还剩余库存
+func StartFlashSaleWorker() {
+	warmFlashSaleStock()
+	requeueFlashReservations()
+
+	go func() {
+		log.Printf("flash sale order worker started")
+		ctx := context.Background()
+		for {
+			payload, err := global.RDB.BLMove(ctx, flashSaleQueueKey, flashSaleProcessingKey, "RIGHT", "LEFT", 5*time.Second).Result()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				log.Printf("pop flash sale reservation failed: %v", err)
+				time.Sleep(time.Second)
+				continue
+			}
+
+			handleFlashReservation(payload)
+			global.RDB.LRem(ctx, flashSaleProcessingKey, 1, payload)
+		}
+	}()
+
+	go func() {
+		flashSaleService := &FlashSaleService{}
+		ticker := time.NewTicker(flashSaleReconcileInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := flashSaleService.EndExpiredFlashSales()
+			if err != nil {
+				log.Printf("end expired flash sales failed: %v", err)
+			} else if count > 0 {
+				log.Printf("ended %d flash sales and returned remaining stock", count)
+			}
+		}
+	}()
+}
+
+func handleFlashReservation(payload string) {
+	var r flashReservation
+	if err := json.Unmarshal([]byte(payload), &r); err != nil {
+		log.Printf("invalid flash sale reservation dropped: %s", payload)
+		return
+	}
+	if err := persistFlashReservation(&r); err != nil {
+		log.Printf("persist flash sale order failed, orderNo=%s err=%v", r.OrderNo, err)
+		compensateFlashReservation(&r, "下单失败，请重试")
+	}
+}
+
+// requeueFlashReservations 将上次退出时未处理完的预占记录重新放回队列
+func requeueFlashReservations() {
+	ctx := context.Background()
+	for {
+		_, err := global.RDB.LMove(ctx, flashSaleProcessingKey, flashSaleQueueKey, "LEFT", "RIGHT").Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) {
+				log.Printf("requeue flash sale reservations failed: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+	"smartcommunity/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultGarbageUploadsPerHour    = 10
+	defaultGarbageDuplicateDistance = 5
+	defaultGarbageDuplicateWindow   = 30
+	garbageMaxRewardPoints          = 50
+	// EXIF 时间允许比服务器时间晚一点，兼容设备时钟误差
+	garbageClockSkew = time.Hour
+
+	pointActionGarbage = "garbage_classification"
+)
+
+var ErrGarbageUploadTooFrequent = errors.New("uploads are too frequent, please try again later")
+
+var errGarbageImageNotStored = errors.New("image storage is unavailable, please try again later")
+
+func greenPointConfig() config.GreenPointConfig {
+	if config.Conf != nil {
+		return config.Conf.GreenPoint
+	}
+	return config.GreenPointConfig{}
+}
+
+// checkGarbageUploadRate 限制上传间隔和每小时次数，Redis 不可用时放行
+func checkGarbageUploadRate(userID int64) error {
+	cfg := greenPointConfig()
+	ctx := context.Background()
+
+	if cfg.UploadIntervalSeconds > 0 {
+		key := fmt.Sprintf("garbage:upload:interval:%d", userID)
+		ok, err := global.RDB.SetNX(ctx, key, 1, time.Duration(cfg.UploadIntervalSeconds)*time.Second).Result()
+		if err != nil {
+			log.Printf("garbage upload interval check failed: %v", err)
+		} else if !ok {
+			return ErrGarbageUploadTooFrequent
+		}
+	}
+
+	limit := cfg.UploadsPerHour
+	if limit <= 0 {
+		limit = defaultGarbageUploadsPerHour
+	}
+	key := fmt.Sprintf("garbage:upload:hour:%d:%s", userID, time.Now().Format("2006010215"))
+	count, err := global.RDB.Incr(ctx, key).Result()
+	if err != nil {
+		log.Printf("garbage upload rate check failed: %v", err)
+		return nil
+	}
+	if count == 1 {
+		global.RDB.Expire(ctx, key, time.Hour)
+	}
+	if count > int64(limit) {
+		return ErrGarbageUploadTooFrequent
+	}
+	return nil
+}
+
+func readMultipartFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	src, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+// inspectGarbageImage 计算图片指纹并检查拍摄时间，返回带风控标记的上传记录；查重在发放事务内进行
+func inspectGarbageImage(userID int64, data []byte, now time.Time) *model.GarbageUpload {
+	cfg := greenPointConfig()
+	sum := sha256.Sum256(data)
+	upload := &model.GarbageUpload{
+		UserID:    userID,
+		SHA256:    hex.EncodeToString(sum[:]),
+		CreatedAt: now,
+	}
+	var flags []string
+
+	if hash, err := utils.ImageDHash(data); err != nil {
+		flags = append(flags, model.GarbageFlagUndecodable)
+	} else {
+		upload.ImageHash = &hash
+	}
+
+	if takenAt, ok := utils.ExifDateTime(data, time.Local); ok {
+		upload.TakenAt = &takenAt
+		if takenAt.After(now.Add(garbageClockSkew)) {
+			flags = append(flags, model.GarbageFlagFuturePhoto)
+		} else if cfg.MaxPhotoAgeHours > 0 && now.Sub(takenAt) > time.Duration(cfg.MaxPhotoAgeHours)*time.Hour {
+			flags = append(flags, model.GarbageFlagStalePhoto)
+		}
+	} else if cfg.RequireExif {
+		flags = append(flags, model.GarbageFlagNoExif)
+	}
+
+	upload.Flags = strings.Join(flags, ",")
+	return upload
+}
+
+// findGarbageDuplicate 查找与本次上传相同或近似的历史图片，返回最早一张的 ID。
+// 近似比对 BIT_COUNT 无法走索引，只扫描查重窗口内的记录(created_at 有索引)
+func findGarbageDuplicate(tx *gorm.DB, upload *model.GarbageUpload, now time.Time) (int64, error) {
+	var duplicate model.GarbageUpload
+	err := tx.Select("id").Where("sha256 = ?", upload.SHA256).Order("id asc").First(&duplicate).Error
+	if err == nil {
+		return duplicate.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	if upload.ImageHash == nil {
+		return 0, nil
+	}
+
+	cfg := greenPointConfig()
+	distance := cfg.DuplicateDistance
+	if distance <= 0 {
+		distance = defaultGarbageDuplicateDistance
+	}
+	windowDays := cfg.DuplicateWindowDays
+	if windowDays <= 0 {
+		windowDays = defaultGarbageDuplicateWindow
+	}
+	err = tx.Select("id").
+		Where("created_at >= ? AND image_hash IS NOT NULL AND BIT_COUNT(image_hash ^ ?) <= ?", now.AddDate(0, 0, -windowDays), *upload.ImageHash, distance).
+		Order("id asc").First(&duplicate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return duplicate.ID, err
+}
+
+// checkGarbageDuplicate 在发放事务内查重并标记。先锁积分池账户把各用户的上传串行化，
+// 之后的一致性读能看到其他事务已提交的上传，避免同一张图被两人同时上传都通过
+func checkGarbageDuplicate(tx *gorm.DB, upload *model.GarbageUpload, now time.Time) error {
+	if _, err := lockLedgerAccount(tx, model.LedgerAccountPointsPool, 0); err != nil {
+		return err
+	}
+	duplicateOf, err := findGarbageDuplicate(tx, upload, now)
+	if err != nil || duplicateOf == 0 {
+		return err
+	}
+	upload.DuplicateOf = duplicateOf
+	flags := []string{model.GarbageFlagDuplicate}
+	if upload.Flags != "" {
+		flags = append(flags, upload.Flags)
+	}
+	upload.Flags = strings.Join(flags, ",")
+	return nil
+}
+
+// garbageRewardedToday 用户当天通过垃圾分类拍照获得的积分
+func garbageRewardedToday(tx *gorm.DB, userID int64, now time.Time) (int, error) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var total int
+	err := tx.Model(&model.GreenPointRecord{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("user_id = ? AND action = ? AND created_at >= ?", userID, pointActionGarbage, dayStart).
+		Scan(&total).Error
+	return total, err
+}
+
+// capGarbageReward 按每日上限削减本次积分，返回可发放的积分和是否被削减
+func capGarbageReward(tx *gorm.DB, userID int64, points int, now time.Time) (int, bool, error) {
+	limit := greenPointConfig().DailyRewardCap
+	if limit <= 0 {
+		return points, false, nil
+	}
+	rewarded, err := garbageRewardedToday(tx, userID, now)
+	if err != nil {
+		return 0, false, err
+	}
+	remaining := limit - rewarded
+	if remaining < 0 {
+		remaining = 0
+	}
+	if points > remaining {
+		return remaining, true, nil
+	}
+	return points, false, nil
+}
+
+// creditGarbagePoints 发放垃圾分类积分：记账、积分记录和积分批次，调用方需已锁定用户行
+func creditGarbagePoints(tx *gorm.DB, userID int64, points int, now time.Time) error {
+	if _, err := postLedgerEntry(tx, LedgerBizGreenReward, userID, "Garbage classification reward",
+		ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: -int64(points)},
+		ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: userID, Amount: int64(points)},
+	); err != nil {
+		return err
+	}
+	record := model.GreenPointRecord{
+		UserID:    userID,
+		Action:    pointActionGarbage,
+		Points:    points,
+		CreatedAt: now,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return err
+	}
+	return grantPointBatch(tx, userID, points, record.Action, record.ID, now)
+}
+
+// ListGarbageUploads 后台查看上传记录，status 为空时返回全部
+func (s *GreenPointService) ListGarbageUploads(status *int, userID int64, page, size int) ([]model.GarbageUpload, int64, error) {
+	var list []model.GarbageUpload
+	var total int64
+	db := global.DB.Model(&model.GarbageUpload{})
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	if userID > 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	db.Count(&total)
+	err := db.Preload("SysUser", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, real_name, mobile") }).
+		Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ReviewGarbageUpload 审核被拦截的上传，通过时发放积分(points<=0 时使用识别结果)，人工审核不受每日上限限制
+func (s *GreenPointService) ReviewGarbageUpload(reviewerID, id int64, approve bool, points int, note string) (*model.GarbageUpload, error) {
+	var upload model.GarbageUpload
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&upload, id).Error; err != nil {
+			return errors.New("upload not found")
+		}
+		if upload.Status != model.GarbageUploadPending {
+			return errors.New("upload has already been reviewed")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"reviewer_id": reviewerID,
+			"review_note": strings.TrimSpace(note),
+			"reviewed_at": &now,
+		}
+		if !approve {
+			upload.Status = model.GarbageUploadRejected
+			updates["status"] = upload.Status
+			if err := tx.Model(&upload).Updates(updates).Error; err != nil {
+				return err
+			}
+			return sendUserNotice(tx, upload.UserID, "垃圾分类审核未通过",
+				"您上传的垃圾分类照片未通过审核，本次不发放积分。"+note, "garbage_upload", upload.ID)
+		}
+
+		if points <= 0 {
+			points = upload.SuggestPoints
+		}
+		if points <= 0 || points > garbageMaxRewardPoints {
+			return fmt.Errorf("points must be between 1 and %d", garbageMaxRewardPoints)
+		}
+		var user model.SysUser
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, upload.UserID).Error; err != nil {
+			return errors.New("user not found")
+		}
+		if err := creditGarbagePoints(tx, user.ID, points, now); err != nil {
+			return err
+		}
+		upload.Status = model.GarbageUploadApproved
+		upload.AwardPoints = points
+		updates["status"] = upload.Status
+		updates["award_points"] = points
+		if err := tx.Model(&upload).Updates(updates).Error; err != nil {
+			return err
+		}
+		return sendUserNotice(tx, upload.UserID, "垃圾分类审核通过",
+			fmt.Sprintf("您上传的垃圾分类照片已通过审核，获得 %d 积分。", points), "garbage_upload", upload.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status == model.GarbageUploadApproved {
+		if err := adjustLeaderboardScore(upload.UserID, upload.AwardPoints); err != nil {
+			log.Printf("update leaderboard after garbage review failed: %v", err)
+		}
+	}
+	return &upload, nil
+}
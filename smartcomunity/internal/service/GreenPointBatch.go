@@ -0,0 +1,296 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPointExpireYears      = 1
+	defaultPointExpiringSoonDays = 30
+
+	pointSourceLegacy  = "legacy"
+	pointActionExpired = "points_expired"
+)
+
+// PointExpiryGroup 同一天过期的积分合计
+type PointExpiryGroup struct {
+	ExpireAt time.Time `json:"expire_at"`
+	Points   int       `json:"points"`
+}
+
+// PointExpiryView 用户积分有效期概览
+type PointExpiryView struct {
+	GreenPoints  int                `json:"green_points"`
+	ExpiringDays int                `json:"expiring_days"`
+	ExpiringSoon int                `json:"expiring_soon"` // ExpiringDays 天内将过期的积分
+	Schedule     []PointExpiryGroup `json:"schedule"`      // 按过期日期汇总的剩余积分
+}
+
+// pointExpireAt 积分在获得年份之后第 N 年的年底过期
+func pointExpireAt(earnedAt time.Time) time.Time {
+	years := defaultPointExpireYears
+	if config.Conf != nil && config.Conf.GreenPoint.ExpireYears > 0 {
+		years = config.Conf.GreenPoint.ExpireYears
+	}
+	return time.Date(earnedAt.Year()+years, 12, 31, 23, 59, 59, 0, earnedAt.Location())
+}
+
+func pointExpiringSoonDays() int {
+	if config.Conf != nil && config.Conf.GreenPoint.ExpiringSoonDays > 0 {
+		return config.Conf.GreenPoint.ExpiringSoonDays
+	}
+	return defaultPointExpiringSoonDays
+}
+
+// grantPointBatch 记录一笔新获得的积分
+func grantPointBatch(tx *gorm.DB, userID int64, points int, source string, sourceID int64, now time.Time) error {
+	if points <= 0 {
+		return nil
+	}
+	return tx.Create(&model.GreenPointBatch{
+		UserID:    userID,
+		Source:    source,
+		SourceID:  sourceID,
+		Points:    points,
+		Remaining: points,
+		ExpireAt:  pointExpireAt(now),
+		CreatedAt: now,
+	}).Error
+}
+
+// ensureLegacyPointBatch 启用有效期前的存量积分没有批次，按当前余额补一个批次，从补录时起算有效期
+// 调用方需已锁定用户行，balance 为用户当前积分。已过期但尚未清零的批次仍计入已跟踪积分，
+// 否则这部分积分会被当作存量重新补录，过期任务扣减后批次合计将大于余额
+func ensureLegacyPointBatch(tx *gorm.DB, userID int64, balance int, now time.Time) error {
+	var tracked int
+	if err := tx.Model(&model.GreenPointBatch{}).
+		Select("COALESCE(SUM(remaining), 0)").
+		Where("user_id = ? AND remaining > 0", userID).
+		Scan(&tracked).Error; err != nil {
+		return err
+	}
+	return grantPointBatch(tx, userID, balance-tracked, pointSourceLegacy, 0, now)
+}
+
+// consumePointBatches 按过期时间先到先用扣减积分批次，调用方需已锁定用户行
+func consumePointBatches(tx *gorm.DB, user *model.SysUser, points int, now time.Time) error {
+	if points <= 0 {
+		return nil
+	}
+	if err := ensureLegacyPointBatch(tx, user.ID, user.GreenPoints, now); err != nil {
+		return err
+	}
+	deducted, err := deductPointBatches(tx, user.ID, points, now)
+	if err != nil {
+		return err
+	}
+	if deducted < points {
+		return errors.New("积分不足")
+	}
+	return nil
+}
+
+// deductPointBatches 从最早过期的批次开始扣减，最多扣 points，返回实际扣减数
+func deductPointBatches(tx *gorm.DB, userID int64, points int, now time.Time) (int, error) {
+	var batches []model.GreenPointBatch
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND remaining > 0 AND expire_at > ?", userID, now).
+		Order("expire_at asc, id asc").
+		Find(&batches).Error; err != nil {
+		return 0, err
+	}
+
+	deducted := 0
+	for _, batch := range batches {
+		if deducted >= points {
+			break
+		}
+		take := minInt(batch.Remaining, points-deducted)
+		if err := tx.Model(&model.GreenPointBatch{}).Where("id = ?", batch.ID).
+			Update("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return deducted, err
+		}
+		deducted += take
+	}
+	return deducted, nil
+}
+
+// ExpiringSoon 用户积分的过期计划，days<=0 时使用配置的默认天数
+func (s *GreenPointService) ExpiringSoon(userID int64, days int) (*PointExpiryView, error) {
+	if days <= 0 {
+		days = pointExpiringSoonDays()
+	}
+	var user model.SysUser
+	if err := global.DB.Select("id, green_points").First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	now := time.Now()
+	var schedule []PointExpiryGroup
+	if err := global.DB.Model(&model.GreenPointBatch{}).
+		Select("expire_at, SUM(remaining) AS points").
+		Where("user_id = ? AND remaining > 0 AND expire_at > ?", userID, now).
+		Group("expire_at").Order("expire_at asc").
+		Scan(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	// 已过期待清零的批次不展示，但也不属于未补录的存量积分
+	var pendingExpired int
+	if err := global.DB.Model(&model.GreenPointBatch{}).
+		Select("COALESCE(SUM(remaining), 0)").
+		Where("user_id = ? AND remaining > 0 AND expire_at <= ?", userID, now).
+		Scan(&pendingExpired).Error; err != nil {
+		return nil, err
+	}
+
+	view := &PointExpiryView{GreenPoints: user.GreenPoints, ExpiringDays: days, Schedule: schedule}
+	tracked := pendingExpired
+	deadline := now.AddDate(0, 0, days)
+	for _, group := range schedule {
+		tracked += group.Points
+		if !group.ExpireAt.After(deadline) {
+			view.ExpiringSoon += group.Points
+		}
+	}
+	// 尚未补录批次的存量积分按今天获得计算有效期
+	if gap := user.GreenPoints - tracked; gap > 0 {
+		view.Schedule = mergePointExpiryGroup(view.Schedule, PointExpiryGroup{ExpireAt: pointExpireAt(now), Points: gap})
+	}
+	if view.Schedule == nil {
+		view.Schedule = []PointExpiryGroup{}
+	}
+	return view, nil
+}
+
+func mergePointExpiryGroup(schedule []PointExpiryGroup, group PointExpiryGroup) []PointExpiryGroup {
+	for i := range schedule {
+		if schedule[i].ExpireAt.Equal(group.ExpireAt) {
+			schedule[i].Points += group.Points
+			return schedule
+		}
+	}
+	schedule = append(schedule, group)
+	for i := len(schedule) - 1; i > 0 && schedule[i].ExpireAt.Before(schedule[i-1].ExpireAt); i-- {
+		schedule[i], schedule[i-1] = schedule[i-1], schedule[i]
+	}
+	return schedule
+}
+
+// ExpirePointBatches 清零已过期批次的剩余积分，写入负数积分记录，返回涉及的用户数和过期积分总数
+func (s *GreenPointService) ExpirePointBatches() (int, int, error) {
+	now := time.Now()
+	var userIDs []int64
+	if err := global.DB.Model(&model.GreenPointBatch{}).
+		Where("remaining > 0 AND expire_at <= ?", now).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, 0, err
+	}
+
+	users, total := 0, 0
+	for _, userID := range userIDs {
+		expired, err := expireUserPointBatches(userID, now)
+		if err != nil {
+			log.Printf("expire green points for user %d failed: %v", userID, err)
+			continue
+		}
+		if expired > 0 {
+			users++
+			total += expired
+		}
+	}
+
+	// 先清零过期批次再补录存量，避免把待清零的积分补成新批次
+	if err := backfillLegacyPointBatches(now); err != nil {
+		return users, total, err
+	}
+	return users, total, nil
+}
+
+func expireUserPointBatches(userID int64, now time.Time) (int, error) {
+	expired := 0
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var user model.SysUser
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		var batches []model.GreenPointBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND remaining > 0 AND expire_at <= ?", userID, now).
+			Find(&batches).Error; err != nil {
+			return err
+		}
+		ids := make([]int64, 0, len(batches))
+		for _, batch := range batches {
+			expired += batch.Remaining
+			ids = append(ids, batch.ID)
+		}
+		// 批次与余额不一致时不扣成负数，差异交由对账处理
+		expired = minInt(expired, user.GreenPoints)
+		if err := tx.Model(&model.GreenPointBatch{}).Where("id IN ?", ids).Update("remaining", 0).Error; err != nil {
+			return err
+		}
+		if expired <= 0 {
+			return nil
+		}
+
+		if _, err := postLedgerEntry(tx, LedgerBizPointExpire, userID, "Green points expired",
+			ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: userID, Amount: -int64(expired)},
+			ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: int64(expired)},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&model.GreenPointRecord{
+			UserID:    userID,
+			Action:    pointActionExpired,
+			Points:    -expired,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		return sendUserNotice(tx, userID, "积分过期提醒",
+			fmt.Sprintf("您有 %d 绿色积分已过期。", expired), "green_point", 0)
+	})
+	return expired, err
+}
+
+// backfillLegacyPointBatches 为存量积分尚未建立批次的用户补录批次
+func backfillLegacyPointBatches(now time.Time) error {
+	type gapRow struct {
+		UserID int64
+		Gap    int
+	}
+	var rows []gapRow
+	if err := global.DB.Table("sys_user u").
+		Select("u.id AS user_id, u.green_points - COALESCE(SUM(b.remaining), 0) AS gap").
+		Joins("LEFT JOIN green_point_batch b ON b.user_id = u.id AND b.remaining > 0").
+		Where("u.green_points > 0").
+		Group("u.id, u.green_points").
+		Having("u.green_points - COALESCE(SUM(b.remaining), 0) > 0").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var user model.SysUser
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, green_points").First(&user, row.UserID).Error; err != nil {
+				return err
+			}
+			return ensureLegacyPointBatch(tx, user.ID, user.GreenPoints, now)
+		})
+		if err != nil {
+			log.Printf("backfill legacy green point batch for user %d failed: %v", row.UserID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// 积分在年底 23:59:59 过期，次日凌晨统一清理
+const greenPointExpireHour = 1
+
+// StartGreenPointExpiryScheduler 每天凌晨清零已过期的积分批次
+func StartGreenPointExpiryScheduler() {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		log.Printf("load Asia/Shanghai location failed, fallback to local: %v", err)
+		location = time.Local
+	}
+
+	greenPointService := &GreenPointService{}
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			nextRun := time.Date(now.Year(), now.Month(), now.Day(), greenPointExpireHour, 0, 0, 0, location)
+			if !now.Before(nextRun) {
+				nextRun = nextRun.AddDate(0, 0, 1)
+			}
+
+			log.Printf("green point expiry scheduler armed, next run at %s", nextRun.Format("2006-01-02 15:04:05"))
+			timer := time.NewTimer(time.Until(nextRun))
+			<-timer.C
+
+			users, points, err := greenPointService.ExpirePointBatches()
+			if err != nil {
+				log.Printf("expire green points failed: %v", err)
+				continue
+			}
+			if points > 0 {
+				log.Printf("expired %d green points for %d users", points, users)
+			}
+		}
+	}()
+}
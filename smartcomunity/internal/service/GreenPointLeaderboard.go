@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 排行榜周期：all 为累计，week/month 按自然周、自然月滚动
+const (
+	LeaderboardPeriodAll   = "all"
+	LeaderboardPeriodWeek  = "week"
+	LeaderboardPeriodMonth = "month"
+)
+
+const (
+	weeklyGreenPointsLeaderboardKey  = "community_green_points_weekly_leaderboard"
+	monthlyGreenPointsLeaderboardKey = "community_green_points_monthly_leaderboard"
+	// 排行榜重建完成的标记，Redis 被清空后标记随之消失，由定时任务发现并重建
+	greenPointsLeaderboardBuiltKey = "community_green_points_leaderboard_built"
+	greenPointsLeaderboardLockKey  = "community_green_points_leaderboard_rebuilding"
+
+	// 周期榜在周期结束后保留一段时间，便于查看上期排名
+	weeklyLeaderboardTTL  = 35 * 24 * time.Hour
+	monthlyLeaderboardTTL = 100 * 24 * time.Hour
+)
+
+var leaderboardPeriods = []string{LeaderboardPeriodAll, LeaderboardPeriodWeek, LeaderboardPeriodMonth}
+
+var errLeaderboardPeriod = errors.New("unsupported leaderboard period")
+
+var (
+	leaderboardLocationOnce sync.Once
+	leaderboardLoc          *time.Location
+)
+
+// LeaderboardRank 用户在某个榜单中的名次，未上榜时 Rank 为 0
+type LeaderboardRank struct {
+	Period   string `json:"period"`
+	Building string `json:"building,omitempty"`
+	Rank     int64  `json:"rank"`
+	Points   int    `json:"points"`
+	Total    int64  `json:"total"` // 榜单总人数
+}
+
+func leaderboardLocation() *time.Location {
+	leaderboardLocationOnce.Do(func() {
+		location, err := time.LoadLocation("Asia/Shanghai")
+		if err != nil {
+			log.Printf("load Asia/Shanghai location failed, fallback to local: %v", err)
+			location = time.Local
+		}
+		leaderboardLoc = location
+	})
+	return leaderboardLoc
+}
+
+func normalizeLeaderboardPeriod(period string) (string, error) {
+	switch strings.TrimSpace(period) {
+	case "", LeaderboardPeriodAll:
+		return LeaderboardPeriodAll, nil
+	case LeaderboardPeriodWeek:
+		return LeaderboardPeriodWeek, nil
+	case LeaderboardPeriodMonth:
+		return LeaderboardPeriodMonth, nil
+	}
+	return "", errLeaderboardPeriod
+}
+
+// leaderboardPeriodStart 周期起点，周以周一为第一天；累计榜返回零值
+func leaderboardPeriodStart(period string, now time.Time) time.Time {
+	now = now.In(leaderboardLocation())
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case LeaderboardPeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case LeaderboardPeriodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Time{}
+}
+
+// leaderboardKey 榜单的 Redis key 和过期时间；周期榜的 key 带周期编号，跨周期自动切换到新榜
+func leaderboardKey(period, building string, now time.Time) (string, time.Duration) {
+	now = now.In(leaderboardLocation())
+	var key string
+	var ttl time.Duration
+	switch period {
+	case LeaderboardPeriodWeek:
+		year, week := now.ISOWeek()
+		key = fmt.Sprintf("%s:%dW%02d", weeklyGreenPointsLeaderboardKey, year, week)
+		ttl = weeklyLeaderboardTTL
+	case LeaderboardPeriodMonth:
+		key = fmt.Sprintf("%s:%s", monthlyGreenPointsLeaderboardKey, now.Format("200601"))
+		ttl = monthlyLeaderboardTTL
+	default:
+		key = communityGreenPointsLeaderboardKey
+	}
+	if building != "" {
+		key += ":building:" + building
+	}
+	return key, ttl
+}
+
+// userBuildings 用户名下房屋所在的楼栋
+func userBuildings(userID int64) ([]string, error) {
+	var buildings []string
+	err := global.DB.Model(&model.Household{}).
+		Where("user_id = ? AND building <> ''", userID).
+		Distinct().Pluck("building", &buildings).Error
+	return buildings, err
+}
+
+// GetScopedLeaderboard 周榜、月榜或楼栋榜的前 limit 名
+func (s *GreenPointService) GetScopedLeaderboard(period, building string, limit int64) ([]GreenPointLeaderboardItem, error) {
+	period, err := normalizeLeaderboardPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	building = strings.TrimSpace(building)
+	if period == LeaderboardPeriodAll && building == "" {
+		return s.GetLeaderboard(limit)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	s.ensureLeaderboards()
+
+	key, _ := leaderboardKey(period, building, time.Now())
+	items, err := global.RDB.ZRevRangeWithScores(context.Background(), key, 0, limit-1).Result()
+	if err != nil {
+		log.Printf("read leaderboard %s from redis failed: %v", key, err)
+		return nil, err
+	}
+	return enrichLeaderboardItems(items)
+}
+
+// MyLeaderboardRank 用户在指定榜单中的名次和积分，不在前 N 名也能查到
+func (s *GreenPointService) MyLeaderboardRank(userID int64, period, building string) (*LeaderboardRank, error) {
+	period, err := normalizeLeaderboardPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	building = strings.TrimSpace(building)
+	s.ensureLeaderboards()
+
+	ctx := context.Background()
+	key, _ := leaderboardKey(period, building, time.Now())
+	member := strconv.FormatInt(userID, 10)
+	result := &LeaderboardRank{Period: period, Building: building}
+
+	total, err := global.RDB.ZCard(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	result.Total = total
+
+	rank, err := global.RDB.ZRevRank(ctx, key, member).Result()
+	if errors.Is(err, redis.Nil) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	score, err := global.RDB.ZScore(ctx, key, member).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	result.Rank = rank + 1
+	result.Points = int(score)
+	return result, nil
+}
+
+// ensureLeaderboards Redis 中没有重建标记时(首次启动或被清空)从积分流水重建所有榜单
+func (s *GreenPointService) ensureLeaderboards() {
+	exists, err := global.RDB.Exists(context.Background(), greenPointsLeaderboardBuiltKey).Result()
+	if err != nil || exists > 0 {
+		return
+	}
+	if err := s.RebuildLeaderboards(); err != nil {
+		log.Printf("rebuild green point leaderboards failed: %v", err)
+	}
+}
+
+type leaderboardAggregateRow struct {
+	Building string `gorm:"column:building"`
+	UserID   int64  `gorm:"column:user_id"`
+	Points   int64  `gorm:"column:points"`
+}
+
+// RebuildLeaderboards 按 green_point_record 中垃圾分类获得的积分重建累计榜、当前周榜和月榜及各楼栋榜，
+// 口径与实时累加一致，不含退款、对账等退回的积分
+func (s *GreenPointService) RebuildLeaderboards() error {
+	ctx := context.Background()
+	locked, err := global.RDB.SetNX(ctx, greenPointsLeaderboardLockKey, 1, 10*time.Minute).Result()
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return errors.New("leaderboard rebuild is already running")
+	}
+	defer global.RDB.Del(ctx, greenPointsLeaderboardLockKey)
+
+	now := time.Now().In(leaderboardLocation())
+	for _, period := range leaderboardPeriods {
+		start := leaderboardPeriodStart(period, now)
+
+		var userRows []leaderboardAggregateRow
+		db := global.DB.Model(&model.GreenPointRecord{}).
+			Select("user_id, COALESCE(SUM(points), 0) AS points").
+			Where("action = ? AND points > 0", pointActionGarbage)
+		if !start.IsZero() {
+			db = db.Where("created_at >= ?", start)
+		}
+		if err := db.Group("user_id").Scan(&userRows).Error; err != nil {
+			return err
+		}
+		key, ttl := leaderboardKey(period, "", now)
+		if err := replaceLeaderboard(ctx, key, ttl, userRows); err != nil {
+			return err
+		}
+
+		var buildingRows []leaderboardAggregateRow
+		db = global.DB.Table("green_point_record AS r").
+			Select("h.building, r.user_id, COALESCE(SUM(r.points), 0) AS points").
+			Joins("JOIN (SELECT DISTINCT user_id, building FROM cms_household WHERE user_id > 0 AND building <> '') h ON h.user_id = r.user_id").
+			Where("r.action = ? AND r.points > 0", pointActionGarbage)
+		if !start.IsZero() {
+			db = db.Where("r.created_at >= ?", start)
+		}
+		if err := db.Group("h.building, r.user_id").Scan(&buildingRows).Error; err != nil {
+			return err
+		}
+		byBuilding := make(map[string][]leaderboardAggregateRow)
+		for _, row := range buildingRows {
+			byBuilding[row.Building] = append(byBuilding[row.Building], row)
+		}
+		rebuilt := make(map[string]bool, len(byBuilding))
+		for building, rows := range byBuilding {
+			key, ttl := leaderboardKey(period, building, now)
+			if err := replaceLeaderboard(ctx, key, ttl, rows); err != nil {
+				return err
+			}
+			rebuilt[key] = true
+		}
+
+		// 清理已没有积分的楼栋榜(如房屋解绑)
+		pattern, _ := leaderboardKey(period, "*", now)
+		iter := global.RDB.Scan(ctx, 0, pattern, 200).Iterator()
+		for iter.Next(ctx) {
+			if !rebuilt[iter.Val()] {
+				global.RDB.Del(ctx, iter.Val())
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+
+	return global.RDB.Set(ctx, greenPointsLeaderboardBuiltKey, now.Format(time.RFC3339), 0).Err()
+}
+
+// replaceLeaderboard 先写临时 key 再 RENAME，重建过程中读到的始终是完整榜单
+func replaceLeaderboard(ctx context.Context, key string, ttl time.Duration, rows []leaderboardAggregateRow) error {
+	if len(rows) == 0 {
+		return global.RDB.Del(ctx, key).Err()
+	}
+
+	tmpKey := key + ":rebuild"
+	pipe := global.RDB.TxPipeline()
+	pipe.Del(ctx, tmpKey)
+	const chunk = 500
+	for i := 0; i < len(rows); i += chunk {
+		end := i + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+		zs := make([]redis.Z, 0, end-i)
+		for _, row := range rows[i:end] {
+			zs = append(zs, redis.Z{Score: float64(row.Points), Member: strconv.FormatInt(row.UserID, 10)})
+		}
+		pipe.ZAdd(ctx, tmpKey, zs...)
+	}
+	pipe.Rename(ctx, tmpKey, key)
+	if ttl > 0 {
+		pipe.Expire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
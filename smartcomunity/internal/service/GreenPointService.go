@@ -33,6 +33,11 @@ type GarbageRewardResponse struct {
 	Points      int    `json:"points"`
 	Reason      string `json:"reason"`
 	GreenPoints int    `json:"green_points"`
+
+	UploadID int64    `json:"upload_id"`
+	Status   int      `json:"status"` // 0 待审核 1 已发放
+	Flags    []string `json:"flags,omitempty"`
+	Message  string   `json:"message,omitempty"`
 }
 
 type GreenPointLeaderboardItem struct {
@@ -46,6 +51,17 @@ type GreenPointLeaderboardItem struct {
 }
 
 func (s *GreenPointService) UploadGarbage(userID int64, fileHeader *multipart.FileHeader) (*GarbageRewardResponse, error) {
+	if err := checkGarbageUploadRate(userID); err != nil {
+		return nil, err
+	}
+
+	data, err := readMultipartFile(fileHeader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	upload := inspectGarbageImage(userID, data, now)
+
 	imageURL, objectKey, err := s.storageService.UploadMultipartFile(fileHeader, "green-points")
 	if err != nil {
 		// Development fallback: if MinIO is unreachable, use base64 data URL directly for vision model.
@@ -64,10 +80,16 @@ func (s *GreenPointService) UploadGarbage(userID int64, fileHeader *multipart.Fi
 		return nil, err
 	}
 
+	if objectKey != "" {
+		upload.ImageURL = imageURL
+	}
+	upload.ObjectKey = objectKey
+	upload.SuggestPoints = recognitionResult.Points
+	upload.Reason = recognitionResult.Reason
+
 	result := &GarbageRewardResponse{
 		ImageURL:  imageURL,
 		ObjectKey: objectKey,
-		Points:    recognitionResult.Points,
 		Reason:    recognitionResult.Reason,
 	}
 
@@ -76,24 +98,38 @@ func (s *GreenPointService) UploadGarbage(userID int64, fileHeader *multipart.Fi
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
 			return errors.New("user not found")
 		}
-
-		if err := tx.Model(&model.SysUser{}).
-			Where("id = ?", userID).
-			Update("green_points", gorm.Expr("green_points + ?", recognitionResult.Points)).Error; err != nil {
+		result.GreenPoints = user.GreenPoints
+		if err := checkGarbageDuplicate(tx, upload, now); err != nil {
 			return err
 		}
 
-		record := model.GreenPointRecord{
-			UserID:    userID,
-			Action:    "garbage_classification",
-			Points:    recognitionResult.Points,
-			CreatedAt: time.Now(),
+		// 命中风控的上传先入审核队列，审核通过后再发放积分；图片未能存储时审核人员无从判断，直接拒绝
+		if upload.Flags != "" {
+			if upload.ObjectKey == "" {
+				return errGarbageImageNotStored
+			}
+			upload.Status = model.GarbageUploadPending
+			return tx.Create(upload).Error
 		}
-		if err := tx.Create(&record).Error; err != nil {
+
+		points, capped, err := capGarbageReward(tx, userID, recognitionResult.Points, now)
+		if err != nil {
 			return err
 		}
-
-		result.GreenPoints = user.GreenPoints + recognitionResult.Points
+		if capped {
+			upload.Flags = model.GarbageFlagDailyCap
+		}
+		upload.Status = model.GarbageUploadApproved
+		upload.AwardPoints = points
+		if err := tx.Create(upload).Error; err != nil {
+			return err
+		}
+		if points > 0 {
+			if err := creditGarbagePoints(tx, userID, points, now); err != nil {
+				return err
+			}
+		}
+		result.GreenPoints += points
 		return nil
 	})
 	if err != nil {
@@ -101,7 +137,20 @@ func (s *GreenPointService) UploadGarbage(userID int64, fileHeader *multipart.Fi
 		return nil, err
 	}
 
-	if err := adjustLeaderboardScore(userID, recognitionResult.Points); err != nil {
+	result.UploadID = upload.ID
+	result.Status = upload.Status
+	result.Points = upload.AwardPoints
+	if upload.Flags != "" {
+		result.Flags = strings.Split(upload.Flags, ",")
+	}
+	switch {
+	case upload.Status == model.GarbageUploadPending:
+		result.Message = "Upload is under manual review, points will be credited after approval"
+	case upload.Flags == model.GarbageFlagDailyCap:
+		result.Message = "Daily reward limit reached"
+	}
+
+	if err := adjustLeaderboardScore(userID, upload.AwardPoints); err != nil {
 		log.Printf("update leaderboard after garbage reward failed: %v", err)
 	}
 
@@ -113,6 +162,8 @@ func (s *GreenPointService) GetLeaderboard(limit int64) ([]GreenPointLeaderboard
 		limit = 10
 	}
 
+	s.ensureLeaderboards()
+
 	ctx := context.Background()
 	items, err := global.RDB.ZRevRangeWithScores(ctx, communityGreenPointsLeaderboardKey, 0, limit-1).Result()
 	if err != nil {
@@ -131,16 +182,26 @@ func adjustLeaderboardScore(userID int64, delta int) error {
 	if delta == 0 {
 		return nil
 	}
-	ctx := context.Background()
-	return global.RDB.ZIncrBy(ctx, communityGreenPointsLeaderboardKey, float64(delta), strconv.FormatInt(userID, 10)).Err()
-}
+	buildings, err := userBuildings(userID)
+	if err != nil {
+		log.Printf("load buildings for leaderboard failed: %v", err)
+	}
 
-func syncLeaderboardScore(userID int64, score int) error {
 	ctx := context.Background()
-	return global.RDB.ZAdd(ctx, communityGreenPointsLeaderboardKey, redis.Z{
-		Score:  float64(score),
-		Member: strconv.FormatInt(userID, 10),
-	}).Err()
+	now := time.Now()
+	member := strconv.FormatInt(userID, 10)
+	pipe := global.RDB.Pipeline()
+	for _, period := range leaderboardPeriods {
+		for _, building := range append([]string{""}, buildings...) {
+			key, ttl := leaderboardKey(period, building, now)
+			pipe.ZIncrBy(ctx, key, float64(delta), member)
+			if ttl > 0 {
+				pipe.Expire(ctx, key, ttl)
+			}
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
 func warmUpLeaderboardFromMySQL(limit int64) ([]GreenPointLeaderboardItem, error) {
@@ -152,7 +213,7 @@ func warmUpLeaderboardFromMySQL(limit int64) ([]GreenPointLeaderboardItem, error
 	var aggregates []leaderboardAggregate
 	if err := global.DB.Model(&model.GreenPointRecord{}).
 		Select("user_id, COALESCE(SUM(points), 0) AS points").
-		Where("points > 0").
+		Where("action = ? AND points > 0", pointActionGarbage).
 		Group("user_id").
 		Order("points desc").
 		Limit(int(limit)).
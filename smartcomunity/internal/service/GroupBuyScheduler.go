@@ -0,0 +1,29 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+const groupBuyExpireScanInterval = time.Minute
+
+// StartGroupBuyScheduler 定期处理到期未成团的拼团并自动退款
+func StartGroupBuyScheduler() {
+	groupBuyService := &GroupBuyService{}
+
+	go func() {
+		log.Printf("group buy scheduler armed, interval=%s", groupBuyExpireScanInterval)
+		ticker := time.NewTicker(groupBuyExpireScanInterval)
+		defer ticker.Stop()
+
+		for {
+			count, err := groupBuyService.ExpireGroupBuys()
+			if err != nil {
+				log.Printf("expire group buys failed: %v", err)
+			} else if count > 0 {
+				log.Printf("%d group buys failed and were refunded", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	groupBuyMinTarget   = 2
+	groupBuyMaxDuration = 7 * 24 * time.Hour
+)
+
+type GroupBuyService struct{}
+
+// OpenGroup 团长发起拼团并自动参团，返回团信息和团长的待支付订单
+func (s *GroupBuyService) OpenGroup(leaderID, productID, storeID int64, targetCount int, deadline time.Time, quantity int) (*model.GroupBuy, *model.Order, error) {
+	if targetCount < groupBuyMinTarget {
+		return nil, nil, fmt.Errorf("成团人数至少为%d人", groupBuyMinTarget)
+	}
+	now := time.Now()
+	if !deadline.After(now) {
+		return nil, nil, errors.New("截止时间必须晚于当前时间")
+	}
+	if deadline.Sub(now) > groupBuyMaxDuration {
+		return nil, nil, errors.New("拼团时长不能超过7天")
+	}
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	var group *model.GroupBuy
+	var order *model.Order
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var product model.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return errors.New("商品不存在")
+		}
+
+		group = &model.GroupBuy{
+			GroupNo:     fmt.Sprintf("G%d%d", now.UnixNano(), leaderID),
+			LeaderID:    leaderID,
+			ProductID:   product.ID,
+			StoreID:     storeID,
+			Price:       product.Price,
+			TargetCount: targetCount,
+			Deadline:    deadline,
+			Status:      model.GroupBuyStatusOpen,
+			CreatedAt:   now,
+		}
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+
+		var err error
+		order, err = createGroupBuyOrder(tx, leaderID, group, &product, quantity, true)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return group, order, nil
+}
+
+// JoinGroup 参团并生成待支付订单，支付成功后计入成团人数
+func (s *GroupBuyService) JoinGroup(userID, groupID int64, quantity int) (*model.GroupBuy, *model.Order, error) {
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	var group model.GroupBuy
+	var order *model.Order
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, groupID).Error; err != nil {
+			return errors.New("拼团不存在")
+		}
+		if group.Status != model.GroupBuyStatusOpen || !time.Now().Before(group.Deadline) {
+			return errors.New("拼团已结束")
+		}
+
+		var joined int64
+		if err := tx.Model(&model.GroupBuyMember{}).
+			Where("group_buy_id = ? AND user_id = ? AND status IN ?", group.ID, userID, []int{model.GroupMemberPending, model.GroupMemberPaid}).
+			Count(&joined).Error; err != nil {
+			return err
+		}
+		if joined > 0 {
+			return errors.New("您已参加该拼团")
+		}
+
+		var product model.Product
+		if err := tx.First(&product, group.ProductID).Error; err != nil {
+			return errors.New("商品不存在")
+		}
+
+		var err error
+		order, err = createGroupBuyOrder(tx, userID, &group, &product, quantity, false)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &group, order, nil
+}
+
+// ListOpenGroups 可参加的拼团，productID 为 0 时返回全部
+func (s *GroupBuyService) ListOpenGroups(productID int64, page, size int) ([]model.GroupBuy, int64, error) {
+	var list []model.GroupBuy
+	var total int64
+
+	db := global.DB.Model(&model.GroupBuy{}).
+		Where("status = ? AND deadline > ?", model.GroupBuyStatusOpen, time.Now())
+	if productID > 0 {
+		db = db.Where("product_id = ?", productID)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("Product").Order("deadline asc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// GetGroupDetail 拼团详情及团员列表
+func (s *GroupBuyService) GetGroupDetail(groupID int64) (*model.GroupBuy, error) {
+	var group model.GroupBuy
+	err := global.DB.Preload("Product").
+		Preload("Members", "status IN ?", []int{model.GroupMemberPending, model.GroupMemberPaid}).
+		Preload("Members.SysUser", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, avatar") }).
+		First(&group, groupID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ListUserGroups 我参与的拼团
+func (s *GroupBuyService) ListUserGroups(userID int64, page, size int) ([]model.GroupBuy, int64, error) {
+	var list []model.GroupBuy
+	var total int64
+
+	db := global.DB.Model(&model.GroupBuy{}).
+		Where("id IN (?)", global.DB.Model(&model.GroupBuyMember{}).Select("group_buy_id").Where("user_id = ?", userID))
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("Product").
+		Preload("Members", "user_id = ?", userID).
+		Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ExpireGroupBuys 处理到期未成团的拼团：取消未支付订单，已支付订单原路退回积分与余额
+func (s *GroupBuyService) ExpireGroupBuys() (int, error) {
+	var ids []int64
+	if err := global.DB.Model(&model.GroupBuy{}).
+		Where("status = ? AND deadline <= ?", model.GroupBuyStatusOpen, time.Now()).
+		Order("id asc").
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, id := range ids {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var group model.GroupBuy
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, id).Error; err != nil {
+				return err
+			}
+			if group.Status != model.GroupBuyStatusOpen {
+				return nil
+			}
+			if err := failGroupBuy(tx, &group); err != nil {
+				return err
+			}
+			failed++
+			return nil
+		})
+		if err != nil {
+			log.Printf("expire group buy failed, groupID=%d err=%v", id, err)
+		}
+	}
+	return failed, nil
+}
+
+func createGroupBuyOrder(tx *gorm.DB, userID int64, group *model.GroupBuy, product *model.Product, quantity int, isLeader bool) (*model.Order, error) {
+	// 门店启用独立库存时只占用门店库存(订单创建后扣减)，商品总库存仅记销量
+	storeStock, err := storeManagesStock(tx, group.StoreID)
+	if err != nil {
+		return nil, err
+	}
+	if storeStock {
+		if err := tx.Model(&model.Product{}).Where("id = ?", product.ID).
+			Update("sales", gorm.Expr("sales + ?", quantity)).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		result := tx.Model(&model.Product{}).
+			Where("id = ? AND stock >= ?", product.ID, quantity).
+			Updates(map[string]interface{}{
+				"stock": gorm.Expr("stock - ?", quantity),
+				"sales": gorm.Expr("sales + ?", quantity),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("商品[%s]库存不足", product.Name)
+		}
+	}
+
+	now := time.Now()
+	amountCents := int(group.Price.Cents()) * quantity
+	order := &model.Order{
+		OrderNo:     fmt.Sprintf("%d%d", now.UnixNano(), userID),
+		UserID:      userID,
+		StoreID:     group.StoreID,
+		TotalAmount: model.Money(amountCents),
+		GroupBuyID:  group.ID,
+		Status:      model.OrderStatusPending,
+		Items: []model.OrderItem{{
+			ProductID:      product.ID,
+			Price:          product.Price,
+			Quantity:       quantity,
+			Amount:         model.Money(amountCents),
+			PromotionTitle: "拼团 " + group.GroupNo,
+			StoreStock:     storeStock,
+		}},
+		CreatedAt: now,
+	}
+	if err := tx.Create(order).Error; err != nil {
+		return nil, err
+	}
+	if err := writeOrderStatusLog(tx, order.ID, model.OrderStatusPending, model.OrderStatusPending, userID, OrderOperatorUser, "group buy order created"); err != nil {
+		return nil, err
+	}
+
+	if storeStock {
+		if err := decreaseStoreStock(tx, group.StoreID, product.ID, quantity, model.StockMovementOrder, order.ID, userID, order.OrderNo); err != nil {
+			return nil, err
+		}
+	}
+
+	member := model.GroupBuyMember{
+		GroupBuyID: group.ID,
+		UserID:     userID,
+		OrderID:    order.ID,
+		Quantity:   quantity,
+		IsLeader:   isLeader,
+		Status:     model.GroupMemberPending,
+		CreatedAt:  now,
+	}
+	if err := tx.Create(&member).Error; err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// lockOrderGroupBuy 拼团订单支付前先锁定团记录，与拼团失败处理(团→订单→用户)保持同样的加锁顺序，避免死锁
+func lockOrderGroupBuy(tx *gorm.DB, orderID int64) error {
+	var order model.Order
+	err := tx.Select("id", "group_buy_id").First(&order, orderID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && order.GroupBuyID == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var group model.GroupBuy
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&group, order.GroupBuyID).Error
+}
+
+// onGroupBuyOrderPaid 团购订单支付成功后更新团员状态，达到目标人数即成团
+func onGroupBuyOrderPaid(tx *gorm.DB, order *model.Order, paidAt time.Time) error {
+	var group model.GroupBuy
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&group, order.GroupBuyID).Error; err != nil {
+		return errors.New("拼团不存在")
+	}
+	if group.Status == model.GroupBuyStatusFailed {
+		return errors.New("拼团已结束")
+	}
+	if group.Status == model.GroupBuyStatusOpen && !paidAt.Before(group.Deadline) {
+		return errors.New("拼团已截止")
+	}
+
+	result := tx.Model(&model.GroupBuyMember{}).
+		Where("order_id = ? AND status = ?", order.ID, model.GroupMemberPending).
+		Updates(map[string]interface{}{
+			"status":  model.GroupMemberPaid,
+			"paid_at": &paidAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("参团记录不存在")
+	}
+
+	updates := map[string]interface{}{"paid_count": gorm.Expr("paid_count + 1")}
+	if group.Status == model.GroupBuyStatusOpen && group.PaidCount+1 >= group.TargetCount {
+		updates["status"] = model.GroupBuyStatusSuccess
+		updates["finished_at"] = &paidAt
+	}
+	return tx.Model(&model.GroupBuy{}).Where("id = ?", group.ID).Updates(updates).Error
+}
+
+// checkGroupBuySucceeded 团购订单在成团前不允许发货或申请售后，未成团时由系统统一退款
+func checkGroupBuySucceeded(tx *gorm.DB, order *model.Order) error {
+	if order.GroupBuyID == 0 {
+		return nil
+	}
+	var group model.GroupBuy
+	if err := tx.First(&group, order.GroupBuyID).Error; err != nil {
+		return errors.New("group buy not found")
+	}
+	if group.Status != model.GroupBuyStatusSuccess {
+		return errors.New("group buy has not reached its target yet")
+	}
+	return nil
+}
+
+// failGroupBuy 拼团失败，调用方需持有团记录行锁
+func failGroupBuy(tx *gorm.DB, group *model.GroupBuy) error {
+	var members []model.GroupBuyMember
+	if err := tx.Where("group_buy_id = ? AND status IN ?", group.ID, []int{model.GroupMemberPending, model.GroupMemberPaid}).
+		Find(&members).Error; err != nil {
+		return err
+	}
+
+	reason := "group buy " + group.GroupNo + " did not reach target"
+	for _, member := range members {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, member.OrderID).Error; err != nil {
+			return err
+		}
+
+		switch order.Status {
+		case model.OrderStatusPending:
+			// cancelPendingOrder 会同步把团员标记为已取消
+			if err := cancelPendingOrder(tx, &order, 0, OrderOperatorSystem, reason); err != nil {
+				return err
+			}
+		case model.OrderStatusPaid:
+			if err := systemRefundOrder(tx, &order, reason); err != nil {
+				return err
+			}
+			if err := tx.Model(&model.GroupBuyMember{}).Where("id = ?", member.ID).
+				Update("status", model.GroupMemberRefunded).Error; err != nil {
+				return err
+			}
+		default:
+			log.Printf("group buy %d member order %d in status %s skipped", group.ID, order.ID, OrderStatusName(order.Status))
+		}
+	}
+
+	now := time.Now()
+	return tx.Model(&model.GroupBuy{}).Where("id = ?", group.ID).Updates(map[string]interface{}{
+		"status":      model.GroupBuyStatusFailed,
+		"finished_at": &now,
+	}).Error
+}
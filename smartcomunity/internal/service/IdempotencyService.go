@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdempotencyInProgress = errors.New("请求正在处理中，请勿重复提交")
+	ErrIdempotencyMismatch   = errors.New("Idempotency-Key 已用于其他请求")
+)
+
+type IdempotencyService struct{}
+
+func idempotencyCacheKey(userID int64, scope, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s:%s", userID, scope, key)
+}
+
+// IdempotencyHash 计算请求指纹，同一个 Key 携带不同请求内容时拒绝
+func IdempotencyHash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin 占用幂等键。replay 为 true 时 record 中是首次请求的响应，调用方直接回放；
+// 否则调用方执行业务后必须调用 Complete 或 Release。
+// MySQL 唯一索引保证同一个 Key 只有一个请求在执行，Redis 缓存已完成的响应以便快速回放。
+func (s *IdempotencyService) Begin(userID int64, scope, key, requestHash string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	ctx := context.Background()
+	cacheKey := idempotencyCacheKey(userID, scope, key)
+	if cached, err := global.RDB.Get(ctx, cacheKey).Bytes(); err == nil {
+		var record model.IdempotencyRecord
+		if err := json.Unmarshal(cached, &record); err == nil {
+			if record.RequestHash != requestHash {
+				return nil, false, ErrIdempotencyMismatch
+			}
+			return &record, true, nil
+		}
+	}
+
+	now := time.Now()
+	// 过期记录不再生效，删除后允许复用同一个 Key
+	if err := global.DB.Where("user_id = ? AND scope = ? AND idem_key = ? AND expires_at <= ?", userID, scope, key, now).
+		Delete(&model.IdempotencyRecord{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record := &model.IdempotencyRecord{
+		UserID:      userID,
+		Scope:       scope,
+		IdemKey:     key,
+		RequestHash: requestHash,
+		Status:      model.IdempotencyStatusProcessing,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	result := global.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return record, false, nil
+	}
+
+	var existing model.IdempotencyRecord
+	if err := global.DB.Where("user_id = ? AND scope = ? AND idem_key = ?", userID, scope, key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyMismatch
+	}
+	if existing.Status != model.IdempotencyStatusCompleted {
+		return nil, false, ErrIdempotencyInProgress
+	}
+	s.cache(&existing)
+	return &existing, true, nil
+}
+
+// Complete 保存首次请求的响应
+func (s *IdempotencyService) Complete(record *model.IdempotencyRecord, httpStatus int, contentType string, body []byte) error {
+	record.Status = model.IdempotencyStatusCompleted
+	record.HTTPStatus = httpStatus
+	record.ContentType = contentType
+	record.ResponseBody = string(body)
+	record.UpdatedAt = time.Now()
+	if err := global.DB.Model(&model.IdempotencyRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"status":        record.Status,
+		"http_status":   httpStatus,
+		"content_type":  contentType,
+		"response_body": record.ResponseBody,
+		"updated_at":    record.UpdatedAt,
+	}).Error; err != nil {
+		return err
+	}
+	s.cache(record)
+	return nil
+}
+
+// Release 业务执行异常时释放幂等键，允许客户端用同一个 Key 重试
+func (s *IdempotencyService) Release(record *model.IdempotencyRecord) error {
+	return global.DB.Where("id = ? AND status = ?", record.ID, model.IdempotencyStatusProcessing).
+		Delete(&model.IdempotencyRecord{}).Error
+}
+
+func (s *IdempotencyService) cache(record *model.IdempotencyRecord) {
+	ttl := time.Until(record.ExpiresAt)
+	if ttl <= 0 {
+		return
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if err := global.RDB.Set(context.Background(), idempotencyCacheKey(record.UserID, record.Scope, record.IdemKey), payload, ttl).Err(); err != nil {
+		log.Printf("cache idempotency record failed, id=%d err=%v", record.ID, err)
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+const leaderboardCheckInterval = 5 * time.Minute
+
+// StartLeaderboardRebuildScheduler 定期检查排行榜是否存在，Redis 被清空后从积分流水重建
+func StartLeaderboardRebuildScheduler() {
+	greenPointService := &GreenPointService{}
+
+	go func() {
+		log.Printf("leaderboard rebuild scheduler armed, interval=%s", leaderboardCheckInterval)
+		ticker := time.NewTicker(leaderboardCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			greenPointService.ensureLeaderboards()
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 凭证业务类型
+const (
+	LedgerBizOpening     = "opening"
+	LedgerBizRecharge    = "recharge"
+	LedgerBizTransfer    = "transfer"
+	LedgerBizOrderPay    = "order_pay"
+	LedgerBizPropertyFee = "property_fee"
+	LedgerBizRefund      = "refund"
+	LedgerBizGreenReward = "green_reward"
+	LedgerBizAdjust      = "admin_adjust"
+	LedgerBizSettlement  = "settlement_paid"
+	LedgerBizReconcile   = "reconcile_correct"
+	LedgerBizPointExpire = "point_expire"
+	LedgerBizPointRedeem = "point_redeem"
+)
+
+type LedgerService struct{}
+
+// ledgerLeg 一条待记账的分录，Amount 为账户余额变动(分或积分)
+type ledgerLeg struct {
+	AccountType string
+	OwnerID     int64
+	Amount      int64
+}
+
+func ledgerAccountUnit(accountType string) string {
+	if accountType == model.LedgerAccountUserPoints || accountType == model.LedgerAccountPointsPool {
+		return model.LedgerUnitPoint
+	}
+	return model.LedgerUnitCNY
+}
+
+func ledgerAccountCode(accountType string, ownerID int64) string {
+	switch accountType {
+	case model.LedgerAccountUserWallet, model.LedgerAccountUserPoints, model.LedgerAccountStorePayable:
+		return fmt.Sprintf("%s:%d", accountType, ownerID)
+	default:
+		return accountType
+	}
+}
+
+func isUserLedgerAccount(accountType string) bool {
+	return accountType == model.LedgerAccountUserWallet || accountType == model.LedgerAccountUserPoints
+}
+
+// orderIncomeLeg 订单收入记入所属门店的应付账户，平台自营订单记入平台收入
+func orderIncomeLeg(storeID int64, cents int) ledgerLeg {
+	if storeID > 0 {
+		return ledgerLeg{AccountType: model.LedgerAccountStorePayable, OwnerID: storeID, Amount: int64(cents)}
+	}
+	return ledgerLeg{AccountType: model.LedgerAccountPlatformRevenue, Amount: int64(cents)}
+}
+
+// postLedgerEntry 在事务内记一笔平衡的凭证，并同步更新账户余额与用户的余额、积分
+func postLedgerEntry(tx *gorm.DB, bizType string, bizID int64, remark string, legs ...ledgerLeg) (*model.LedgerEntry, error) {
+	merged, err := mergeLedgerLegs(legs)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged) == 0 {
+		return nil, nil
+	}
+
+	// 按账户编码加锁，避免并发记账时互相等待
+	codes := make([]string, 0, len(merged))
+	for code := range merged {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	now := time.Now()
+	entry := &model.LedgerEntry{
+		EntryNo:   fmt.Sprintf("L%d%d", now.UnixNano(), bizID),
+		BizType:   bizType,
+		BizID:     bizID,
+		Remark:    remark,
+		CreatedAt: now,
+	}
+	accounts := make([]*model.LedgerAccount, 0, len(codes))
+	for _, code := range codes {
+		leg := merged[code]
+		account, err := lockLedgerAccount(tx, leg.AccountType, leg.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkLedgerAccountBalance(account, leg.Amount); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+		entry.Lines = append(entry.Lines, model.LedgerLine{
+			AccountID: account.ID,
+			Unit:      account.Unit,
+			Amount:    leg.Amount,
+			CreatedAt: now,
+		})
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return nil, err
+	}
+	for i, account := range accounts {
+		if err := applyLedgerLine(tx, account, entry.Lines[i].Amount); err != nil {
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// mergeLedgerLegs 合并同一账户的分录并按计量单位分别校验借贷平衡，返回按账户编码索引的非零分录
+func mergeLedgerLegs(legs []ledgerLeg) (map[string]*ledgerLeg, error) {
+	merged := map[string]*ledgerLeg{}
+	sums := map[string]int64{}
+	for _, leg := range legs {
+		if leg.Amount == 0 {
+			continue
+		}
+		code := ledgerAccountCode(leg.AccountType, leg.OwnerID)
+		if existing, ok := merged[code]; ok {
+			existing.Amount += leg.Amount
+		} else {
+			l := leg
+			merged[code] = &l
+		}
+		sums[ledgerAccountUnit(leg.AccountType)] += leg.Amount
+	}
+	for unit, sum := range sums {
+		if sum != 0 {
+			return nil, fmt.Errorf("凭证借贷不平衡: %s 差额 %d", unit, sum)
+		}
+	}
+	for code, leg := range merged {
+		if leg.Amount == 0 {
+			delete(merged, code)
+		}
+	}
+	return merged, nil
+}
+
+// checkLedgerAccountBalance 用户的余额、积分账户不允许记为负数
+func checkLedgerAccountBalance(account *model.LedgerAccount, amount int64) error {
+	if !isUserLedgerAccount(account.Type) || account.Balance+amount >= 0 {
+		return nil
+	}
+	if account.Type == model.LedgerAccountUserPoints {
+		return errors.New("积分不足")
+	}
+	return errors.New("余额不足")
+}
+
+// lockLedgerAccount 锁定账户，不存在时自动开户；用户账户开户时把现有余额记为期初凭证
+func lockLedgerAccount(tx *gorm.DB, accountType string, ownerID int64) (*model.LedgerAccount, error) {
+	code := ledgerAccountCode(accountType, ownerID)
+	now := time.Now()
+	created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.LedgerAccount{
+		Code:      code,
+		Type:      accountType,
+		OwnerID:   ownerID,
+		Unit:      ledgerAccountUnit(accountType),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if created.Error != nil {
+		return nil, created.Error
+	}
+
+	var account model.LedgerAccount
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&account).Error; err != nil {
+		return nil, err
+	}
+	if created.RowsAffected > 0 && isUserLedgerAccount(accountType) {
+		if err := openUserLedgerAccount(tx, &account); err != nil {
+			return nil, err
+		}
+	}
+	return &account, nil
+}
+
+// openUserLedgerAccount 接入账本前已有的用户余额、积分作为期初余额入账
+func openUserLedgerAccount(tx *gorm.DB, account *model.LedgerAccount) error {
+	var user model.SysUser
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "balance", "green_points").
+		First(&user, account.OwnerID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+
+	opening := user.Balance.Cents()
+	counterType := model.LedgerAccountCashClearing
+	if account.Type == model.LedgerAccountUserPoints {
+		opening = int64(user.GreenPoints)
+		counterType = model.LedgerAccountPointsPool
+	}
+	if opening == 0 {
+		return nil
+	}
+
+	counter, err := lockLedgerAccount(tx, counterType, 0)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := &model.LedgerEntry{
+		EntryNo:   fmt.Sprintf("L%d%d", now.UnixNano(), account.OwnerID),
+		BizType:   LedgerBizOpening,
+		BizID:     account.OwnerID,
+		Remark:    "期初余额",
+		CreatedAt: now,
+		Lines: []model.LedgerLine{
+			{AccountID: account.ID, Unit: account.Unit, Amount: opening, CreatedAt: now},
+			{AccountID: counter.ID, Unit: counter.Unit, Amount: -opening, CreatedAt: now},
+		},
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	if err := applyLedgerLine(tx, counter, -opening); err != nil {
+		return err
+	}
+	return applyLedgerLine(tx, account, opening)
+}
+
+// applyLedgerLine 更新账户余额，用户账户的余额同时回写到 sys_user，保证两者一致
+func applyLedgerLine(tx *gorm.DB, account *model.LedgerAccount, amount int64) error {
+	account.Balance += amount
+	if err := tx.Model(&model.LedgerAccount{}).Where("id = ?", account.ID).Updates(map[string]interface{}{
+		"balance":    gorm.Expr("balance + ?", amount),
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+
+	switch account.Type {
+	case model.LedgerAccountUserWallet:
+		return tx.Model(&model.SysUser{}).Where("id = ?", account.OwnerID).
+			Update("balance", model.Money(account.Balance)).Error
+	case model.LedgerAccountUserPoints:
+		return tx.Model(&model.SysUser{}).Where("id = ?", account.OwnerID).
+			Update("green_points", account.Balance).Error
+	}
+	return nil
+}
+
+// LedgerMismatch 账户余额与分录合计或用户缓存余额不一致的记录
+type LedgerMismatch struct {
+	AccountID int64  `json:"account_id"`
+	Code      string `json:"code"`
+	OwnerID   int64  `json:"owner_id"`
+	Balance   int64  `json:"balance"`
+	Expected  int64  `json:"expected"`
+	Source    string `json:"source"` // journal: 分录合计 sys_user: 用户表余额
+}
+
+type LedgerCheckReport struct {
+	UnbalancedEntries []int64          `json:"unbalanced_entries"`
+	Mismatches        []LedgerMismatch `json:"mismatches"`
+	Consistent        bool             `json:"consistent"`
+	CheckedAt         time.Time        `json:"checked_at"`
+}
+
+// CheckConsistency 校验所有凭证是否平衡、账户余额是否等于分录合计、用户余额是否等于账户余额
+func (s *LedgerService) CheckConsistency() (*LedgerCheckReport, error) {
+	report := &LedgerCheckReport{CheckedAt: time.Now()}
+
+	if err := global.DB.Model(&model.LedgerLine{}).
+		Distinct("entry_id").
+		Group("entry_id, unit").
+		Having("SUM(amount) <> 0").
+		Pluck("entry_id", &report.UnbalancedEntries).Error; err != nil {
+		return nil, err
+	}
+
+	var journal []LedgerMismatch
+	if err := global.DB.Table("sys_ledger_account a").
+		Select("a.id AS account_id, a.code, a.owner_id, a.balance, COALESCE(SUM(l.amount), 0) AS expected, 'journal' AS source").
+		Joins("LEFT JOIN sys_ledger_line l ON l.account_id = a.id").
+		Group("a.id, a.code, a.owner_id, a.balance").
+		Having("a.balance <> COALESCE(SUM(l.amount), 0)").
+		Scan(&journal).Error; err != nil {
+		return nil, err
+	}
+
+	var cached []LedgerMismatch
+	if err := global.DB.Table("sys_ledger_account a").
+		Select("a.id AS account_id, a.code, a.owner_id, a.balance, "+
+			"CASE WHEN a.type = ? THEN ROUND(u.balance * 100) ELSE u.green_points END AS expected, 'sys_user' AS source",
+			model.LedgerAccountUserWallet).
+		Joins("JOIN sys_user u ON u.id = a.owner_id").
+		Where("(a.type = ? AND a.balance <> ROUND(u.balance * 100)) OR (a.type = ? AND a.balance <> u.green_points)",
+			model.LedgerAccountUserWallet, model.LedgerAccountUserPoints).
+		Scan(&cached).Error; err != nil {
+		return nil, err
+	}
+
+	report.Mismatches = append(journal, cached...)
+	report.Consistent = len(report.UnbalancedEntries) == 0 && len(report.Mismatches) == 0
+	return report, nil
+}
+
+// ListAccounts 账户列表，accountType 为空时返回全部
+func (s *LedgerService) ListAccounts(accountType string, ownerID int64, page, size int) ([]model.LedgerAccount, int64, error) {
+	var list []model.LedgerAccount
+	var total int64
+
+	db := global.DB.Model(&model.LedgerAccount{})
+	if accountType != "" {
+		db = db.Where("type = ?", accountType)
+	}
+	if ownerID > 0 {
+		db = db.Where("owner_id = ?", ownerID)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id asc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ListEntries 凭证列表，accountID 大于 0 时只返回涉及该账户的凭证
+func (s *LedgerService) ListEntries(accountID int64, bizType string, page, size int) ([]model.LedgerEntry, int64, error) {
+	var list []model.LedgerEntry
+	var total int64
+
+	db := global.DB.Model(&model.LedgerEntry{})
+	if accountID > 0 {
+		db = db.Where("id IN (?)", global.DB.Model(&model.LedgerLine{}).Select("entry_id").Where("account_id = ?", accountID))
+	}
+	if bizType != "" {
+		db = db.Where("biz_type = ?", bizType)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("Lines").Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"smartcommunity/internal/model"
+)
+
+func TestPostLedgerEntryRejectsUnbalancedLegs(t *testing.T) {
+	tests := []struct {
+		name string
+		legs []ledgerLeg
+	}{
+		{name: "single leg", legs: []ledgerLeg{
+			{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: 100},
+		}},
+		{name: "cny off by one cent", legs: []ledgerLeg{
+			{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: -100},
+			{AccountType: model.LedgerAccountPlatformRevenue, Amount: 99},
+		}},
+		{name: "points unbalanced while cny balanced", legs: []ledgerLeg{
+			{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: -100},
+			{AccountType: model.LedgerAccountPlatformRevenue, Amount: 100},
+			{AccountType: model.LedgerAccountUserPoints, OwnerID: 1, Amount: 10},
+		}},
+		{name: "cny and points cancel out only across units", legs: []ledgerLeg{
+			{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: 100},
+			{AccountType: model.LedgerAccountPointsPool, Amount: -100},
+		}},
+	}
+	for _, tt := range tests {
+		// 借贷校验在访问数据库之前完成，不平衡的凭证不会用到事务
+		entry, err := postLedgerEntry(nil, LedgerBizAdjust, 1, tt.name, tt.legs...)
+		if err == nil || !strings.Contains(err.Error(), "凭证借贷不平衡") {
+			t.Errorf("%s: postLedgerEntry() = %v, %v; want unbalanced error", tt.name, entry, err)
+		}
+	}
+
+	entry, err := postLedgerEntry(nil, LedgerBizAdjust, 1, "empty",
+		ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: 0})
+	if entry != nil || err != nil {
+		t.Errorf("postLedgerEntry(zero legs) = %v, %v; want nil, nil", entry, err)
+	}
+}
+
+func TestMergeLedgerLegs(t *testing.T) {
+	tests := []struct {
+		name    string
+		legs    []ledgerLeg
+		want    map[string]int64
+		wantErr string
+	}{
+		{
+			name: "balanced per unit",
+			legs: []ledgerLeg{
+				{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: -700},
+				{AccountType: model.LedgerAccountPlatformRevenue, Amount: 1000},
+				{AccountType: model.LedgerAccountUserPoints, OwnerID: 1, Amount: -30},
+				{AccountType: model.LedgerAccountPointsPool, Amount: 30},
+				{AccountType: model.LedgerAccountCashClearing, Amount: -300},
+			},
+			want: map[string]int64{
+				"user_wallet:1": -700, "platform_revenue": 1000, "user_points:1": -30, "points_pool": 30, "cash_clearing": -300,
+			},
+		},
+		{
+			name: "same account is merged",
+			legs: []ledgerLeg{
+				{AccountType: model.LedgerAccountStorePayable, OwnerID: 2, Amount: 500},
+				{AccountType: model.LedgerAccountStorePayable, OwnerID: 2, Amount: 300},
+				{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: -800},
+			},
+			want: map[string]int64{"store_payable:2": 800, "user_wallet:1": -800},
+		},
+		{
+			name: "different owners stay separate",
+			legs: []ledgerLeg{
+				{AccountType: model.LedgerAccountUserWallet, OwnerID: 1, Amount: -100},
+				{AccountType: model.LedgerAccountUserWallet, OwnerID: 2, Amount: 100},
+			},
+			want: map[string]int64{"user_wallet:1": -100, "user_wallet:2": 100},
+		},
+		{
+			name: "legs netting to zero are dropped",
+			legs: []ledgerLeg{
+				{AccountType: model.LedgerAccountPlatformRevenue, Amount: 100},
+				{AccountType: model.LedgerAccountPlatformRevenue, Amount: -100},
+				{AccountType: model.LedgerAccountPointsPool, Amount: 0},
+			},
+			want: map[string]int64{},
+		},
+		{
+			name: "points imbalance is reported with its unit",
+			legs: []ledgerLeg{
+				{AccountType: model.LedgerAccountUserPoints, OwnerID: 1, Amount: 5},
+			},
+			wantErr: model.LedgerUnitPoint,
+		},
+	}
+	for _, tt := range tests {
+		merged, err := mergeLedgerLegs(tt.legs)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: mergeLedgerLegs() error = %v, want error mentioning %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: mergeLedgerLegs() unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(merged) != len(tt.want) {
+			t.Errorf("%s: mergeLedgerLegs() returned %d accounts, want %d", tt.name, len(merged), len(tt.want))
+		}
+		for code, amount := range tt.want {
+			if leg, ok := merged[code]; !ok || leg.Amount != amount {
+				t.Errorf("%s: account %s = %v, want %d", tt.name, code, leg, amount)
+			}
+		}
+	}
+}
+
+func TestCheckLedgerAccountBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountType string
+		balance     int64
+		amount      int64
+		wantErr     string
+	}{
+		{name: "wallet debit within balance", accountType: model.LedgerAccountUserWallet, balance: 1000, amount: -999},
+		{name: "wallet drained to zero", accountType: model.LedgerAccountUserWallet, balance: 1000, amount: -1000},
+		{name: "wallet overdraft", accountType: model.LedgerAccountUserWallet, balance: 1000, amount: -1001, wantErr: "余额不足"},
+		{name: "points overdraft", accountType: model.LedgerAccountUserPoints, balance: 20, amount: -21, wantErr: "积分不足"},
+		{name: "points drained to zero", accountType: model.LedgerAccountUserPoints, balance: 20, amount: -20},
+		{name: "platform account may go negative", accountType: model.LedgerAccountPlatformRevenue, amount: -500},
+		{name: "store payable may go negative", accountType: model.LedgerAccountStorePayable, amount: -500},
+		{name: "points pool may go negative", accountType: model.LedgerAccountPointsPool, amount: -500},
+	}
+	for _, tt := range tests {
+		account := &model.LedgerAccount{Type: tt.accountType, Balance: tt.balance}
+		err := checkLedgerAccountBalance(account, tt.amount)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: checkLedgerAccountBalance() unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%s: checkLedgerAccountBalance() error = %v, want %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
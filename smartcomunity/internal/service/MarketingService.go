@@ -1,6 +1,9 @@
 package service
 
 import (
+	"errors"
+	"time"
+
 	"smartcommunity/internal/global"
 	"smartcommunity/internal/model"
 )
@@ -9,6 +12,9 @@ type MarketingService struct{}
 
 // CreatePromotion 创建/修改活动
 func (s *MarketingService) CreatePromotion(p *model.Promotion) error {
+	if err := validatePromotion(p); err != nil {
+		return err
+	}
 	if p.ID > 0 {
 		return global.DB.Model(&model.Promotion{}).Where("id = ?", p.ID).Updates(p).Error
 	}
@@ -29,3 +35,42 @@ func (s *MarketingService) ListPromotions(page, size int) ([]model.Promotion, in
 func (s *MarketingService) DeletePromotion(id int64) error {
 	return global.DB.Delete(&model.Promotion{}, id).Error
 }
+
+// ExpirePromotions 将已过结束时间的活动置为下线，返回处理数量
+func (s *MarketingService) ExpirePromotions() (int64, error) {
+	result := global.DB.Model(&model.Promotion{}).
+		Where("status = 1 AND end_date <= ?", time.Now()).
+		Update("status", 0)
+	return result.RowsAffected, result.Error
+}
+
+func validatePromotion(p *model.Promotion) error {
+	if p.ProductID == 0 {
+		return errors.New("请选择活动商品")
+	}
+	if !p.EndDate.After(p.StartDate) {
+		return errors.New("活动结束时间必须晚于开始时间")
+	}
+	switch p.Type {
+	case model.PromotionTypeFlashSale:
+		if p.PromoPrice <= 0 {
+			return errors.New("请设置活动价")
+		}
+	case model.PromotionTypeMarkdown:
+	case model.PromotionTypeBuyNGetM:
+		if p.BuyQuantity <= 0 || p.FreeQuantity <= 0 {
+			return errors.New("请设置买赠数量")
+		}
+	case model.PromotionTypeBundle:
+		if p.BundleQuantity < 2 || p.BundlePrice <= 0 {
+			return errors.New("请设置一口价件数和价格")
+		}
+	case model.PromotionTypeTiered:
+		if len(parsePromotionTiers(p.Tiers)) == 0 {
+			return errors.New("阶梯折扣配置无效")
+		}
+	default:
+		return errors.New("不支持的活动类型")
+	}
+	return nil
+}
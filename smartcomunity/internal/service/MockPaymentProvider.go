@@ -0,0 +1,221 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/model"
+)
+
+const (
+	mockPaymentProviderName = "mock"
+
+	defaultMockSecret      = "mock-payment-secret"
+	defaultMockNotifyDelay = 3 * time.Second
+	mockNotifyRetries      = 3
+
+	// 模拟场景，通过充值请求的 scenario 参数指定
+	MockScenarioSuccess = "success" // 延迟后支付成功并通知
+	MockScenarioFail    = "fail"    // 延迟后支付失败并通知
+	MockScenarioDelay   = "delay"   // 较长时间后才支付成功
+	MockScenarioLost    = "lost"    // 支付成功但通知丢失，只能主动查询
+)
+
+type mockCharge struct {
+	tradeNo string
+	amount  model.Money
+	status  int
+}
+
+// MockPaymentProvider 本地模拟渠道：下单后在后台模拟用户支付，并按真实渠道的方式发送签名通知
+type MockPaymentProvider struct {
+	secret  string
+	delay   time.Duration
+	charges sync.Map // chargeNo -> *mockCharge
+	mu      sync.Mutex
+}
+
+func newMockPaymentProvider() *MockPaymentProvider {
+	p := &MockPaymentProvider{secret: defaultMockSecret, delay: defaultMockNotifyDelay}
+	if config.Conf != nil {
+		if config.Conf.Payment.Mock.Secret != "" {
+			p.secret = config.Conf.Payment.Mock.Secret
+		}
+		if config.Conf.Payment.Mock.NotifyDelaySeconds > 0 {
+			p.delay = time.Duration(config.Conf.Payment.Mock.NotifyDelaySeconds) * time.Second
+		}
+	}
+	return p
+}
+
+func (p *MockPaymentProvider) Name() string {
+	return mockPaymentProviderName
+}
+
+func (p *MockPaymentProvider) CreateCharge(req *ChargeRequest) (*ChargeResult, error) {
+	scenario := strings.ToLower(strings.TrimSpace(req.Extra["scenario"]))
+	if scenario == "" {
+		scenario = MockScenarioSuccess
+	}
+	if scenario != MockScenarioSuccess && scenario != MockScenarioFail && scenario != MockScenarioDelay && scenario != MockScenarioLost {
+		return nil, fmt.Errorf("不支持的模拟场景: %s", scenario)
+	}
+
+	charge := &mockCharge{
+		tradeNo: fmt.Sprintf("MOCK%d", time.Now().UnixNano()),
+		amount:  req.Amount,
+		status:  model.ChargeStatusPending,
+	}
+	p.charges.Store(req.ChargeNo, charge)
+
+	go p.simulate(req, charge, scenario)
+
+	return &ChargeResult{
+		ProviderTradeNo: charge.tradeNo,
+		PayURL:          "mock://pay/" + req.ChargeNo,
+		Status:          model.ChargeStatusPending,
+		Amount:          req.Amount,
+	}, nil
+}
+
+// simulate 模拟用户完成支付并回调
+func (p *MockPaymentProvider) simulate(req *ChargeRequest, charge *mockCharge, scenario string) {
+	delay := p.delay
+	if scenario == MockScenarioDelay {
+		delay *= 10
+	}
+	time.Sleep(delay)
+
+	status := model.ChargeStatusSucceeded
+	if scenario == MockScenarioFail {
+		status = model.ChargeStatusFailed
+	}
+	p.mu.Lock()
+	charge.status = status
+	p.mu.Unlock()
+
+	if scenario == MockScenarioLost {
+		log.Printf("mock payment %s paid, notify dropped on purpose", req.ChargeNo)
+		return
+	}
+
+	params := map[string]string{
+		"charge_no":    req.ChargeNo,
+		"trade_no":     charge.tradeNo,
+		"amount":       charge.amount.String(),
+		"trade_status": "SUCCESS",
+		"paid_at":      strconv.FormatInt(time.Now().Unix(), 10),
+		"nonce":        strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+	if status == model.ChargeStatusFailed {
+		params["trade_status"] = "FAILED"
+		params["fail_reason"] = "mock payment declined"
+	}
+	params["sign"] = signPaymentParams(params, p.secret)
+
+	for attempt := 1; attempt <= mockNotifyRetries; attempt++ {
+		err := p.deliver(req.NotifyURL, params)
+		if err == nil {
+			return
+		}
+		log.Printf("mock payment notify failed, chargeNo=%s attempt=%d err=%v", req.ChargeNo, attempt, err)
+		time.Sleep(time.Duration(attempt) * delay)
+	}
+}
+
+// deliver 配置了通知地址时走 HTTP，否则直接在进程内处理
+func (p *MockPaymentProvider) deliver(notifyURL string, params map[string]string) error {
+	if notifyURL == "" {
+		return (&PaymentService{}).HandleNotify(p.Name(), params)
+	}
+
+	form := url.Values{}
+	for k, v := range params {
+		form.Set(k, v)
+	}
+	resp, err := http.PostForm(notifyURL, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if strings.TrimSpace(string(body)) != "success" {
+		return fmt.Errorf("notify rejected: %s", string(body))
+	}
+	return nil
+}
+
+func (p *MockPaymentProvider) ParseNotify(params map[string]string) (*PaymentNotification, error) {
+	if !verifyPaymentSign(params, p.secret) {
+		return nil, errors.New("支付通知签名错误")
+	}
+	amount, err := model.ParseMoney(params["amount"])
+	if err != nil {
+		return nil, err
+	}
+	paidAt := time.Now()
+	if ts, err := strconv.ParseInt(params["paid_at"], 10, 64); err == nil {
+		paidAt = time.Unix(ts, 0)
+	}
+
+	raw := url.Values{}
+	for k, v := range params {
+		raw.Set(k, v)
+	}
+	return &PaymentNotification{
+		ChargeNo:        params["charge_no"],
+		ProviderTradeNo: params["trade_no"],
+		Amount:          amount,
+		Succeeded:       params["trade_status"] == "SUCCESS",
+		FailReason:      params["fail_reason"],
+		PaidAt:          paidAt,
+		Raw:             raw.Encode(),
+	}, nil
+}
+
+func (p *MockPaymentProvider) QueryCharge(chargeNo string) (*ChargeResult, error) {
+	value, ok := p.charges.Load(chargeNo)
+	if !ok {
+		return nil, errors.New("渠道支付单不存在")
+	}
+	charge := value.(*mockCharge)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &ChargeResult{
+		ProviderTradeNo: charge.tradeNo,
+		Status:          charge.status,
+		Amount:          charge.amount,
+	}, nil
+}
+
+func (p *MockPaymentProvider) Refund(chargeNo string, amount model.Money, refundNo string) error {
+	value, ok := p.charges.Load(chargeNo)
+	if !ok {
+		// 进程重启后内存中的支付单会丢失，模拟渠道直接视为退款成功
+		log.Printf("mock refund %s for unknown charge %s accepted", refundNo, chargeNo)
+		return nil
+	}
+	charge := value.(*mockCharge)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if charge.status == model.ChargeStatusRefunded {
+		return nil
+	}
+	if charge.status != model.ChargeStatusSucceeded {
+		return errors.New("渠道支付单未支付，无法退款")
+	}
+	if amount > charge.amount {
+		return errors.New("退款金额超过支付金额")
+	}
+	charge.status = model.ChargeStatusRefunded
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var moneyType = reflect.TypeOf(model.Money(0))
+
+// MigrateMoneyColumns 在 AutoMigrate 之前执行：金额字段对应的列如果还不是两位小数的 decimal
+// (例如历史上的 double 列)，先把已有数据四舍五入到分，再由 AutoMigrate 改为 decimal，
+// 保证改列类型时不会因为截断产生分差。已是 decimal(x,2) 的列不做任何改动。
+func MigrateMoneyColumns(models ...interface{}) error {
+	migrator := global.DB.Migrator()
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: global.DB}
+		if err := stmt.Parse(m); err != nil {
+			return err
+		}
+		if !migrator.HasTable(m) {
+			continue
+		}
+
+		columnTypes, err := migrator.ColumnTypes(m)
+		if err != nil {
+			return err
+		}
+		existing := map[string]string{}
+		scales := map[string]int64{}
+		for _, ct := range columnTypes {
+			existing[ct.Name()] = strings.ToLower(ct.DatabaseTypeName())
+			if _, scale, ok := ct.DecimalSize(); ok {
+				scales[ct.Name()] = scale
+			}
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.FieldType != moneyType {
+				continue
+			}
+			dbType, ok := existing[field.DBName]
+			if !ok {
+				continue
+			}
+			if dbType == "decimal" && scales[field.DBName] == 2 {
+				continue
+			}
+			sql := fmt.Sprintf("UPDATE `%s` SET `%s` = ROUND(`%s`, 2)", stmt.Schema.Table, field.DBName, field.DBName)
+			result := global.DB.Exec(sql)
+			if result.Error != nil {
+				return result.Error
+			}
+			log.Printf("money column %s.%s (%s) rounded to cents, %d rows affected", stmt.Schema.Table, field.DBName, dbType, result.RowsAffected)
+		}
+	}
+	return nil
+}
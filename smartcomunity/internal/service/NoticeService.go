@@ -1,9 +1,17 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"smartcommunity/internal/config"
 	"smartcommunity/internal/global"
 	"smartcommunity/internal/model"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type NoticeService struct{}
@@ -72,3 +80,67 @@ func (s *NoticeService) MarkRead(userID, noticeID int64) error {
 	}
 	return global.DB.Create(&read).Error
 }
+
+// ListUserNotices 我的站内信
+func (s *NoticeService) ListUserNotices(userID int64, page, size int) ([]model.UserNotice, int64, int64, error) {
+	var list []model.UserNotice
+	var total, unread int64
+
+	db := global.DB.Model(&model.UserNotice{}).Where("user_id = ?", userID)
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, 0, err
+	}
+	global.DB.Model(&model.UserNotice{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&unread)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, unread, err
+}
+
+// MarkUserNoticeRead 站内信标记已读，id 为 0 时全部标记已读
+func (s *NoticeService) MarkUserNoticeRead(userID, id int64) error {
+	db := global.DB.Model(&model.UserNotice{}).Where("user_id = ?", userID)
+	if id > 0 {
+		db = db.Where("id = ?", id)
+	}
+	return db.Update("is_read", true).Error
+}
+
+// sendUserNotice 给用户发送站内信，可在事务中调用
+func sendUserNotice(db *gorm.DB, userID int64, title, content, bizType string, bizID int64) error {
+	notice := model.UserNotice{
+		UserID:    userID,
+		Title:     title,
+		Content:   content,
+		BizType:   bizType,
+		BizID:     bizID,
+		CreatedAt: time.Now(),
+	}
+	return db.Create(&notice).Error
+}
+
+// sendSMSNotice 通过通知类短信模板推送消息，未配置推送地址时只记录日志
+func sendSMSNotice(mobile, content string) error {
+	if config.Conf == nil || config.Conf.SMS.NoticeURL == "" {
+		log.Printf("sms notice url not configured, skip sms to %s: %s", mobile, content)
+		return nil
+	}
+
+	payload := map[string]interface{}{
+		"content": content,
+		"targets": mobile,
+	}
+	jsonBody, _ := json.Marshal(payload)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(config.Conf.SMS.NoticeURL, "application/json", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return errors.New("短信发送失败: " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.New("短信服务异常")
+	}
+	return nil
+}
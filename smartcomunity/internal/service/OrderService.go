@@ -3,17 +3,28 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log"
+	"math/rand"
+	"strings"
 	"time"
 
 	"smartcommunity/internal/global"
 	"smartcommunity/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderService struct{}
 
-func (s *OrderService) CreateOrder(userID int64, storeID int64, items []model.CartItemParam) (*model.Order, error) {
+func (s *OrderService) CreateOrder(userID int64, storeID int64, items []model.CartItemParam, userCouponID int64, deliveryType int) (*model.Order, error) {
+	if deliveryType != model.DeliveryTypePickup {
+		deliveryType = model.DeliveryTypeExpress
+	}
+	if deliveryType == model.DeliveryTypePickup && storeID <= 0 {
+		return nil, errors.New("please select a store for pickup")
+	}
+
 	var order *model.Order
 
 	err := global.DB.Transaction(func(tx *gorm.DB) error {
@@ -34,9 +45,24 @@ func (s *OrderService) CreateOrder(userID int64, storeID int64, items []model.Ca
 			return errors.New("please select items to purchase")
 		}
 
+		storeStock, err := storeManagesStock(tx, storeID)
+		if err != nil {
+			return err
+		}
+
+		productIDs := make([]int64, 0, len(cartList))
+		for _, cart := range cartList {
+			productIDs = append(productIDs, cart.ProductID)
+		}
+		promotions, err := loadActivePromotions(tx, productIDs)
+		if err != nil {
+			return err
+		}
+
 		orderNo := fmt.Sprintf("%d%d", time.Now().UnixNano(), userID)
-		totalAmount := 0.0
+		totalCents := 0
 		var orderItems []model.OrderItem
+		var lines []couponLine
 
 		for _, cart := range cartList {
 			finalQty := cart.Quantity
@@ -44,40 +70,86 @@ func (s *OrderService) CreateOrder(userID int64, storeID int64, items []model.Ca
 				finalQty = q
 			}
 
-			result := tx.Model(&model.Product{}).
-				Where("id = ? AND stock >= ?", cart.ProductID, finalQty).
-				Updates(map[string]interface{}{
-					"stock": gorm.Expr("stock - ?", finalQty),
-					"sales": gorm.Expr("sales + ?", finalQty),
-				})
-			if result.Error != nil {
-				return result.Error
-			}
-			if result.RowsAffected == 0 {
-				return fmt.Errorf("product [%s] has insufficient stock", cart.Product.Name)
+			// 门店启用独立库存时只占用门店库存(见下方 decreaseStoreStock)，商品总库存仅记销量
+			if storeStock {
+				if err := tx.Model(&model.Product{}).Where("id = ?", cart.ProductID).
+					Update("sales", gorm.Expr("sales + ?", finalQty)).Error; err != nil {
+					return err
+				}
+			} else {
+				result := tx.Model(&model.Product{}).
+					Where("id = ? AND stock >= ?", cart.ProductID, finalQty).
+					Updates(map[string]interface{}{
+						"stock": gorm.Expr("stock - ?", finalQty),
+						"sales": gorm.Expr("sales + ?", finalQty),
+					})
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					return fmt.Errorf("product [%s] has insufficient stock", cart.Product.Name)
+				}
 			}
 
-			itemTotal := cart.Product.Price * float64(finalQty)
-			totalAmount += itemTotal
-			orderItems = append(orderItems, model.OrderItem{
-				ProductID: cart.ProductID,
-				Price:     cart.Product.Price,
-				Quantity:  finalQty,
-			})
+			line := priceLine(cart.Product.Price, finalQty, promotions[cart.ProductID])
+			totalCents += line.Cents
+			lines = append(lines, couponLine{CategoryID: cart.Product.CategoryID, Cents: line.Cents})
+			orderItem := model.OrderItem{
+				ProductID:  cart.ProductID,
+				Price:      cart.Product.Price,
+				Quantity:   finalQty,
+				Amount:     model.Money(line.Cents),
+				StoreStock: storeStock,
+			}
+			if line.Promotion != nil {
+				orderItem.PromotionID = line.Promotion.ID
+				orderItem.PromotionTitle = line.Promotion.Title
+			}
+			orderItems = append(orderItems, orderItem)
 		}
 
 		order = &model.Order{
-			OrderNo:     orderNo,
-			UserID:      userID,
-			StoreID:     storeID,
-			TotalAmount: totalAmount,
-			Status:      0,
-			Items:       orderItems,
-			CreatedAt:   time.Now(),
+			OrderNo:      orderNo,
+			UserID:       userID,
+			StoreID:      storeID,
+			TotalAmount:  model.Money(totalCents),
+			Status:       model.OrderStatusPending,
+			DeliveryType: deliveryType,
+			Items:        orderItems,
+			CreatedAt:    time.Now(),
 		}
 		if err := tx.Create(order).Error; err != nil {
 			return err
 		}
+		if err := writeOrderStatusLog(tx, order.ID, model.OrderStatusPending, model.OrderStatusPending, userID, OrderOperatorUser, "order created"); err != nil {
+			return err
+		}
+
+		if userCouponID > 0 {
+			discountCents, err := useCoupon(tx, userID, userCouponID, order.ID, storeID, lines)
+			if err != nil {
+				return err
+			}
+			order.DiscountAmount = model.Money(discountCents)
+			order.TotalAmount = model.Money(totalCents - discountCents)
+			order.UserCouponID = userCouponID
+			if err := tx.Model(&model.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+				"discount_amount": order.DiscountAmount,
+				"total_amount":    order.TotalAmount,
+				"user_coupon_id":  userCouponID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 门店启用独立库存时，扣减该门店的商品库存
+		if storeStock {
+			for _, item := range orderItems {
+				if err := decreaseStoreStock(tx, storeID, item.ProductID, item.Quantity, model.StockMovementOrder, order.ID, userID, orderNo); err != nil {
+					return fmt.Errorf("product %d: %w", item.ProductID, err)
+				}
+			}
+		}
 
 		return tx.Delete(&model.Cart{}, cartIDs).Error
 	})
@@ -108,55 +180,186 @@ func (s *OrderService) PayOrder(userID, orderID int64) error {
 	return err
 }
 
-func (s *OrderService) ShipOrder(orderID int64) error {
-	var order model.Order
-	if err := global.DB.First(&order, orderID).Error; err != nil {
-		return errors.New("order not found")
-	}
-	if order.Status != 1 {
-		return errors.New("only paid orders can be shipped")
-	}
-	return global.DB.Model(&order).Update("status", 2).Error
+func (s *OrderService) ShipOrder(operatorID int64, operatorRole string, orderID int64) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if err := checkStoreScope(operatorID, operatorRole, order.StoreID); err != nil {
+			return err
+		}
+		if order.Status != model.OrderStatusPaid {
+			return errors.New("only paid orders can be shipped")
+		}
+		if order.DeliveryType == model.DeliveryTypePickup {
+			return errors.New("pickup orders are completed by pickup verification")
+		}
+		if err := checkGroupBuySucceeded(tx, &order); err != nil {
+			return err
+		}
+		return transitOrderStatus(tx, &order, model.OrderStatusShipped, operatorID, operatorRole, "order shipped", nil)
+	})
 }
 
 func (s *OrderService) ReceiveOrder(userID, orderID int64) error {
-	var order model.Order
-	if err := global.DB.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
-		return errors.New("order not found")
-	}
-	if order.Status != 2 {
-		return errors.New("only shipped orders can be confirmed")
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", orderID, userID).
+			First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.Status != model.OrderStatusShipped {
+			return errors.New("only shipped orders can be confirmed")
+		}
+		return transitOrderStatus(tx, &order, model.OrderStatusReceived, userID, OrderOperatorUser, "order received", nil)
+	})
+}
+
+// CloseOrder 已收货订单结束售后期，交易关闭
+func (s *OrderService) CloseOrder(operatorID int64, operatorRole string, orderID int64, reason string) error {
+	if reason == "" {
+		reason = "order closed"
 	}
-	return global.DB.Model(&order).Update("status", 3).Error
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return errors.New("order not found")
+		}
+		return transitOrderStatus(tx, &order, model.OrderStatusClosed, operatorID, operatorRole, reason, nil)
+	})
 }
 
-func (s *OrderService) ListAllOrders(page, size int, userID int64) ([]model.Order, int64, error) {
+func (s *OrderService) ListAllOrders(operatorID int64, operatorRole string, page, size int, userID int64) ([]model.Order, int64, error) {
 	var list []model.Order
 	var total int64
 
-	tx := global.DB.Model(&model.Order{})
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, 0, err
+	}
+	tx := scope.Apply(global.DB.Model(&model.Order{}), "store_id")
 	if userID > 0 {
 		tx = tx.Where("user_id = ?", userID)
 	}
 	tx.Count(&total)
 
 	offset := (page - 1) * size
-	err := tx.Preload("Items").Preload("Items.Product").Preload("Store").Preload("SysUser").
+	err = tx.Preload("Items").Preload("Items.Product").Preload("Store").Preload("SysUser").
+		Preload("StatusLogs", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
 		Order("id desc").Offset(offset).Limit(size).Find(&list).Error
 	return list, total, err
 }
 
 func (s *OrderService) CancelOrder(userID, id int64, isAdmin bool) error {
-	db := global.DB.Model(&model.Order{})
-	if !isAdmin {
-		db = db.Where("user_id = ?", userID)
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		db := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		if !isAdmin {
+			db = db.Where("user_id = ?", userID)
+		}
+		var order model.Order
+		if err := db.Where("id = ?", id).First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.Status != model.OrderStatusPending {
+			return errors.New("only pending orders can be cancelled")
+		}
+
+		operatorRole := OrderOperatorUser
+		if isAdmin {
+			operatorRole = "admin"
+		}
+		return cancelPendingOrder(tx, &order, userID, operatorRole, "order cancelled")
+	})
+}
+
+// CancelExpiredOrders 取消超过支付时限仍未支付的订单并释放库存，返回取消数量
+func (s *OrderService) CancelExpiredOrders(timeout time.Duration) (int, error) {
+	deadline := time.Now().Add(-timeout)
+
+	var ids []int64
+	if err := global.DB.Model(&model.Order{}).
+		Where("status = ? AND created_at < ?", model.OrderStatusPending, deadline).
+		Order("id asc").
+		Limit(200).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	reason := fmt.Sprintf("payment timeout after %d minutes", int(timeout.Minutes()))
+	for _, id := range ids {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var order model.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+				return err
+			}
+			// 加锁后复查，订单可能已在扫描期间被支付或取消
+			if order.Status != model.OrderStatusPending || !order.CreatedAt.Before(deadline) {
+				return nil
+			}
+			if err := cancelPendingOrder(tx, &order, 0, OrderOperatorSystem, reason); err != nil {
+				return err
+			}
+			cancelled++
+			return nil
+		})
+		if err != nil {
+			log.Printf("cancel expired order failed, orderID=%d err=%v", id, err)
+		}
 	}
-	return db.Where("id = ? AND status = ?", id, 0).Update("status", 40).Error
+	return cancelled, nil
+}
+
+// cancelPendingOrder 取消待支付订单并归还下单时扣减的库存与销量
+func cancelPendingOrder(tx *gorm.DB, order *model.Order, operatorID int64, operatorRole, reason string) error {
+	if err := transitOrderStatus(tx, order, model.OrderStatusCancelled, operatorID, operatorRole, reason, nil); err != nil {
+		return err
+	}
+	if order.UserCouponID > 0 {
+		if err := releaseOrderCoupon(tx, order.ID); err != nil {
+			return err
+		}
+	}
+	if order.GroupBuyID > 0 {
+		if err := tx.Model(&model.GroupBuyMember{}).
+			Where("order_id = ? AND status = ?", order.ID, model.GroupMemberPending).
+			Update("status", model.GroupMemberCancelled).Error; err != nil {
+			return err
+		}
+	}
+
+	var items []model.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		return err
+	}
+	// 秒杀进行中时库存退回秒杀库存池，活动结束后按普通订单归还商品库存
+	if order.FlashSaleID > 0 {
+		released, err := releaseFlashSaleStock(tx, order, items)
+		if err != nil {
+			return err
+		}
+		if released {
+			return nil
+		}
+	}
+	for _, item := range items {
+		if err := tx.Model(&model.Product{}).Where("id = ?", item.ProductID).
+			Update("sales", gorm.Expr("GREATEST(sales - ?, 0)", item.Quantity)).Error; err != nil {
+			return err
+		}
+		if err := returnProductStock(tx, item.StoreStock, order.StoreID, item.ProductID, item.Quantity, model.StockMovementOrderCancel, order.ID, operatorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *OrderService) GetOrderDetail(userID, orderID int64) (*model.Order, error) {
 	var order model.Order
 	err := global.DB.Preload("Items.Product").Preload("Store").Preload("SysUser").
+		Preload("StatusLogs", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
 		Where("id = ? AND user_id = ?", orderID, userID).
 		First(&order).Error
 	if err != nil {
@@ -164,3 +367,126 @@ func (s *OrderService) GetOrderDetail(userID, orderID int64) (*model.Order, erro
 	}
 	return &order, nil
 }
+
+// VerifyPickup 门店核销自提码，订单直接完成收货
+func (s *OrderService) VerifyPickup(operatorID int64, operatorRole string, storeID int64, code string) (*model.Order, error) {
+	code = strings.TrimSpace(code)
+	if storeID <= 0 || code == "" {
+		return nil, errors.New("store_id and pickup code are required")
+	}
+	if err := checkStoreScope(operatorID, operatorRole, storeID); err != nil {
+		return nil, err
+	}
+
+	var order model.Order
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND pickup_code = ? AND delivery_type = ? AND status = ?", storeID, code, model.DeliveryTypePickup, model.OrderStatusPaid).
+			First(&order).Error; err != nil {
+			return errors.New("pickup code is invalid or already used")
+		}
+		if order.PickupDeadline != nil && time.Now().After(*order.PickupDeadline) {
+			return errors.New("pickup deadline has passed")
+		}
+
+		now := time.Now()
+		order.PickedUpAt = &now
+		return transitOrderStatus(tx, &order, model.OrderStatusReceived, operatorID, operatorRole, "picked up at store", map[string]interface{}{
+			"picked_up_at": &now,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// RemindPickupOrders 给即将超过自提期限的订单发送取货提醒，返回提醒数量
+func (s *OrderService) RemindPickupOrders(remindBefore time.Duration) (int, error) {
+	now := time.Now()
+	var list []model.Order
+	if err := global.DB.Preload("Store").
+		Where("delivery_type = ? AND status = ? AND pickup_reminded = ? AND pickup_deadline > ? AND pickup_deadline <= ?",
+			model.DeliveryTypePickup, model.OrderStatusPaid, false, now, now.Add(remindBefore)).
+		Order("id asc").
+		Limit(200).
+		Find(&list).Error; err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, order := range list {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&model.Order{}).
+				Where("id = ? AND pickup_reminded = ?", order.ID, false).
+				Update("pickup_reminded", true)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			content := fmt.Sprintf("您的订单 %s 已备好，请在 %s 前到 %s 出示取货码 %s 取货，逾期将自动退款。",
+				order.OrderNo, order.PickupDeadline.Format("2006-01-02 15:04"), order.Store.Name, order.PickupCode)
+			return sendUserNotice(tx, order.UserID, "取货提醒", content, "order_pickup", order.ID)
+		})
+		if err != nil {
+			log.Printf("remind pickup order failed, orderID=%d err=%v", order.ID, err)
+			continue
+		}
+		reminded++
+	}
+	return reminded, nil
+}
+
+// RefundOverduePickupOrders 超过自提期限仍未取货的订单自动全额退款，返回处理数量
+func (s *OrderService) RefundOverduePickupOrders() (int, error) {
+	var ids []int64
+	if err := global.DB.Model(&model.Order{}).
+		Where("delivery_type = ? AND status = ? AND pickup_deadline <= ?", model.DeliveryTypePickup, model.OrderStatusPaid, time.Now()).
+		Order("id asc").
+		Limit(100).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	refunded := 0
+	for _, id := range ids {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var order model.Order
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, id).Error; err != nil {
+				return err
+			}
+			if order.Status != model.OrderStatusPaid || order.PickupDeadline == nil || time.Now().Before(*order.PickupDeadline) {
+				return nil
+			}
+			if err := systemRefundOrder(tx, &order, "pickup deadline passed"); err != nil {
+				return err
+			}
+			content := fmt.Sprintf("您的订单 %s 超过自提期限未取货，已自动退款。", order.OrderNo)
+			if err := sendUserNotice(tx, order.UserID, "自提订单已退款", content, "order_pickup", order.ID); err != nil {
+				return err
+			}
+			refunded++
+			return nil
+		})
+		if err != nil {
+			log.Printf("refund overdue pickup order failed, orderID=%d err=%v", id, err)
+		}
+	}
+	return refunded, nil
+}
+
+// generatePickupCode 生成门店内未被占用的 6 位自提码
+func generatePickupCode(tx *gorm.DB, storeID int64) (string, error) {
+	for i := 0; i < 10; i++ {
+		code := fmt.Sprintf("%06d", rand.Intn(1000000))
+		var count int64
+		if err := tx.Model(&model.Order{}).
+			Where("store_id = ? AND pickup_code = ? AND status = ?", storeID, code, model.OrderStatusPaid).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("生成取货码失败，请重试")
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	OrderOperatorUser   = "user"
+	OrderOperatorSystem = "system"
+)
+
+// orderTransitions 订单状态流转表，未列出的流转一律拒绝
+var orderTransitions = map[int][]int{
+	model.OrderStatusPending:   {model.OrderStatusPaid, model.OrderStatusCancelled},
+	model.OrderStatusPaid:      {model.OrderStatusShipped, model.OrderStatusReceived, model.OrderStatusRefunding}, // 自提订单核销后直接收货
+	model.OrderStatusShipped:   {model.OrderStatusReceived, model.OrderStatusRefunding},
+	model.OrderStatusReceived:  {model.OrderStatusRefunding, model.OrderStatusClosed},
+	model.OrderStatusRefunding: {model.OrderStatusPaid, model.OrderStatusShipped, model.OrderStatusReceived, model.OrderStatusRefunded},
+}
+
+var orderStatusNames = map[int]string{
+	model.OrderStatusPending:   "pending",
+	model.OrderStatusPaid:      "paid",
+	model.OrderStatusShipped:   "shipped",
+	model.OrderStatusReceived:  "received",
+	model.OrderStatusCancelled: "cancelled",
+	model.OrderStatusRefunding: "refunding",
+	model.OrderStatusRefunded:  "refunded",
+	model.OrderStatusClosed:    "closed",
+}
+
+// OrderStatusName 返回订单状态的英文标识
+func OrderStatusName(status int) string {
+	if name, ok := orderStatusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", status)
+}
+
+func canTransitOrder(from, to int) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitOrderStatus 校验并执行订单状态流转，同时写入流转记录。
+// 使用 status 条件更新，防止并发请求基于过期状态重复流转。
+func transitOrderStatus(tx *gorm.DB, order *model.Order, to int, operatorID int64, operatorRole, reason string, extra map[string]interface{}) error {
+	from := order.Status
+	if !canTransitOrder(from, to) {
+		return fmt.Errorf("order cannot move from %s to %s", OrderStatusName(from), OrderStatusName(to))
+	}
+
+	updates := map[string]interface{}{"status": to}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	result := tx.Model(&model.Order{}).
+		Where("id = ? AND status = ?", order.ID, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("order status has changed, expected %s", OrderStatusName(from))
+	}
+
+	order.Status = to
+	return writeOrderStatusLog(tx, order.ID, from, to, operatorID, operatorRole, reason)
+}
+
+func writeOrderStatusLog(tx *gorm.DB, orderID int64, from, to int, operatorID int64, operatorRole, reason string) error {
+	if operatorRole == "" {
+		operatorRole = OrderOperatorSystem
+	}
+	log := model.OrderStatusLog{
+		OrderID:      orderID,
+		FromStatus:   from,
+		ToStatus:     to,
+		OperatorID:   operatorID,
+		OperatorRole: operatorRole,
+		Reason:       reason,
+		CreatedAt:    time.Now(),
+	}
+	return tx.Create(&log).Error
+}
+
+// orderRevenueStatuses 计入商城收入的订单状态
+func orderRevenueStatuses() []int {
+	return []int{model.OrderStatusPaid, model.OrderStatusShipped, model.OrderStatusReceived, model.OrderStatusClosed}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"smartcommunity/internal/config"
+)
+
+const (
+	defaultOrderPaymentTimeout     = 30 * time.Minute
+	defaultOrderCancelScanInterval = time.Minute
+)
+
+// OrderPaymentTimeout 未支付订单的支付时限
+func OrderPaymentTimeout() time.Duration {
+	if config.Conf != nil && config.Conf.Order.PaymentTimeoutMinutes > 0 {
+		return time.Duration(config.Conf.Order.PaymentTimeoutMinutes) * time.Minute
+	}
+	return defaultOrderPaymentTimeout
+}
+
+func orderCancelScanInterval() time.Duration {
+	if config.Conf != nil && config.Conf.Order.CancelScanIntervalSeconds > 0 {
+		return time.Duration(config.Conf.Order.CancelScanIntervalSeconds) * time.Second
+	}
+	return defaultOrderCancelScanInterval
+}
+
+// StartOrderTimeoutScheduler 定期取消超时未支付订单并释放库存
+func StartOrderTimeoutScheduler() {
+	orderService := &OrderService{}
+	timeout := OrderPaymentTimeout()
+	interval := orderCancelScanInterval()
+
+	go func() {
+		log.Printf("order timeout scheduler armed, timeout=%s interval=%s", timeout, interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			count, err := orderService.CancelExpiredOrders(timeout)
+			if err != nil {
+				log.Printf("cancel expired orders failed: %v", err)
+			} else if count > 0 {
+				log.Printf("cancelled %d expired unpaid orders", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
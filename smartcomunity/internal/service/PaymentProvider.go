@@ -0,0 +1,139 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/model"
+)
+
+const defaultPaymentProvider = "mock"
+
+// ChargeRequest 发起支付的参数
+type ChargeRequest struct {
+	ChargeNo  string
+	Amount    model.Money
+	Subject   string
+	NotifyURL string
+	ExpiresAt time.Time
+	Extra     map[string]string // 渠道特有参数，如 mock 渠道的 scenario
+}
+
+// ChargeResult 渠道返回的支付单信息，Status 取值同 model.ChargeStatus*
+type ChargeResult struct {
+	ProviderTradeNo string      `json:"provider_trade_no"`
+	PayURL          string      `json:"pay_url"`
+	Status          int         `json:"status"`
+	Amount          model.Money `json:"amount"`
+}
+
+// PaymentNotification 验签通过后的异步通知内容
+type PaymentNotification struct {
+	ChargeNo        string
+	ProviderTradeNo string
+	Amount          model.Money
+	Succeeded       bool
+	FailReason      string
+	PaidAt          time.Time
+	Raw             string
+}
+
+// PaymentProvider 支付渠道，新增渠道实现该接口并在 newPaymentProvider 中注册
+type PaymentProvider interface {
+	Name() string
+	// CreateCharge 下单，返回支付链接等信息；支付结果通过异步通知告知
+	CreateCharge(req *ChargeRequest) (*ChargeResult, error)
+	// ParseNotify 校验通知签名并解析，签名不正确时返回错误
+	ParseNotify(params map[string]string) (*PaymentNotification, error)
+	// QueryCharge 主动查询渠道侧的支付状态
+	QueryCharge(chargeNo string) (*ChargeResult, error)
+	// Refund 原路退款
+	Refund(chargeNo string, amount model.Money, refundNo string) error
+}
+
+var (
+	paymentProvidersMu sync.Mutex
+	paymentProviders   = map[string]PaymentProvider{}
+)
+
+// GetPaymentProvider 按名称获取渠道实例，name 为空时使用配置的默认渠道
+func GetPaymentProvider(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = defaultPaymentProvider
+		if config.Conf != nil && config.Conf.Payment.Provider != "" {
+			name = config.Conf.Payment.Provider
+		}
+	}
+
+	paymentProvidersMu.Lock()
+	defer paymentProvidersMu.Unlock()
+	if provider, ok := paymentProviders[name]; ok {
+		return provider, nil
+	}
+	provider, err := newPaymentProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	paymentProviders[name] = provider
+	return provider, nil
+}
+
+func newPaymentProvider(name string) (PaymentProvider, error) {
+	switch name {
+	case mockPaymentProviderName:
+		// mock 渠道会自动把支付单置为成功，非开发环境启用等于凭空充值
+		if !mockPaymentAllowed() {
+			return nil, errors.New("mock 支付渠道仅限开发环境使用")
+		}
+		return newMockPaymentProvider(), nil
+	}
+	return nil, fmt.Errorf("不支持的支付渠道: %s", name)
+}
+
+func mockPaymentAllowed() bool {
+	return config.Conf != nil && config.Conf.Env == "dev"
+}
+
+// CheckPaymentConfig 启动时校验支付配置，非开发环境显式配置 mock 渠道时返回错误
+func CheckPaymentConfig() error {
+	if config.Conf == nil || config.Conf.Payment.Provider != mockPaymentProviderName {
+		return nil
+	}
+	if !mockPaymentAllowed() {
+		return fmt.Errorf("payment.provider=mock is not allowed in %s environment", config.Conf.Env)
+	}
+	return nil
+}
+
+// signPaymentParams 按参数名排序拼接 k=v&k=v 后做 HMAC-SHA256，忽略 sign 本身和空值
+func signPaymentParams(params map[string]string, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if k == "sign" || v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(pairs, "&")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyPaymentSign(params map[string]string, secret string) bool {
+	expected := signPaymentParams(params, secret)
+	return hmac.Equal([]byte(expected), []byte(params["sign"]))
+}
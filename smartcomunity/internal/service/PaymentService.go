@@ -0,0 +1,304 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultChargeExpire = 30 * time.Minute
+
+type PaymentService struct{}
+
+// ChargeStatus 支付单及渠道侧查询到的状态
+type ChargeStatus struct {
+	Charge         *model.PaymentCharge `json:"charge"`
+	ProviderStatus *int                 `json:"provider_status,omitempty"`
+}
+
+func chargeExpire() time.Duration {
+	if config.Conf != nil && config.Conf.Payment.ChargeExpireMinutes > 0 {
+		return time.Duration(config.Conf.Payment.ChargeExpireMinutes) * time.Minute
+	}
+	return defaultChargeExpire
+}
+
+func paymentNotifyURL() string {
+	if config.Conf != nil {
+		return config.Conf.Payment.NotifyURL
+	}
+	return ""
+}
+
+// CreateRecharge 创建待支付的充值单，余额在收到渠道的成功通知后才增加
+func (s *PaymentService) CreateRecharge(userID int64, amount model.Money, extra map[string]string) (*model.PaymentCharge, error) {
+	if amount <= 0 {
+		return nil, errors.New("充值金额必须大于0")
+	}
+	provider, err := GetPaymentProvider("")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	charge := &model.PaymentCharge{
+		ChargeNo:  fmt.Sprintf("C%d%d", now.UnixNano(), userID),
+		UserID:    userID,
+		BizType:   model.ChargeBizRecharge,
+		Amount:    amount,
+		Provider:  provider.Name(),
+		Status:    model.ChargeStatusPending,
+		ExpiresAt: now.Add(chargeExpire()),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := global.DB.Create(charge).Error; err != nil {
+		return nil, err
+	}
+
+	result, err := provider.CreateCharge(&ChargeRequest{
+		ChargeNo:  charge.ChargeNo,
+		Amount:    amount,
+		Subject:   "余额充值",
+		NotifyURL: paymentNotifyURL(),
+		ExpiresAt: charge.ExpiresAt,
+		Extra:     extra,
+	})
+	if err != nil {
+		global.DB.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Updates(map[string]interface{}{
+			"status":      model.ChargeStatusFailed,
+			"fail_reason": err.Error(),
+		})
+		return nil, err
+	}
+
+	charge.ProviderTradeNo = result.ProviderTradeNo
+	charge.PayURL = result.PayURL
+	if err := global.DB.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Updates(map[string]interface{}{
+		"provider_trade_no": result.ProviderTradeNo,
+		"pay_url":           result.PayURL,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return charge, nil
+}
+
+// HandleNotify 处理渠道异步通知：验签、核对金额后入账；重复通知直接返回成功
+func (s *PaymentService) HandleNotify(providerName string, params map[string]string) error {
+	provider, err := GetPaymentProvider(providerName)
+	if err != nil {
+		return err
+	}
+	notification, err := provider.ParseNotify(params)
+	if err != nil {
+		log.Printf("payment notify rejected, provider=%s err=%v", providerName, err)
+		return err
+	}
+
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var charge model.PaymentCharge
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("charge_no = ?", notification.ChargeNo).
+			First(&charge).Error; err != nil {
+			return errors.New("支付单不存在")
+		}
+		if charge.Provider != provider.Name() {
+			return errors.New("支付渠道不匹配")
+		}
+		if charge.Status == model.ChargeStatusSucceeded || charge.Status == model.ChargeStatusRefunding || charge.Status == model.ChargeStatusRefunded {
+			return nil
+		}
+		if notification.Amount != charge.Amount {
+			log.Printf("payment notify amount mismatch, chargeNo=%s expected=%s got=%s", charge.ChargeNo, charge.Amount, notification.Amount)
+			return errors.New("支付金额与支付单不一致")
+		}
+
+		if !notification.Succeeded {
+			if charge.Status != model.ChargeStatusPending {
+				return nil
+			}
+			return tx.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Updates(map[string]interface{}{
+				"status":            model.ChargeStatusFailed,
+				"fail_reason":       notification.FailReason,
+				"provider_trade_no": notification.ProviderTradeNo,
+				"notify_payload":    notification.Raw,
+			}).Error
+		}
+		// 超时关闭后用户仍完成了支付，钱已到账，照常入账
+		return creditRecharge(tx, &charge, notification)
+	})
+}
+
+func creditRecharge(tx *gorm.DB, charge *model.PaymentCharge, notification *PaymentNotification) error {
+	cents := charge.Amount.Cents()
+	remark := "Balance recharge " + charge.ChargeNo
+	if _, err := postLedgerEntry(tx, LedgerBizRecharge, charge.ID, remark,
+		ledgerLeg{AccountType: model.LedgerAccountCashClearing, Amount: -cents},
+		ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: charge.UserID, Amount: cents},
+	); err != nil {
+		return err
+	}
+
+	transaction := model.SysTransaction{
+		UserID:    charge.UserID,
+		Type:      TransactionTypeTopUp,
+		Amount:    charge.Amount,
+		RelatedID: charge.ID,
+		Remark:    remark,
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+
+	paidAt := notification.PaidAt
+	return tx.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Updates(map[string]interface{}{
+		"status":            model.ChargeStatusSucceeded,
+		"provider_trade_no": notification.ProviderTradeNo,
+		"notify_payload":    notification.Raw,
+		"fail_reason":       "",
+		"paid_at":           &paidAt,
+	}).Error
+}
+
+// GetCharge 查询充值单；待支付时同时返回渠道侧状态，但入账仍以异步通知为准
+func (s *PaymentService) GetCharge(userID int64, chargeNo string) (*ChargeStatus, error) {
+	var charge model.PaymentCharge
+	if err := global.DB.Where("charge_no = ? AND user_id = ?", chargeNo, userID).First(&charge).Error; err != nil {
+		return nil, errors.New("支付单不存在")
+	}
+
+	if charge.Status == model.ChargeStatusPending && time.Now().After(charge.ExpiresAt) {
+		result := global.DB.Model(&model.PaymentCharge{}).
+			Where("id = ? AND status = ?", charge.ID, model.ChargeStatusPending).
+			Update("status", model.ChargeStatusClosed)
+		if result.Error == nil && result.RowsAffected > 0 {
+			charge.Status = model.ChargeStatusClosed
+		}
+	}
+
+	status := &ChargeStatus{Charge: &charge}
+	if charge.Status == model.ChargeStatusPending || charge.Status == model.ChargeStatusClosed {
+		if provider, err := GetPaymentProvider(charge.Provider); err == nil {
+			if result, err := provider.QueryCharge(charge.ChargeNo); err == nil {
+				status.ProviderStatus = &result.Status
+			}
+		}
+	}
+	return status, nil
+}
+
+// ListCharges 我的充值记录
+func (s *PaymentService) ListCharges(userID int64, page, size int) ([]model.PaymentCharge, int64, error) {
+	var list []model.PaymentCharge
+	var total int64
+	db := global.DB.Model(&model.PaymentCharge{}).Where("user_id = ?", userID)
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+const chargeRefundFinaliseAttempts = 3
+
+// RefundCharge 充值原路退回，从用户余额中扣回充值金额 (Admin)
+// 先在事务内扣回余额并标记退款中，再在事务外调用渠道，避免渠道已退款而余额未扣回，
+// 也避免慢渠道长时间持有用户行锁。渠道失败时保留退款中状态，可用同一退款单号重试
+func (s *PaymentService) RefundCharge(operatorID int64, chargeNo, reason string) error {
+	charge, err := reserveChargeRefund(operatorID, chargeNo, reason)
+	if err != nil {
+		return err
+	}
+
+	provider, err := GetPaymentProvider(charge.Provider)
+	if err != nil {
+		return err
+	}
+	if err := provider.Refund(charge.ChargeNo, charge.Amount, charge.RefundNo); err != nil {
+		log.Printf("provider refund failed, chargeNo=%s refundNo=%s err=%v", charge.ChargeNo, charge.RefundNo, err)
+		global.DB.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Update("fail_reason", truncateReceiptText(err.Error(), 255))
+		return fmt.Errorf("渠道退款失败，退款单保持退款中，可稍后重试: %w", err)
+	}
+
+	now := time.Now()
+	for attempt := 1; ; attempt++ {
+		err = global.DB.Model(&model.PaymentCharge{}).
+			Where("id = ? AND status = ?", charge.ID, model.ChargeStatusRefunding).
+			Updates(map[string]interface{}{
+				"status":      model.ChargeStatusRefunded,
+				"fail_reason": "",
+				"refunded_at": &now,
+			}).Error
+		if err == nil || attempt >= chargeRefundFinaliseAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * 200 * time.Millisecond)
+	}
+	if err != nil {
+		// 余额已扣回、渠道已退款，仅状态未更新；再次发起退款会以同一退款单号幂等完成
+		log.Printf("finalise charge refund failed, chargeNo=%s refundNo=%s err=%v", charge.ChargeNo, charge.RefundNo, err)
+		return err
+	}
+	return nil
+}
+
+// reserveChargeRefund 扣回充值金额并将支付单标记为退款中；已处于退款中的支付单直接返回以便重试渠道退款
+func reserveChargeRefund(operatorID int64, chargeNo, reason string) (*model.PaymentCharge, error) {
+	var charge model.PaymentCharge
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("charge_no = ?", chargeNo).
+			First(&charge).Error; err != nil {
+			return errors.New("支付单不存在")
+		}
+		if charge.Status == model.ChargeStatusRefunding {
+			return nil
+		}
+		if charge.Status != model.ChargeStatusSucceeded {
+			return errors.New("只能退款已入账的充值单")
+		}
+
+		cents := charge.Amount.Cents()
+		remark := "Recharge refund " + charge.ChargeNo
+		if reason != "" {
+			remark += ": " + reason
+		}
+		if _, err := postLedgerEntry(tx, LedgerBizRecharge, charge.ID, remark,
+			ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: charge.UserID, Amount: -cents},
+			ledgerLeg{AccountType: model.LedgerAccountCashClearing, Amount: cents},
+		); err != nil {
+			return err
+		}
+		transaction := model.SysTransaction{
+			UserID:    charge.UserID,
+			Type:      TransactionTypeTopUp,
+			Amount:    -charge.Amount,
+			RelatedID: charge.ID,
+			Remark:    remark,
+			CreatedAt: time.Now(),
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+
+		charge.Status = model.ChargeStatusRefunding
+		charge.RefundNo = fmt.Sprintf("RF%d%d", time.Now().UnixNano(), operatorID)
+		return tx.Model(&model.PaymentCharge{}).Where("id = ?", charge.ID).Updates(map[string]interface{}{
+			"status":    charge.Status,
+			"refund_no": charge.RefundNo,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
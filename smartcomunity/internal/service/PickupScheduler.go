@@ -0,0 +1,55 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"smartcommunity/internal/config"
+)
+
+const (
+	defaultPickupDeadline     = 72 * time.Hour
+	defaultPickupRemindBefore = 12 * time.Hour
+	pickupScanInterval        = 5 * time.Minute
+)
+
+// PickupDeadline 自提订单支付后的取货期限
+func PickupDeadline() time.Duration {
+	if config.Conf != nil && config.Conf.Order.PickupDeadlineHours > 0 {
+		return time.Duration(config.Conf.Order.PickupDeadlineHours) * time.Hour
+	}
+	return defaultPickupDeadline
+}
+
+func pickupRemindBefore() time.Duration {
+	if config.Conf != nil && config.Conf.Order.PickupRemindHours > 0 {
+		return time.Duration(config.Conf.Order.PickupRemindHours) * time.Hour
+	}
+	return defaultPickupRemindBefore
+}
+
+// StartPickupScheduler 定期提醒未取货的自提订单，并对逾期订单自动退款
+func StartPickupScheduler() {
+	orderService := &OrderService{}
+	remindBefore := pickupRemindBefore()
+
+	go func() {
+		log.Printf("pickup scheduler armed, remindBefore=%s interval=%s", remindBefore, pickupScanInterval)
+		ticker := time.NewTicker(pickupScanInterval)
+		defer ticker.Stop()
+
+		for {
+			if count, err := orderService.RemindPickupOrders(remindBefore); err != nil {
+				log.Printf("remind pickup orders failed: %v", err)
+			} else if count > 0 {
+				log.Printf("sent %d pickup reminders", count)
+			}
+			if count, err := orderService.RefundOverduePickupOrders(); err != nil {
+				log.Printf("refund overdue pickup orders failed: %v", err)
+			} else if count > 0 {
+				log.Printf("refunded %d overdue pickup orders", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -12,11 +12,15 @@ import (
 
 type ProductService struct{}
 
-func (s *ProductService) GetList(page, size int, name string, minPrice, maxPrice float64, sort string, categoryID int64, isPromotion bool, status *int) ([]model.Product, int64, error) {
+func (s *ProductService) GetList(page, size int, name string, minPrice, maxPrice model.Money, sort string, categoryID int64, isPromotion bool, status *int, storeID int64) ([]model.Product, int64, error) {
 	var list []model.Product
 	var total int64
 
 	tx := global.DB.Model(&model.Product{})
+	if storeID > 0 {
+		// 只展示该门店已铺货的商品
+		tx = tx.Where("id IN (?)", global.DB.Model(&model.StoreProduct{}).Select("product_id").Where("store_id = ?", storeID))
+	}
 	if name != "" {
 		tx = tx.Where("name LIKE ?", "%"+name+"%")
 	}
@@ -33,8 +37,11 @@ func (s *ProductService) GetList(page, size int, name string, minPrice, maxPrice
 		tx = tx.Where("status = ?", *status)
 	}
 	if isPromotion {
-		// Promotion is defined by price relation, not stale stored flag.
-		tx = tx.Where("original_price > price")
+		// Promotion is defined by price relation or an active promotion, not stale stored flag.
+		now := time.Now()
+		tx = tx.Where("original_price > price OR id IN (?)",
+			global.DB.Model(&model.Promotion{}).Select("product_id").
+				Where("status = 1 AND start_date <= ? AND end_date > ?", now, now))
 	}
 
 	switch sort {
@@ -54,19 +61,72 @@ func (s *ProductService) GetList(page, size int, name string, minPrice, maxPrice
 	if err := tx.Offset(offset).Limit(size).Find(&list).Error; err != nil {
 		return nil, 0, err
 	}
-	for i := range list {
-		list[i].IsPromotion = calcIsPromotion(list[i].OriginalPrice, list[i].Price)
+	if err := fillPromotions(list); err != nil {
+		return nil, 0, err
+	}
+	if storeID > 0 {
+		if err := fillStoreStock(storeID, list); err != nil {
+			return nil, 0, err
+		}
 	}
 	return list, total, nil
 }
 
+// fillStoreStock 为商品列表补充指定门店的库存
+func fillStoreStock(storeID int64, list []model.Product) error {
+	if len(list) == 0 {
+		return nil
+	}
+	productIDs := make([]int64, 0, len(list))
+	for _, p := range list {
+		productIDs = append(productIDs, p.ID)
+	}
+
+	var bindings []model.StoreProduct
+	if err := global.DB.Where("store_id = ? AND product_id IN ?", storeID, productIDs).Find(&bindings).Error; err != nil {
+		return err
+	}
+	stockMap := make(map[int64]int, len(bindings))
+	for _, b := range bindings {
+		stockMap[b.ProductID] = b.Stock
+	}
+	for i := range list {
+		stock := stockMap[list[i].ID]
+		list[i].StoreStock = &stock
+	}
+	return nil
+}
+
 func (s *ProductService) GetDetail(id int64) (*model.Product, error) {
 	var product model.Product
 	if err := global.DB.First(&product, id).Error; err != nil {
 		return nil, err
 	}
-	product.IsPromotion = calcIsPromotion(product.OriginalPrice, product.Price)
-	return &product, nil
+	list := []model.Product{product}
+	if err := fillPromotions(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// fillPromotions 为商品补充当前生效的活动及促销标记
+func fillPromotions(list []model.Product) error {
+	productIDs := make([]int64, 0, len(list))
+	for _, p := range list {
+		productIDs = append(productIDs, p.ID)
+	}
+	promotions, err := loadActivePromotions(global.DB, productIDs)
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].Promotions = promotions[list[i].ID]
+		list[i].IsPromotion = calcIsPromotion(list[i].OriginalPrice, list[i].Price)
+		if len(list[i].Promotions) > 0 {
+			list[i].IsPromotion = 1
+		}
+	}
+	return nil
 }
 
 func (s *ProductService) GetSalesRank() ([]model.Product, error) {
@@ -78,7 +138,7 @@ func (s *ProductService) GetSalesRank() ([]model.Product, error) {
 	return list, err
 }
 
-func (s *ProductService) Create(product *model.Product) error {
+func (s *ProductService) Create(operatorID int64, operatorRole string, product *model.Product) error {
 	if product.Name == "" {
 		return errors.New("product name is required")
 	}
@@ -86,6 +146,20 @@ func (s *ProductService) Create(product *model.Product) error {
 		return errors.New("product price must be greater than 0")
 	}
 
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return err
+	}
+	if !scope.All {
+		// 门店账号发布的商品归属自己的门店，只绑定一个门店时可省略 store_id
+		if product.StoreID == 0 && len(scope.StoreIDs) == 1 {
+			product.StoreID = scope.StoreIDs[0]
+		}
+		if err := scope.Check(product.StoreID); err != nil {
+			return err
+		}
+	}
+
 	if product.CategoryID > 0 {
 		var cat model.ProductCategory
 		if err := global.DB.First(&cat, product.CategoryID).Error; err == nil {
@@ -95,18 +169,19 @@ func (s *ProductService) Create(product *model.Product) error {
 
 	product.IsPromotion = calcIsPromotion(product.OriginalPrice, product.Price)
 
-	return global.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(product).Error; err != nil {
-			return fmt.Errorf("create product failed: %w", err)
-		}
-		return s.syncPromotionRecord(tx, product.ID, product.IsPromotion)
-	})
+	if err := global.DB.Create(product).Error; err != nil {
+		return fmt.Errorf("create product failed: %w", err)
+	}
+	return nil
 }
 
-func (s *ProductService) Update(product *model.Product) error {
+func (s *ProductService) Update(operatorID int64, operatorRole string, product *model.Product) error {
 	if product.ID == 0 {
 		return errors.New("product id is required")
 	}
+	if err := checkProductScope(operatorID, operatorRole, product.ID); err != nil {
+		return err
+	}
 	if product.Name == "" {
 		return errors.New("product name is required")
 	}
@@ -123,28 +198,26 @@ func (s *ProductService) Update(product *model.Product) error {
 
 	product.IsPromotion = calcIsPromotion(product.OriginalPrice, product.Price)
 
-	return global.DB.Transaction(func(tx *gorm.DB) error {
-		// Use map to ensure zero values (e.g. status=0) are persisted.
-		updates := map[string]interface{}{
-			"name":           product.Name,
-			"price":          product.Price,
-			"original_price": product.OriginalPrice,
-			"stock":          product.Stock,
-			"category_id":    product.CategoryID,
-			"category_name":  product.CategoryName,
-			"description":    product.Description,
-			"image_url":      product.ImageURL,
-			"status":         product.Status,
-			"is_promotion":   product.IsPromotion,
-		}
-		if err := tx.Model(&model.Product{}).Where("id = ?", product.ID).Updates(updates).Error; err != nil {
-			return err
-		}
-		return s.syncPromotionRecord(tx, product.ID, product.IsPromotion)
-	})
+	// Use map to ensure zero values (e.g. status=0) are persisted.
+	updates := map[string]interface{}{
+		"name":           product.Name,
+		"price":          product.Price,
+		"original_price": product.OriginalPrice,
+		"stock":          product.Stock,
+		"category_id":    product.CategoryID,
+		"category_name":  product.CategoryName,
+		"description":    product.Description,
+		"image_url":      product.ImageURL,
+		"status":         product.Status,
+		"is_promotion":   product.IsPromotion,
+	}
+	return global.DB.Model(&model.Product{}).Where("id = ?", product.ID).Updates(updates).Error
 }
 
-func (s *ProductService) Delete(id int64) error {
+func (s *ProductService) Delete(operatorID int64, operatorRole string, id int64) error {
+	if err := checkProductScope(operatorID, operatorRole, id); err != nil {
+		return err
+	}
 	return global.DB.Transaction(func(tx *gorm.DB) error {
 		tx.Delete(&model.Promotion{}, "product_id = ?", id)
 		tx.Delete(&model.Product{}, id)
@@ -158,33 +231,50 @@ func (s *ProductService) GetCategories() ([]model.ProductCategory, error) {
 	return list, err
 }
 
-func calcIsPromotion(originalPrice, price float64) int {
+func calcIsPromotion(originalPrice, price model.Money) int {
 	if originalPrice > price {
 		return 1
 	}
 	return 0
 }
 
-func (s *ProductService) syncPromotionRecord(tx *gorm.DB, productID int64, isPromotion int) error {
-	if isPromotion == 1 {
-		var count int64
-		if err := tx.Model(&model.Promotion{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
-			return err
-		}
-		if count == 0 {
-			promotion := model.Promotion{
-				Title:     "special promotion",
-				Type:      2,
-				StartDate: time.Now(),
-				EndDate:   time.Now().AddDate(1, 0, 0),
-				Status:    1,
-				ProductID: productID,
-			}
-			if err := tx.Create(&promotion).Error; err != nil {
-				return fmt.Errorf("create promotion failed: %w", err)
-			}
-		}
+// ListManaged 后台商品列表，门店账号只能看到自己门店的商品
+func (s *ProductService) ListManaged(operatorID int64, operatorRole string, page, size int, name string) ([]model.Product, int64, error) {
+	var list []model.Product
+	var total int64
+
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := scope.Apply(global.DB.Model(&model.Product{}), "store_id")
+	if name != "" {
+		db = db.Where("name LIKE ?", "%"+name+"%")
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	if err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := fillPromotions(list); err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// checkProductScope 门店账号只能修改归属自己门店的商品
+func checkProductScope(operatorID int64, operatorRole string, productID int64) error {
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return err
+	}
+	if scope.All {
 		return nil
 	}
-	return tx.Where("product_id = ?", productID).Delete(&model.Promotion{}).Error
+	var product model.Product
+	if err := global.DB.Select("id, store_id").First(&product, productID).Error; err != nil {
+		return errors.New("product not found")
+	}
+	return scope.Check(product.StoreID)
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// linePrice 单个商品行按活动计价的结果
+type linePrice struct {
+	Cents     int
+	Promotion *model.Promotion
+}
+
+// loadActivePromotions 查询商品当前生效的活动，按商品分组
+func loadActivePromotions(db *gorm.DB, productIDs []int64) (map[int64][]model.Promotion, error) {
+	result := make(map[int64][]model.Promotion)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	var list []model.Promotion
+	if err := db.Where("product_id IN ? AND status = 1 AND start_date <= ? AND end_date > ?", productIDs, now, now).
+		Order("id asc").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		result[p.ProductID] = append(result[p.ProductID], p)
+	}
+	return result, nil
+}
+
+// priceLine 在商品的所有生效活动中选出价格最低的一个，没有更优活动时按原价计算
+func priceLine(unitPrice model.Money, quantity int, promotions []model.Promotion) linePrice {
+	unitCents := int(unitPrice.Cents())
+	best := linePrice{Cents: unitCents * quantity}
+	for i := range promotions {
+		cents, ok := promotionLineCents(&promotions[i], unitCents, quantity)
+		if ok && cents < best.Cents {
+			best = linePrice{Cents: cents, Promotion: &promotions[i]}
+		}
+	}
+	return best
+}
+
+func promotionLineCents(p *model.Promotion, unitCents, quantity int) (int, bool) {
+	switch p.Type {
+	case model.PromotionTypeFlashSale:
+		promoCents := int(p.PromoPrice.Cents())
+		if promoCents <= 0 {
+			return 0, false
+		}
+		return promoCents * quantity, true
+
+	case model.PromotionTypeBuyNGetM:
+		if p.BuyQuantity <= 0 || p.FreeQuantity <= 0 {
+			return 0, false
+		}
+		groups := quantity / (p.BuyQuantity + p.FreeQuantity)
+		return (quantity - groups*p.FreeQuantity) * unitCents, true
+
+	case model.PromotionTypeBundle:
+		bundleCents := int(p.BundlePrice.Cents())
+		if p.BundleQuantity <= 0 || bundleCents <= 0 {
+			return 0, false
+		}
+		groups := quantity / p.BundleQuantity
+		return groups*bundleCents + (quantity%p.BundleQuantity)*unitCents, true
+
+	case model.PromotionTypeTiered:
+		tiers := parsePromotionTiers(p.Tiers)
+		for _, tier := range tiers {
+			if quantity >= tier.MinQty {
+				return int(math.Round(float64(unitCents*quantity) * tier.Discount)), true
+			}
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// parsePromotionTiers 解析阶梯配置，按起购数量从高到低排列，忽略非法档位
+func parsePromotionTiers(raw string) []model.PromotionTier {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	var tiers []model.PromotionTier
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil {
+		return nil
+	}
+	valid := tiers[:0]
+	for _, t := range tiers {
+		if t.MinQty > 0 && t.Discount > 0 && t.Discount < 1 {
+			valid = append(valid, t)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool { return valid[i].MinQty > valid[j].MinQty })
+	return valid
+}
+
+// orderItemCents 订单行实际小计，兼容未记录 Amount 的历史订单
+func orderItemCents(item model.OrderItem) int {
+	if item.Amount > 0 || item.PromotionID > 0 {
+		return int(item.Amount.Cents())
+	}
+	return int(item.Price.Cents()) * item.Quantity
+}
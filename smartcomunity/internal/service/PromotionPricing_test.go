@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+
+	"smartcommunity/internal/model"
+)
+
+func TestPromotionLineCents(t *testing.T) {
+	const unitCents = 1000
+	tests := []struct {
+		name      string
+		promotion model.Promotion
+		unitCents int
+		quantity  int
+		want      int
+		wantOK    bool
+	}{
+		{name: "flash sale", promotion: model.Promotion{Type: model.PromotionTypeFlashSale, PromoPrice: 750}, quantity: 3, want: 2250, wantOK: true},
+		{name: "flash sale without price", promotion: model.Promotion{Type: model.PromotionTypeFlashSale}, quantity: 3},
+
+		{name: "buy 2 get 1 below threshold", promotion: model.Promotion{Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2, FreeQuantity: 1}, quantity: 2, want: 2000, wantOK: true},
+		{name: "buy 2 get 1 one group", promotion: model.Promotion{Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2, FreeQuantity: 1}, quantity: 3, want: 2000, wantOK: true},
+		{name: "buy 2 get 1 with leftover", promotion: model.Promotion{Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2, FreeQuantity: 1}, quantity: 5, want: 4000, wantOK: true},
+		{name: "buy 2 get 1 two groups", promotion: model.Promotion{Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2, FreeQuantity: 1}, quantity: 6, want: 4000, wantOK: true},
+		{name: "buy n get m without free quantity", promotion: model.Promotion{Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2}, quantity: 3},
+
+		{name: "bundle with remainder", promotion: model.Promotion{Type: model.PromotionTypeBundle, BundleQuantity: 3, BundlePrice: 2500}, quantity: 7, want: 6000, wantOK: true},
+		{name: "bundle below bundle size", promotion: model.Promotion{Type: model.PromotionTypeBundle, BundleQuantity: 3, BundlePrice: 2500}, quantity: 2, want: 2000, wantOK: true},
+		{name: "bundle without price", promotion: model.Promotion{Type: model.PromotionTypeBundle, BundleQuantity: 3}, quantity: 3},
+		{name: "bundle without size", promotion: model.Promotion{Type: model.PromotionTypeBundle, BundlePrice: 2500}, quantity: 3},
+
+		{name: "tiered below first tier", promotion: model.Promotion{Type: model.PromotionTypeTiered, Tiers: `[{"min_qty":2,"discount":0.9},{"min_qty":5,"discount":0.8}]`}, quantity: 1},
+		{name: "tiered first tier", promotion: model.Promotion{Type: model.PromotionTypeTiered, Tiers: `[{"min_qty":2,"discount":0.9},{"min_qty":5,"discount":0.8}]`}, quantity: 3, want: 2700, wantOK: true},
+		{name: "tiered highest tier wins", promotion: model.Promotion{Type: model.PromotionTypeTiered, Tiers: `[{"min_qty":2,"discount":0.9},{"min_qty":5,"discount":0.8}]`}, quantity: 5, want: 4000, wantOK: true},
+		{name: "tiered rounds to the cent", promotion: model.Promotion{Type: model.PromotionTypeTiered, Tiers: `[{"min_qty":3,"discount":0.85}]`}, unitCents: 333, quantity: 3, want: 849, wantOK: true},
+		{name: "tiered invalid config", promotion: model.Promotion{Type: model.PromotionTypeTiered, Tiers: `not json`}, quantity: 5},
+
+		{name: "markdown is display only", promotion: model.Promotion{Type: model.PromotionTypeMarkdown, PromoPrice: 500}, quantity: 1},
+	}
+	for _, tt := range tests {
+		unit := tt.unitCents
+		if unit == 0 {
+			unit = unitCents
+		}
+		got, ok := promotionLineCents(&tt.promotion, unit, tt.quantity)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("%s: promotionLineCents() = (%d, %v), want (%d, %v)", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPriceLine(t *testing.T) {
+	overlapping := []model.Promotion{
+		{ID: 1, Type: model.PromotionTypeFlashSale, PromoPrice: 900},
+		{ID: 2, Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2, FreeQuantity: 1},
+		{ID: 3, Type: model.PromotionTypeBundle, BundleQuantity: 3, BundlePrice: 2600},
+		{ID: 4, Type: model.PromotionTypeTiered, Tiers: `[{"min_qty":3,"discount":0.8},{"min_qty":6,"discount":0.6}]`},
+	}
+	tests := []struct {
+		name          string
+		promotions    []model.Promotion
+		quantity      int
+		wantCents     int
+		wantPromotion int64 // 0 表示按原价
+	}{
+		{name: "no promotion", quantity: 2, wantCents: 2000},
+		{name: "promotion worse than list price", promotions: []model.Promotion{{ID: 9, Type: model.PromotionTypeFlashSale, PromoPrice: 1200}}, quantity: 2, wantCents: 2000},
+		{name: "invalid promotions are ignored", promotions: []model.Promotion{
+			{ID: 7, Type: model.PromotionTypeFlashSale},
+			{ID: 8, Type: model.PromotionTypeBuyNGetM, BuyQuantity: 2},
+		}, quantity: 3, wantCents: 3000},
+		{name: "overlapping picks flash sale for one item", promotions: overlapping, quantity: 1, wantCents: 900, wantPromotion: 1},
+		{name: "overlapping picks buy n get m", promotions: overlapping, quantity: 3, wantCents: 2000, wantPromotion: 2},
+		{name: "overlapping tie keeps the earlier promotion", promotions: overlapping, quantity: 5, wantCents: 4000, wantPromotion: 2},
+		{name: "overlapping picks tiered discount", promotions: overlapping, quantity: 6, wantCents: 3600, wantPromotion: 4},
+		{name: "bundle beats flash sale", promotions: []model.Promotion{
+			{ID: 1, Type: model.PromotionTypeFlashSale, PromoPrice: 900},
+			{ID: 3, Type: model.PromotionTypeBundle, BundleQuantity: 2, BundlePrice: 1500},
+		}, quantity: 4, wantCents: 3000, wantPromotion: 3},
+	}
+	for _, tt := range tests {
+		got := priceLine(1000, tt.quantity, tt.promotions)
+		var gotPromotion int64
+		if got.Promotion != nil {
+			gotPromotion = got.Promotion.ID
+		}
+		if got.Cents != tt.wantCents || gotPromotion != tt.wantPromotion {
+			t.Errorf("%s: priceLine() = (%d, promotion %d), want (%d, promotion %d)", tt.name, got.Cents, gotPromotion, tt.wantCents, tt.wantPromotion)
+		}
+	}
+}
+
+func TestParsePromotionTiers(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []model.PromotionTier
+	}{
+		{name: "empty", raw: "  "},
+		{name: "invalid json", raw: "[{"},
+		{name: "sorted by min quantity desc", raw: `[{"min_qty":2,"discount":0.9},{"min_qty":10,"discount":0.7},{"min_qty":5,"discount":0.8}]`,
+			want: []model.PromotionTier{{MinQty: 10, Discount: 0.7}, {MinQty: 5, Discount: 0.8}, {MinQty: 2, Discount: 0.9}}},
+		{name: "invalid tiers dropped", raw: `[{"min_qty":0,"discount":0.9},{"min_qty":2,"discount":1},{"min_qty":3,"discount":0},{"min_qty":4,"discount":0.5}]`,
+			want: []model.PromotionTier{{MinQty: 4, Discount: 0.5}}},
+	}
+	for _, tt := range tests {
+		got := parsePromotionTiers(tt.raw)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: parsePromotionTiers() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: parsePromotionTiers() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestOrderItemCents(t *testing.T) {
+	tests := []struct {
+		name string
+		item model.OrderItem
+		want int
+	}{
+		{name: "promotion subtotal", item: model.OrderItem{Price: 1000, Quantity: 3, Amount: 2000, PromotionID: 2}, want: 2000},
+		{name: "subtotal without promotion", item: model.OrderItem{Price: 1000, Quantity: 3, Amount: 3000}, want: 3000},
+		{name: "free by promotion", item: model.OrderItem{Price: 1000, Quantity: 1, PromotionID: 2}, want: 0},
+		{name: "legacy item without amount", item: model.OrderItem{Price: 1250, Quantity: 2}, want: 2500},
+	}
+	for _, tt := range tests {
+		if got := orderItemCents(tt.item); got != tt.want {
+			t.Errorf("%s: orderItemCents() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+const promotionExpireScanInterval = 5 * time.Minute
+
+// StartPromotionExpiryScheduler 定期下线已结束的活动
+func StartPromotionExpiryScheduler() {
+	marketingService := &MarketingService{}
+
+	go func() {
+		log.Printf("promotion expiry scheduler armed, interval=%s", promotionExpireScanInterval)
+		ticker := time.NewTicker(promotionExpireScanInterval)
+		defer ticker.Stop()
+
+		for {
+			count, err := marketingService.ExpirePromotions()
+			if err != nil {
+				log.Printf("expire promotions failed: %v", err)
+			} else if count > 0 {
+				log.Printf("expired %d promotions", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const propertyBillingLockTTL = 10 * time.Minute
+
+type PropertyBillingService struct{}
+
+// PropertyBillingItem 单户出账结果
+type PropertyBillingItem struct {
+	HouseholdID int64       `json:"household_id"`
+	UserID      int64       `json:"user_id"`
+	Building    string      `json:"building"`
+	Unit        string      `json:"unit"`
+	RoomNo      string      `json:"room_no"`
+	Area        float64     `json:"area"`
+	Amount      model.Money `json:"amount"`
+	Detail      string      `json:"detail"`
+	Skipped     bool        `json:"skipped"`
+	SkipReason  string      `json:"skip_reason,omitempty"`
+}
+
+// BuildingBillingSummary 楼栋汇总
+type BuildingBillingSummary struct {
+	Building   string      `json:"building"`
+	Households int         `json:"households"`
+	Created    int         `json:"created"`
+	Skipped    int         `json:"skipped"`
+	Amount     model.Money `json:"amount"`
+}
+
+// PropertyBillingSummary 出账汇总，预览时 RunID 为 0 且不落库
+type PropertyBillingSummary struct {
+	RunID          int64                    `json:"run_id"`
+	Month          string                   `json:"month"`
+	DryRun         bool                     `json:"dry_run"`
+	HouseholdCount int                      `json:"household_count"`
+	CreatedCount   int                      `json:"created_count"`
+	SkippedCount   int                      `json:"skipped_count"`
+	TotalAmount    model.Money              `json:"total_amount"`
+	Buildings      []BuildingBillingSummary `json:"buildings"`
+	Items          []PropertyBillingItem    `json:"items"`
+}
+
+// SaveHousehold 新增或修改房屋
+func (s *PropertyBillingService) SaveHousehold(h *model.Household) error {
+	h.Building = strings.TrimSpace(h.Building)
+	h.Unit = strings.TrimSpace(h.Unit)
+	h.RoomNo = strings.TrimSpace(h.RoomNo)
+	if h.Building == "" || h.RoomNo == "" {
+		return errors.New("楼栋和房号不能为空")
+	}
+	if h.Area < 0 {
+		return errors.New("面积不能为负数")
+	}
+	if h.ID > 0 {
+		return global.DB.Model(&model.Household{}).Where("id = ?", h.ID).
+			Select("building", "unit", "room_no", "area", "user_id", "status").
+			Updates(h).Error
+	}
+	return global.DB.Create(h).Error
+}
+
+func (s *PropertyBillingService) DeleteHousehold(id int64) error {
+	return global.DB.Delete(&model.Household{}, id).Error
+}
+
+// ListHouseholds 房屋列表，building 为空时返回全部
+func (s *PropertyBillingService) ListHouseholds(building string, page, size int) ([]model.Household, int64, error) {
+	var list []model.Household
+	var total int64
+	db := global.DB.Model(&model.Household{})
+	if building != "" {
+		db = db.Where("building = ?", building)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("SysUser", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, real_name, mobile") }).
+		Order("building asc, unit asc, room_no asc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// SaveRate 新增或修改收费标准
+func (s *PropertyBillingService) SaveRate(rate *model.PropertyFeeRate) error {
+	rate.Name = strings.TrimSpace(rate.Name)
+	rate.Building = strings.TrimSpace(rate.Building)
+	if rate.Name == "" {
+		return errors.New("收费项目名称不能为空")
+	}
+	if rate.Type != model.FeeRateTypePerSqm && rate.Type != model.FeeRateTypeFixed {
+		return errors.New("不支持的计费方式")
+	}
+	if rate.UnitPrice <= 0 {
+		return errors.New("单价必须大于0")
+	}
+	if rate.ID > 0 {
+		return global.DB.Model(&model.PropertyFeeRate{}).Where("id = ?", rate.ID).
+			Select("name", "type", "unit_price", "building", "status").
+			Updates(rate).Error
+	}
+	return global.DB.Create(rate).Error
+}
+
+func (s *PropertyBillingService) DeleteRate(id int64) error {
+	return global.DB.Delete(&model.PropertyFeeRate{}, id).Error
+}
+
+func (s *PropertyBillingService) ListRates() ([]model.PropertyFeeRate, error) {
+	var list []model.PropertyFeeRate
+	err := global.DB.Order("building asc, id asc").Find(&list).Error
+	return list, err
+}
+
+// ListBillingRuns 出账记录
+func (s *PropertyBillingService) ListBillingRuns(page, size int) ([]model.PropertyBillingRun, int64, error) {
+	var list []model.PropertyBillingRun
+	var total int64
+	db := global.DB.Model(&model.PropertyBillingRun{})
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// RunBilling 按收费标准为所有正常出账的房屋生成当月物业费，已出过账的房屋跳过。
+// dryRun 为 true 时只计算并返回预览，不写入数据。
+func (s *PropertyBillingService) RunBilling(operatorID int64, month, building string, dryRun bool) (*PropertyBillingSummary, error) {
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return nil, errors.New("账期格式应为 YYYY-MM")
+	}
+
+	if !dryRun {
+		lockKey := "property:billing:" + month
+		ok, err := global.RDB.SetNX(context.Background(), lockKey, operatorID, propertyBillingLockTTL).Result()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("该账期正在出账，请稍后再试")
+		}
+		defer global.RDB.Del(context.Background(), lockKey)
+	}
+
+	dueDate, err := propertyMonthDueDate(month)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary *PropertyBillingSummary
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		summary, err = buildPropertyBilling(tx, month, building)
+		if err != nil || dryRun {
+			return err
+		}
+
+		buildings, _ := json.Marshal(summary.Buildings)
+		run := &model.PropertyBillingRun{
+			Month:          month,
+			OperatorID:     operatorID,
+			HouseholdCount: summary.HouseholdCount,
+			CreatedCount:   summary.CreatedCount,
+			SkippedCount:   summary.SkippedCount,
+			TotalAmount:    summary.TotalAmount,
+			Summary:        string(buildings),
+			CreatedAt:      time.Now(),
+		}
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+		summary.RunID = run.ID
+
+		fees := make([]model.PropertyFee, 0, summary.CreatedCount)
+		for _, item := range summary.Items {
+			if item.Skipped {
+				continue
+			}
+			fees = append(fees, model.PropertyFee{
+				UserID:       item.UserID,
+				HouseholdID:  item.HouseholdID,
+				BillingRunID: run.ID,
+				Month:        month,
+				Amount:       item.Amount,
+				Detail:       item.Detail,
+				DueDate:      &dueDate,
+				Status:       0,
+			})
+		}
+		if len(fees) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(fees, 200).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	summary.DryRun = dryRun
+	return summary, nil
+}
+
+func buildPropertyBilling(tx *gorm.DB, month, building string) (*PropertyBillingSummary, error) {
+	householdQuery := tx.Where("status = ?", 1)
+	if building != "" {
+		householdQuery = householdQuery.Where("building = ?", building)
+	}
+	var households []model.Household
+	if err := householdQuery.Order("building asc, unit asc, room_no asc").Find(&households).Error; err != nil {
+		return nil, err
+	}
+
+	var rates []model.PropertyFeeRate
+	if err := tx.Where("status = ?", 1).Order("id asc").Find(&rates).Error; err != nil {
+		return nil, err
+	}
+
+	// 已出账的房屋，以及手工为业主录入过当月账单的(历史数据没有房屋ID)
+	var billedHouseholds, billedUsers []int64
+	if err := tx.Model(&model.PropertyFee{}).Where("month = ? AND household_id > 0", month).
+		Pluck("household_id", &billedHouseholds).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&model.PropertyFee{}).Where("month = ? AND household_id = 0", month).
+		Pluck("user_id", &billedUsers).Error; err != nil {
+		return nil, err
+	}
+	billedHouseholdSet := make(map[int64]bool, len(billedHouseholds))
+	for _, id := range billedHouseholds {
+		billedHouseholdSet[id] = true
+	}
+	billedUserSet := make(map[int64]bool, len(billedUsers))
+	for _, id := range billedUsers {
+		billedUserSet[id] = true
+	}
+
+	summary := &PropertyBillingSummary{Month: month, HouseholdCount: len(households)}
+	byBuilding := map[string]*BuildingBillingSummary{}
+	for _, h := range households {
+		item := PropertyBillingItem{
+			HouseholdID: h.ID,
+			UserID:      h.UserID,
+			Building:    h.Building,
+			Unit:        h.Unit,
+			RoomNo:      h.RoomNo,
+			Area:        h.Area,
+		}
+		switch {
+		case h.UserID == 0:
+			item.Skipped, item.SkipReason = true, "未绑定业主"
+		case billedHouseholdSet[h.ID] || billedUserSet[h.UserID]:
+			item.Skipped, item.SkipReason = true, "当月已出账"
+		default:
+			item.Amount, item.Detail = calcHouseholdFee(&h, rates)
+			if item.Amount <= 0 {
+				item.Skipped, item.SkipReason = true, "无适用收费标准"
+			}
+		}
+
+		b, ok := byBuilding[h.Building]
+		if !ok {
+			b = &BuildingBillingSummary{Building: h.Building}
+			byBuilding[h.Building] = b
+		}
+		b.Households++
+		if item.Skipped {
+			b.Skipped++
+			summary.SkippedCount++
+		} else {
+			b.Created++
+			b.Amount += item.Amount
+			summary.CreatedCount++
+			summary.TotalAmount += item.Amount
+		}
+		summary.Items = append(summary.Items, item)
+	}
+
+	for _, b := range byBuilding {
+		summary.Buildings = append(summary.Buildings, *b)
+	}
+	sort.Slice(summary.Buildings, func(i, j int) bool { return summary.Buildings[i].Building < summary.Buildings[j].Building })
+	return summary, nil
+}
+
+// calcHouseholdFee 汇总适用于该房屋的收费项目，同名项目楼栋标准覆盖通用标准
+func calcHouseholdFee(h *model.Household, rates []model.PropertyFeeRate) (model.Money, string) {
+	applicable := map[string]model.PropertyFeeRate{}
+	var names []string
+	for _, rate := range rates {
+		if rate.Building != "" && rate.Building != h.Building {
+			continue
+		}
+		existing, ok := applicable[rate.Name]
+		if !ok {
+			names = append(names, rate.Name)
+		}
+		if !ok || (existing.Building == "" && rate.Building != "") {
+			applicable[rate.Name] = rate
+		}
+	}
+
+	var total model.Money
+	details := make([]string, 0, len(names))
+	for _, name := range names {
+		rate := applicable[name]
+		switch rate.Type {
+		case model.FeeRateTypePerSqm:
+			amount := rate.UnitPrice.MulRate(h.Area)
+			total += amount
+			details = append(details, fmt.Sprintf("%s %s/㎡ x %.2f㎡ = %s", name, rate.UnitPrice, h.Area, amount))
+		case model.FeeRateTypeFixed:
+			total += rate.UnitPrice
+			details = append(details, fmt.Sprintf("%s %s", name, rate.UnitPrice))
+		}
+	}
+	return total, strings.Join(details, "; ")
+}
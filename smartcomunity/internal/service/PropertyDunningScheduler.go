@@ -0,0 +1,43 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+// 催缴提醒放在白天发送，避免夜间打扰业主
+const propertyRemindHour = 10
+
+// StartPropertyDunningScheduler 每天上午检查逾期物业费并发送催缴提醒
+func StartPropertyDunningScheduler() {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		log.Printf("load Asia/Shanghai location failed, fallback to local: %v", err)
+		location = time.Local
+	}
+
+	billingService := &PropertyBillingService{}
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			nextRun := time.Date(now.Year(), now.Month(), now.Day(), propertyRemindHour, 0, 0, 0, location)
+			if !now.Before(nextRun) {
+				nextRun = nextRun.AddDate(0, 0, 1)
+			}
+
+			log.Printf("property dunning scheduler armed, next run at %s", nextRun.Format("2006-01-02 15:04:05"))
+			timer := time.NewTimer(time.Until(nextRun))
+			<-timer.C
+
+			sent, err := billingService.RemindOverduePropertyFees()
+			if err != nil {
+				log.Printf("property fee reminders failed after %d sent: %v", sent, err)
+				continue
+			}
+			if sent > 0 {
+				log.Printf("property fee reminders sent for %d bills", sent)
+			}
+		}
+	}()
+}
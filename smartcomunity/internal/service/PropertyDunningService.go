@@ -0,0 +1,297 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPropertyDueDay = 15
+	propertyRemindBatch   = 500
+)
+
+var defaultPropertyRemindDays = []int{1, 7, 15}
+
+// PropertyArrearsMonth 某户某账期的欠费
+type PropertyArrearsMonth struct {
+	FeeID       int64       `json:"fee_id"`
+	Month       string      `json:"month"`
+	DueDate     time.Time   `json:"due_date"`
+	OverdueDays int         `json:"overdue_days"`
+	Amount      model.Money `json:"amount"`
+	LateFee     model.Money `json:"late_fee"`
+	RemindStage int         `json:"remind_stage"`
+}
+
+// PropertyArrearsUser 按业主汇总的欠费
+type PropertyArrearsUser struct {
+	UserID   int64                  `json:"user_id"`
+	Username string                 `json:"username"`
+	RealName string                 `json:"real_name"`
+	Mobile   string                 `json:"mobile"`
+	Amount   model.Money            `json:"amount"`
+	LateFee  model.Money            `json:"late_fee"`
+	Total    model.Money            `json:"total"`
+	Months   []PropertyArrearsMonth `json:"months"`
+}
+
+// PropertyArrearsReport 欠费报表
+type PropertyArrearsReport struct {
+	UserCount int                   `json:"user_count"`
+	BillCount int                   `json:"bill_count"`
+	Amount    model.Money           `json:"amount"`
+	LateFee   model.Money           `json:"late_fee"`
+	Total     model.Money           `json:"total"`
+	List      []PropertyArrearsUser `json:"list"`
+}
+
+func propertyConfig() config.PropertyConfig {
+	var cfg config.PropertyConfig
+	if config.Conf != nil {
+		cfg = config.Conf.Property
+	}
+	if cfg.DueDay <= 0 {
+		cfg.DueDay = defaultPropertyDueDay
+	}
+	if len(cfg.RemindDays) == 0 {
+		cfg.RemindDays = defaultPropertyRemindDays
+	}
+	return cfg
+}
+
+// propertyMonthDueDate 账期当月的到期日（当天结束前有效），日数超出当月天数时取月末
+func propertyMonthDueDate(month string) (time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", month, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("账期格式应为 YYYY-MM")
+	}
+	day := propertyConfig().DueDay
+	if last := start.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(start.Year(), start.Month(), day, 23, 59, 59, 0, time.Local), nil
+}
+
+// propertyFeeDueDate 早期账单没有到期日，按账期推算
+func propertyFeeDueDate(fee *model.PropertyFee) (time.Time, bool) {
+	if fee.DueDate != nil {
+		return *fee.DueDate, true
+	}
+	due, err := propertyMonthDueDate(fee.Month)
+	return due, err == nil
+}
+
+// propertyLateFee 计算截至 now 的逾期天数和应收滞纳金，宽限期内不计
+func propertyLateFee(fee *model.PropertyFee, now time.Time) (int, model.Money) {
+	due, ok := propertyFeeDueDate(fee)
+	if !ok || !now.After(due) {
+		return 0, 0
+	}
+	cfg := propertyConfig()
+	overdueDays := int(math.Ceil(now.Sub(due).Hours() / 24))
+	chargeDays := overdueDays - cfg.GraceDays
+	if chargeDays <= 0 || cfg.LateFeeDailyRate <= 0 {
+		return overdueDays, 0
+	}
+	lateFee := fee.Amount.MulRate(cfg.LateFeeDailyRate * float64(chargeDays))
+	if cfg.LateFeeCapRate > 0 {
+		if limit := fee.Amount.MulRate(cfg.LateFeeCapRate); lateFee > limit {
+			lateFee = limit
+		}
+	}
+	return overdueDays, lateFee
+}
+
+// fillPropertyFeeDue 为未缴账单填充逾期天数、滞纳金和应缴合计
+func fillPropertyFeeDue(list []model.PropertyFee) {
+	now := time.Now()
+	for i := range list {
+		fee := &list[i]
+		if fee.DueDate == nil {
+			if due, ok := propertyFeeDueDate(fee); ok {
+				fee.DueDate = &due
+			}
+		}
+		if fee.Status == 1 {
+			fee.PayableAmount = fee.Amount + fee.LateFee
+			continue
+		}
+		fee.OverdueDays, fee.AccruedLateFee = propertyLateFee(fee, now)
+		fee.PayableAmount = fee.Amount + fee.AccruedLateFee
+	}
+}
+
+// RemindOverduePropertyFees 按逾期天数节点发送催缴提醒，每个节点只提醒一次，返回本次提醒的账单数
+func (s *PropertyBillingService) RemindOverduePropertyFees() (int, error) {
+	cfg := propertyConfig()
+	stages := append([]int(nil), cfg.RemindDays...)
+	sort.Ints(stages)
+
+	now := time.Now()
+	sent := 0
+	var lastID int64
+	for {
+		var fees []model.PropertyFee
+		err := global.DB.Where("status = 0 AND remind_stage < ? AND id > ?", len(stages), lastID).
+			Order("id asc").Limit(propertyRemindBatch).Find(&fees).Error
+		if err != nil {
+			return sent, err
+		}
+		if len(fees) == 0 {
+			return sent, nil
+		}
+		lastID = fees[len(fees)-1].ID
+
+		for i := range fees {
+			fee := &fees[i]
+			overdueDays, lateFee := propertyLateFee(fee, now)
+			stage := fee.RemindStage
+			for stage < len(stages) && overdueDays >= stages[stage] {
+				stage++
+			}
+			if stage == fee.RemindStage {
+				continue
+			}
+			if err := s.remindPropertyFee(fee, stage, overdueDays, lateFee, cfg.SMSFromStage, now); err != nil {
+				log.Printf("remind property fee %d failed: %v", fee.ID, err)
+				continue
+			}
+			sent++
+		}
+	}
+}
+
+func (s *PropertyBillingService) remindPropertyFee(fee *model.PropertyFee, stage, overdueDays int, lateFee model.Money, smsFromStage int, now time.Time) error {
+	content := fmt.Sprintf("您 %s 的物业费 %s 元已逾期 %d 天", fee.Month, fee.Amount.String(), overdueDays)
+	if lateFee > 0 {
+		content += fmt.Sprintf("，当前滞纳金 %s 元，合计应缴 %s 元", lateFee.String(), (fee.Amount + lateFee).String())
+	}
+	content += "，请尽快缴纳。"
+
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		// 条件更新防止并发重复提醒
+		res := tx.Model(&model.PropertyFee{}).
+			Where("id = ? AND status = 0 AND remind_stage = ?", fee.ID, fee.RemindStage).
+			Updates(map[string]interface{}{"remind_stage": stage, "reminded_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("账单状态已变化")
+		}
+		return sendUserNotice(tx, fee.UserID, "物业费催缴提醒", content, "property_fee", fee.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if smsFromStage > 0 && stage >= smsFromStage {
+		var user model.SysUser
+		if err := global.DB.Select("id, mobile").First(&user, fee.UserID).Error; err == nil && user.Mobile != "" {
+			if err := sendSMSNotice(user.Mobile, content); err != nil {
+				log.Printf("send property fee reminder sms to user %d failed: %v", fee.UserID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ArrearsReport 已过到期日的未缴物业费，按业主和账期汇总，按欠费合计从高到低排序
+func (s *PropertyBillingService) ArrearsReport(building string, page, size int) (*PropertyArrearsReport, error) {
+	db := global.DB.Model(&model.PropertyFee{}).Where("cms_property_fee.status = 0")
+	if building != "" {
+		db = db.Joins("JOIN cms_household ON cms_household.id = cms_property_fee.household_id").
+			Where("cms_household.building = ?", building)
+	}
+	var fees []model.PropertyFee
+	if err := db.Select("cms_property_fee.*").Order("cms_property_fee.month asc").Find(&fees).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &PropertyArrearsReport{List: []PropertyArrearsUser{}}
+	byUser := make(map[int64]*PropertyArrearsUser)
+	for i := range fees {
+		fee := &fees[i]
+		due, ok := propertyFeeDueDate(fee)
+		if !ok || !now.After(due) {
+			continue
+		}
+		overdueDays, lateFee := propertyLateFee(fee, now)
+		row, exists := byUser[fee.UserID]
+		if !exists {
+			row = &PropertyArrearsUser{UserID: fee.UserID}
+			byUser[fee.UserID] = row
+		}
+		row.Months = append(row.Months, PropertyArrearsMonth{
+			FeeID:       fee.ID,
+			Month:       fee.Month,
+			DueDate:     due,
+			OverdueDays: overdueDays,
+			Amount:      fee.Amount,
+			LateFee:     lateFee,
+			RemindStage: fee.RemindStage,
+		})
+		row.Amount += fee.Amount
+		row.LateFee += lateFee
+		row.Total += fee.Amount + lateFee
+
+		report.BillCount++
+		report.Amount += fee.Amount
+		report.LateFee += lateFee
+		report.Total += fee.Amount + lateFee
+	}
+
+	rows := make([]PropertyArrearsUser, 0, len(byUser))
+	for _, row := range byUser {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Total != rows[j].Total {
+			return rows[i].Total > rows[j].Total
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+	report.UserCount = len(rows)
+
+	offset := (page - 1) * size
+	if offset >= len(rows) {
+		return report, nil
+	}
+	end := offset + size
+	if end > len(rows) {
+		end = len(rows)
+	}
+	rows = rows[offset:end]
+
+	userIDs := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		userIDs = append(userIDs, row.UserID)
+	}
+	var users []model.SysUser
+	if err := global.DB.Select("id, username, real_name, mobile").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userMap := make(map[int64]model.SysUser, len(users))
+	for _, u := range users {
+		userMap[u.ID] = u
+	}
+	for i := range rows {
+		u := userMap[rows[i].UserID]
+		rows[i].Username = u.Username
+		rows[i].RealName = u.RealName
+		rows[i].Mobile = u.Mobile
+	}
+	report.List = rows
+	return report, nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+
+	"smartcommunity/internal/model"
+	"smartcommunity/pkg/utils"
+)
+
+// 单页收据最多列出的明细行，超出部分合并为一行
+const receiptMaxPDFItems = 30
+
+var receiptHTMLTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>电子收据 {{.ReceiptNo}}</title>
+<style>
+body { font-family: "PingFang SC", "Microsoft YaHei", sans-serif; margin: 40px; color: #333; }
+h1 { text-align: center; margin-bottom: 4px; }
+.sub { text-align: center; color: #888; margin-bottom: 24px; }
+.meta td { padding: 4px 16px 4px 0; }
+table.items { width: 100%; border-collapse: collapse; margin-top: 16px; }
+table.items th, table.items td { border-bottom: 1px solid #ddd; padding: 8px; text-align: left; }
+table.items td.num { text-align: right; }
+.summary { margin-top: 16px; text-align: right; line-height: 1.8; }
+.void { color: #c00; font-size: 28px; font-weight: bold; text-align: center; border: 3px solid #c00; padding: 8px; margin: 16px auto; width: 240px; }
+</style>
+</head>
+<body>
+<h1>智慧社区电子收据</h1>
+<div class="sub">收据编号 {{.ReceiptNo}}</div>
+{{if .Void}}<div class="void">已作废</div>{{end}}
+<table class="meta">
+<tr><td>付款人</td><td>{{.PayerName}} {{.PayerMobile}}</td></tr>
+<tr><td>收款事项</td><td>{{.Title}}</td></tr>
+<tr><td>支付时间</td><td>{{.PaidAt}}</td></tr>
+<tr><td>开具时间</td><td>{{.IssuedAt}}</td></tr>
+{{if .ReissuedFrom}}<tr><td>备注</td><td>补开收据，原收据已作废</td></tr>{{end}}
+{{if .Void}}<tr><td>作废原因</td><td>{{.VoidReason}}</td></tr>{{end}}
+</table>
+<table class="items">
+<tr><th>项目</th><th>数量</th><th>单价</th><th>金额</th></tr>
+{{range .Items}}<tr><td>{{.Name}}</td><td class="num">{{.Quantity}}</td><td class="num">{{.Price}}</td><td class="num">{{.Amount}}</td></tr>
+{{end}}</table>
+<div class="summary">
+<div>合计：{{.TotalAmount}} 元</div>
+<div>积分抵扣：{{.UsedPoints}} 积分，折合 {{.PointsDeduct}} 元</div>
+<div>余额支付：{{.UsedBalance}} 元</div>
+</div>
+</body>
+</html>
+`))
+
+type receiptView struct {
+	ReceiptNo    string
+	PayerName    string
+	PayerMobile  string
+	Title        string
+	PaidAt       string
+	IssuedAt     string
+	Items        []model.ReceiptItem
+	TotalAmount  string
+	UsedPoints   int
+	PointsDeduct string
+	UsedBalance  string
+	ReissuedFrom int64
+	Void         bool
+	VoidReason   string
+}
+
+func newReceiptView(r *model.Receipt) receiptView {
+	return receiptView{
+		ReceiptNo:    r.ReceiptNo,
+		PayerName:    r.PayerName,
+		PayerMobile:  r.PayerMobile,
+		Title:        r.Title,
+		PaidAt:       r.PaidAt.Format("2006-01-02 15:04:05"),
+		IssuedAt:     r.CreatedAt.Format("2006-01-02 15:04:05"),
+		Items:        r.ItemList,
+		TotalAmount:  r.TotalAmount.String(),
+		UsedPoints:   r.UsedPoints,
+		PointsDeduct: r.PointsDeduct.String(),
+		UsedBalance:  r.UsedBalance.String(),
+		ReissuedFrom: r.ReissuedFrom,
+		Void:         r.Status == model.ReceiptStatusVoid,
+		VoidReason:   r.VoidReason,
+	}
+}
+
+// renderReceipt 生成收据的 HTML 和 PDF 两种格式
+func renderReceipt(r *model.Receipt) ([]byte, []byte, error) {
+	view := newReceiptView(r)
+
+	var html bytes.Buffer
+	if err := receiptHTMLTemplate.Execute(&html, view); err != nil {
+		return nil, nil, err
+	}
+	return html.Bytes(), renderReceiptPDF(view), nil
+}
+
+func renderReceiptPDF(v receiptView) []byte {
+	const left, right = 60.0, utils.PDFPageWidth - 60
+	pdf := utils.NewSimplePDF()
+	y := utils.PDFPageHeight - 70
+
+	title := "智慧社区电子收据"
+	pdf.Text((utils.PDFPageWidth-pdf.TextWidth(title, 20))/2, y, 20, title)
+	y -= 22
+	sub := "收据编号 " + v.ReceiptNo
+	pdf.Text((utils.PDFPageWidth-pdf.TextWidth(sub, 10))/2, y, 10, sub)
+	y -= 30
+	if v.Void {
+		pdf.Text(right-pdf.TextWidth("已作废", 18), utils.PDFPageHeight-70, 18, "已作废")
+	}
+
+	meta := [][2]string{
+		{"付款人", v.PayerName + " " + v.PayerMobile},
+		{"收款事项", v.Title},
+		{"支付时间", v.PaidAt},
+		{"开具时间", v.IssuedAt},
+	}
+	if v.ReissuedFrom > 0 {
+		meta = append(meta, [2]string{"备注", "补开收据，原收据已作废"})
+	}
+	if v.Void {
+		meta = append(meta, [2]string{"作废原因", v.VoidReason})
+	}
+	for _, m := range meta {
+		pdf.Text(left, y, 11, m[0])
+		pdf.Text(left+70, y, 11, m[1])
+		y -= 18
+	}
+
+	y -= 10
+	cols := []float64{left, 360, 420, 490}
+	pdf.Line(left, y+14, right, y+14)
+	for i, h := range []string{"项目", "数量", "单价", "金额"} {
+		pdf.Text(cols[i], y, 11, h)
+	}
+	pdf.Line(left, y-6, right, y-6)
+	y -= 22
+
+	items := v.Items
+	var rest []model.ReceiptItem
+	if len(items) > receiptMaxPDFItems {
+		items, rest = items[:receiptMaxPDFItems-1], items[receiptMaxPDFItems-1:]
+	}
+	for _, item := range items {
+		pdf.Text(cols[0], y, 10, truncateReceiptText(item.Name, 24))
+		pdf.Text(cols[1], y, 10, fmt.Sprintf("%d", item.Quantity))
+		pdf.Text(cols[2], y, 10, item.Price.String())
+		pdf.Text(cols[3], y, 10, item.Amount.String())
+		y -= 16
+	}
+	if len(rest) > 0 {
+		var amount model.Money
+		for _, item := range rest {
+			amount += item.Amount
+		}
+		pdf.Text(cols[0], y, 10, fmt.Sprintf("其余 %d 项", len(rest)))
+		pdf.Text(cols[3], y, 10, amount.String())
+		y -= 16
+	}
+	pdf.Line(left, y+8, right, y+8)
+
+	y -= 14
+	for _, line := range []string{
+		"合计：" + v.TotalAmount + " 元",
+		fmt.Sprintf("积分抵扣：%d 积分，折合 %s 元", v.UsedPoints, v.PointsDeduct),
+		"余额支付：" + v.UsedBalance + " 元",
+	} {
+		pdf.Text(right-pdf.TextWidth(line, 11), y, 11, line)
+		y -= 18
+	}
+	return pdf.Bytes()
+}
+
+func truncateReceiptText(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
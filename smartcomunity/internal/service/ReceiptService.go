@@ -0,0 +1,444 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReceiptService struct{}
+
+const (
+	ReceiptFormatHTML = "html"
+	ReceiptFormatPDF  = "pdf"
+
+	receiptSeqTTL       = 48 * time.Hour
+	receiptSeqDigits    = 6
+	receiptIssueRetries = 3
+)
+
+// receiptSeqScript 当日计数落后于种子值(Key 丢失或与库中编号冲突)时先抬到种子值，再自增取号
+var receiptSeqScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+local seed = tonumber(ARGV[1])
+if current < seed then
+	redis.call('SET', KEYS[1], seed)
+end
+local seq = redis.call('INCR', KEYS[1])
+if redis.call('TTL', KEYS[1]) < 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return seq
+`)
+
+var (
+	ErrReceiptNotFound = errors.New("收据不存在")
+	ErrReceiptVoided   = errors.New("收据已作废")
+)
+
+// IssueForPayment 支付成功后开具收据，同一笔业务已有有效收据时直接返回
+func (s *ReceiptService) IssueForPayment(userID int64, result *MixedPaymentResult) (*model.Receipt, error) {
+	bizType, err := receiptBizType(result.PayType)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing model.Receipt
+	err = global.DB.Where("biz_type = ? AND biz_id = ? AND status = ?", bizType, result.BusinessID, model.ReceiptStatusValid).
+		First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	receipt, err := buildReceipt(userID, bizType, result.BusinessID)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TotalAmount = result.TotalAmount
+	receipt.UsedPoints = result.UsedPoints
+	receipt.PointsDeduct = model.Money(result.UsedPoints * CentsPerGreenPoint)
+	receipt.UsedBalance = result.UsedBalance
+	return s.issue(receipt)
+}
+
+// List 用户查看自己的收据
+func (s *ReceiptService) List(userID int64, page, size int) ([]model.Receipt, int64, error) {
+	return s.listReceipts(global.DB.Where("user_id = ?", userID), page, size)
+}
+
+// AdminList 后台按用户、业务类型、状态或收据号查询
+func (s *ReceiptService) AdminList(userID int64, bizType string, status int, receiptNo string, page, size int) ([]model.Receipt, int64, error) {
+	db := global.DB
+	if userID > 0 {
+		db = db.Where("user_id = ?", userID)
+	}
+	if bizType != "" {
+		db = db.Where("biz_type = ?", bizType)
+	}
+	if status > 0 {
+		db = db.Where("status = ?", status)
+	}
+	if receiptNo != "" {
+		db = db.Where("receipt_no = ?", receiptNo)
+	}
+	return s.listReceipts(db, page, size)
+}
+
+func (s *ReceiptService) listReceipts(db *gorm.DB, page, size int) ([]model.Receipt, int64, error) {
+	var list []model.Receipt
+	var total int64
+	db = db.Model(&model.Receipt{})
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	for i := range list {
+		decodeReceiptItems(&list[i])
+	}
+	return list, total, err
+}
+
+// Get 查询收据，ownerID > 0 时只能查看本人的收据
+func (s *ReceiptService) Get(ownerID, id int64) (*model.Receipt, error) {
+	db := global.DB.Where("id = ?", id)
+	if ownerID > 0 {
+		db = db.Where("user_id = ?", ownerID)
+	}
+	var receipt model.Receipt
+	if err := db.First(&receipt).Error; err != nil {
+		return nil, ErrReceiptNotFound
+	}
+	decodeReceiptItems(&receipt)
+	return &receipt, nil
+}
+
+// Download 返回收据文件内容；存储中缺失时按快照重新生成并补存
+func (s *ReceiptService) Download(ownerID, id int64, format string) (*model.Receipt, []byte, error) {
+	if format != ReceiptFormatHTML && format != ReceiptFormatPDF {
+		return nil, nil, errors.New("不支持的收据格式")
+	}
+	receipt, err := s.Get(ownerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := receipt.PDFKey
+	if format == ReceiptFormatHTML {
+		key = receipt.HTMLKey
+	}
+	if key != "" {
+		data, err := (&StorageService{}).GetBytes(key)
+		if err == nil && len(data) > 0 {
+			return receipt, data, nil
+		}
+		log.Printf("load receipt %s file %s failed, regenerate: %v", receipt.ReceiptNo, key, err)
+	}
+
+	htmlData, pdfData, err := renderReceipt(receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.storeFiles(receipt, htmlData, pdfData)
+	if format == ReceiptFormatHTML {
+		return receipt, htmlData, nil
+	}
+	return receipt, pdfData, nil
+}
+
+// Void 作废收据，重新生成带作废标记的文件
+func (s *ReceiptService) Void(operatorID, id int64, reason string) (*model.Receipt, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("请填写作废原因")
+	}
+
+	var receipt model.Receipt
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		return voidReceipt(tx, &receipt, operatorID, id, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	decodeReceiptItems(&receipt)
+	if htmlData, pdfData, err := renderReceipt(&receipt); err == nil {
+		s.storeFiles(&receipt, htmlData, pdfData)
+	} else {
+		log.Printf("render voided receipt %s failed: %v", receipt.ReceiptNo, err)
+	}
+	return &receipt, nil
+}
+
+// Reissue 作废原收据并按业务单据的当前信息补开一张新收据，支付金额沿用原收据
+func (s *ReceiptService) Reissue(operatorID, id int64, reason string) (*model.Receipt, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = "补开"
+	}
+
+	var old model.Receipt
+	if err := global.DB.First(&old, id).Error; err != nil {
+		return nil, ErrReceiptNotFound
+	}
+	if old.Status == model.ReceiptStatusVoid {
+		// 同一笔业务已有有效收据时不再补开
+		var count int64
+		global.DB.Model(&model.Receipt{}).
+			Where("biz_type = ? AND biz_id = ? AND status = ?", old.BizType, old.BizID, model.ReceiptStatusValid).
+			Count(&count)
+		if count > 0 {
+			return nil, errors.New("该业务已有有效收据")
+		}
+	}
+
+	receipt, err := buildReceipt(old.UserID, old.BizType, old.BizID)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TotalAmount = old.TotalAmount
+	receipt.UsedPoints = old.UsedPoints
+	receipt.PointsDeduct = old.PointsDeduct
+	receipt.UsedBalance = old.UsedBalance
+	receipt.PaidAt = old.PaidAt
+	receipt.ReissuedFrom = old.ID
+
+	if old.Status == model.ReceiptStatusValid {
+		if _, err := s.Void(operatorID, old.ID, "补开作废: "+reason); err != nil {
+			return nil, err
+		}
+	}
+	return s.issue(receipt)
+}
+
+func voidReceipt(tx *gorm.DB, receipt *model.Receipt, operatorID, id int64, reason string) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(receipt, id).Error; err != nil {
+		return ErrReceiptNotFound
+	}
+	if receipt.Status == model.ReceiptStatusVoid {
+		return ErrReceiptVoided
+	}
+	now := time.Now()
+	receipt.Status = model.ReceiptStatusVoid
+	receipt.VoidReason = reason
+	receipt.VoidedBy = operatorID
+	receipt.VoidedAt = &now
+	return tx.Model(receipt).Updates(map[string]interface{}{
+		"status":      receipt.Status,
+		"void_reason": reason,
+		"voided_by":   operatorID,
+		"voided_at":   &now,
+	}).Error
+}
+
+// issue 编号并落库，文件上传失败不影响收据本身，下载时会按快照重新生成
+func (s *ReceiptService) issue(receipt *model.Receipt) (*model.Receipt, error) {
+	items, _ := json.Marshal(receipt.ItemList)
+	receipt.Items = string(items)
+	receipt.Status = model.ReceiptStatusValid
+	receipt.CreatedAt = time.Now()
+	// 编号与库中已有收据冲突时按库中最大编号重新取号
+	for attempt := 1; ; attempt++ {
+		receiptNo, err := nextReceiptNo(attempt > 1)
+		if err != nil {
+			log.Printf("receipt sequence unavailable: %v", err)
+			return nil, errors.New("收据编号生成失败，请稍后重试")
+		}
+		receipt.ReceiptNo = receiptNo
+		err = global.DB.Create(receipt).Error
+		if err == nil {
+			break
+		}
+		if !isDuplicateKeyError(err) || attempt >= receiptIssueRetries {
+			return nil, err
+		}
+		receipt.ID = 0
+	}
+
+	htmlData, pdfData, err := renderReceipt(receipt)
+	if err != nil {
+		log.Printf("render receipt %s failed: %v", receipt.ReceiptNo, err)
+		return receipt, nil
+	}
+	s.storeFiles(receipt, htmlData, pdfData)
+	return receipt, nil
+}
+
+func (s *ReceiptService) storeFiles(receipt *model.Receipt, htmlData, pdfData []byte) {
+	if global.MinioClient == nil {
+		return
+	}
+	storage := &StorageService{}
+	dir := fmt.Sprintf("receipts/%s", receipt.CreatedAt.Format("200601"))
+	_, htmlKey, err := storage.PutBytes(fmt.Sprintf("%s/%s.html", dir, receipt.ReceiptNo), "text/html; charset=utf-8", htmlData)
+	if err != nil {
+		log.Printf("upload receipt %s html failed: %v", receipt.ReceiptNo, err)
+		return
+	}
+	_, pdfKey, err := storage.PutBytes(fmt.Sprintf("%s/%s.pdf", dir, receipt.ReceiptNo), "application/pdf", pdfData)
+	if err != nil {
+		log.Printf("upload receipt %s pdf failed: %v", receipt.ReceiptNo, err)
+		return
+	}
+	receipt.HTMLKey = htmlKey
+	receipt.PDFKey = pdfKey
+	global.DB.Model(&model.Receipt{}).Where("id = ?", receipt.ID).
+		Updates(map[string]interface{}{"html_key": htmlKey, "pdf_key": pdfKey})
+}
+
+// buildReceipt 按业务单据生成付款人和明细快照，金额拆分由调用方填写
+func buildReceipt(userID int64, bizType string, bizID int64) (*model.Receipt, error) {
+	var user model.SysUser
+	if err := global.DB.Select("id, username, real_name, mobile").First(&user, userID).Error; err != nil {
+		return nil, errors.New("用户不存在")
+	}
+	payer := user.RealName
+	if payer == "" {
+		payer = user.Username
+	}
+	receipt := &model.Receipt{
+		UserID:      userID,
+		BizType:     bizType,
+		BizID:       bizID,
+		PayerName:   payer,
+		PayerMobile: maskMobile(user.Mobile),
+		PaidAt:      time.Now(),
+	}
+
+	switch bizType {
+	case model.ReceiptBizOrder:
+		var order model.Order
+		if err := global.DB.Preload("Items.Product").Where("id = ? AND user_id = ?", bizID, userID).First(&order).Error; err != nil {
+			return nil, errors.New("未找到订单")
+		}
+		receipt.Title = "商城订单 " + order.OrderNo
+		if order.PaidAt != nil {
+			receipt.PaidAt = *order.PaidAt
+		}
+		for _, item := range order.Items {
+			amount := item.Amount
+			if amount == 0 {
+				amount = item.Price * model.Money(item.Quantity)
+			}
+			receipt.ItemList = append(receipt.ItemList, model.ReceiptItem{
+				Name:     item.Product.Name,
+				Quantity: item.Quantity,
+				Price:    item.Price,
+				Amount:   amount,
+			})
+		}
+		if order.DiscountAmount > 0 {
+			receipt.ItemList = append(receipt.ItemList, model.ReceiptItem{Name: "优惠", Quantity: 1, Price: -order.DiscountAmount, Amount: -order.DiscountAmount})
+		}
+	case model.ReceiptBizPropertyFee:
+		var fee model.PropertyFee
+		if err := global.DB.Where("id = ? AND user_id = ?", bizID, userID).First(&fee).Error; err != nil {
+			return nil, errors.New("未找到物业费记录")
+		}
+		receipt.Title = "物业费 " + fee.Month
+		if fee.PayTime != nil {
+			receipt.PaidAt = *fee.PayTime
+		}
+		name := "物业费 " + fee.Month
+		if fee.Detail != "" {
+			name = fee.Detail
+		}
+		receipt.ItemList = append(receipt.ItemList, model.ReceiptItem{Name: name, Quantity: 1, Price: fee.Amount, Amount: fee.Amount})
+		if fee.LateFee > 0 {
+			receipt.LateFee = fee.LateFee
+			receipt.ItemList = append(receipt.ItemList, model.ReceiptItem{Name: "滞纳金", Quantity: 1, Price: fee.LateFee, Amount: fee.LateFee})
+		}
+	default:
+		return nil, errors.New("不支持的收据类型")
+	}
+	return receipt, nil
+}
+
+func receiptBizType(payType int) (string, error) {
+	switch payType {
+	case PayTypeOrder:
+		return model.ReceiptBizOrder, nil
+	case PayTypePropertyFee:
+		return model.ReceiptBizPropertyFee, nil
+	}
+	return "", errors.New("不支持的收据类型")
+}
+
+func decodeReceiptItems(receipt *model.Receipt) {
+	if receipt.Items != "" {
+		_ = json.Unmarshal([]byte(receipt.Items), &receipt.ItemList)
+	}
+}
+
+// nextReceiptNo 按天连续编号，如 R20261018000123。当日计数不存在或 reseed 时以库中当日最大编号为种子
+func nextReceiptNo(reseed bool) (string, error) {
+	day := time.Now().Format("20060102")
+	prefix := "R" + day
+	key := "receipt:seq:" + day
+	ctx := context.Background()
+
+	if !reseed {
+		n, err := global.RDB.Exists(ctx, key).Result()
+		if err != nil {
+			return "", err
+		}
+		reseed = n == 0
+	}
+	var seed int64
+	if reseed {
+		var err error
+		if seed, err = maxReceiptSeq(prefix); err != nil {
+			return "", err
+		}
+	}
+	seq, err := receiptSeqScript.Run(ctx, global.RDB, []string{key}, seed, int64(receiptSeqTTL/time.Second)).Int64()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%0*d", prefix, receiptSeqDigits, seq), nil
+}
+
+// maxReceiptSeq 库中指定日期前缀下已使用的最大序号
+func maxReceiptSeq(prefix string) (int64, error) {
+	var nos []string
+	if err := global.DB.Model(&model.Receipt{}).
+		Where("receipt_no LIKE ? AND CHAR_LENGTH(receipt_no) = ?", prefix+"%", len(prefix)+receiptSeqDigits).
+		Order("receipt_no desc").Limit(1).
+		Pluck("receipt_no", &nos).Error; err != nil {
+		return 0, err
+	}
+	if len(nos) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(strings.TrimPrefix(nos[0], prefix), 10, 64)
+}
+
+// isDuplicateKeyError 是否为唯一索引冲突
+func isDuplicateKeyError(err error) bool {
+	if translator, ok := global.DB.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// maskMobile 手机号中间四位打码
+func maskMobile(mobile string) string {
+	if len(mobile) < 7 {
+		return mobile
+	}
+	return mobile[:3] + "****" + mobile[len(mobile)-4:]
+}
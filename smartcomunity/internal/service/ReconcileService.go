@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 差异处理方式
+const (
+	ReconcileResolveBalance = "balance" // 以流水为准，记一笔冲正凭证修正余额/积分
+	ReconcileResolveHistory = "history" // 以余额为准，补记一条流水
+	ReconcileResolveIgnore  = "ignore"
+)
+
+type ReconcileService struct{}
+
+type walletTotals struct {
+	UserID   int64
+	Actual   int64
+	Expected int64
+}
+
+// RunWalletReconciliation 用 sys_transaction 重算余额、用 green_point_record 重算积分，与用户表比对并保存差异
+func (s *ReconcileService) RunWalletReconciliation(operatorID int64) (*model.WalletReconciliation, error) {
+	now := time.Now()
+	run := &model.WalletReconciliation{
+		RunNo:      fmt.Sprintf("RC%d", now.UnixNano()),
+		OperatorID: operatorID,
+		StartedAt:  now,
+	}
+	if err := global.DB.Model(&model.SysUser{}).Count(&run.CheckedUsers).Error; err != nil {
+		return nil, err
+	}
+
+	balances, err := walletBalanceMismatches(global.DB, 0)
+	if err != nil {
+		return nil, err
+	}
+	points, err := walletPointsMismatches(global.DB, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range balances {
+		run.Discrepancies = append(run.Discrepancies, newWalletDiscrepancy(row, model.DiscrepancyFieldBalance, now))
+	}
+	for _, row := range points {
+		run.Discrepancies = append(run.Discrepancies, newWalletDiscrepancy(row, model.DiscrepancyFieldGreenPoints, now))
+	}
+	run.MismatchCount = len(run.Discrepancies)
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+
+	if err := global.DB.Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func newWalletDiscrepancy(row walletTotals, field string, now time.Time) model.WalletDiscrepancy {
+	return model.WalletDiscrepancy{
+		UserID:     row.UserID,
+		Field:      field,
+		Actual:     row.Actual,
+		Expected:   row.Expected,
+		Difference: row.Actual - row.Expected,
+		Status:     model.DiscrepancyStatusOpen,
+		CreatedAt:  now,
+	}
+}
+
+// walletBalanceMismatches 余额(分)与交易流水合计不一致的用户，userID 大于 0 时只查该用户
+func walletBalanceMismatches(db *gorm.DB, userID int64) ([]walletTotals, error) {
+	var rows []walletTotals
+	query := db.Table("sys_user u").
+		Select("u.id AS user_id, ROUND(u.balance * 100) AS actual, COALESCE(ROUND(SUM(t.amount) * 100), 0) AS expected").
+		Joins("LEFT JOIN sys_transaction t ON t.user_id = u.id")
+	if userID > 0 {
+		query = query.Where("u.id = ?", userID)
+	}
+	err := query.Group("u.id, u.balance").
+		Having("ROUND(u.balance * 100) <> COALESCE(ROUND(SUM(t.amount) * 100), 0)").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// walletPointsMismatches 积分与积分流水合计不一致的用户
+func walletPointsMismatches(db *gorm.DB, userID int64) ([]walletTotals, error) {
+	var rows []walletTotals
+	query := db.Table("sys_user u").
+		Select("u.id AS user_id, u.green_points AS actual, COALESCE(SUM(r.points), 0) AS expected").
+		Joins("LEFT JOIN green_point_record r ON r.user_id = u.id")
+	if userID > 0 {
+		query = query.Where("u.id = ?", userID)
+	}
+	err := query.Group("u.id, u.green_points").
+		Having("u.green_points <> COALESCE(SUM(r.points), 0)").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// ListReconciliations 对账批次列表
+func (s *ReconcileService) ListReconciliations(page, size int) ([]model.WalletReconciliation, int64, error) {
+	var list []model.WalletReconciliation
+	var total int64
+
+	db := global.DB.Model(&model.WalletReconciliation{})
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ListDiscrepancies 差异明细，reconciliationID 为 0 时查全部批次
+func (s *ReconcileService) ListDiscrepancies(reconciliationID int64, status *int, page, size int) ([]model.WalletDiscrepancy, int64, error) {
+	var list []model.WalletDiscrepancy
+	var total int64
+
+	db := global.DB.Model(&model.WalletDiscrepancy{})
+	if reconciliationID > 0 {
+		db = db.Where("reconciliation_id = ?", reconciliationID)
+	}
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("SysUser", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, real_name, mobile") }).
+		Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ResolveDiscrepancy 处理差异；冲正前按最新数据重新计算，差异已消失时直接关闭
+func (s *ReconcileService) ResolveDiscrepancy(operatorID, id int64, resolution, remark string) error {
+	if resolution != ReconcileResolveBalance && resolution != ReconcileResolveHistory && resolution != ReconcileResolveIgnore {
+		return errors.New("不支持的处理方式")
+	}
+
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var d model.WalletDiscrepancy
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&d, id).Error; err != nil {
+			return errors.New("差异记录不存在")
+		}
+		if d.Status != model.DiscrepancyStatusOpen {
+			return errors.New("差异已处理")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":      model.DiscrepancyStatusIgnored,
+			"resolution":  resolution,
+			"resolved_by": operatorID,
+			"resolved_at": &now,
+			"remark":      remark,
+		}
+		if resolution != ReconcileResolveIgnore {
+			if err := correctWalletDiscrepancy(tx, &d, resolution); err != nil {
+				return err
+			}
+			updates["status"] = model.DiscrepancyStatusCorrected
+		}
+		return tx.Model(&model.WalletDiscrepancy{}).Where("id = ?", d.ID).Updates(updates).Error
+	})
+}
+
+func correctWalletDiscrepancy(tx *gorm.DB, d *model.WalletDiscrepancy, resolution string) error {
+	var user model.SysUser
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, d.UserID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+
+	var rows []walletTotals
+	var err error
+	if d.Field == model.DiscrepancyFieldBalance {
+		rows, err = walletBalanceMismatches(tx, user.ID)
+	} else {
+		rows, err = walletPointsMismatches(tx, user.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	diff := rows[0].Actual - rows[0].Expected
+	remark := "Reconciliation correction #" + fmt.Sprint(d.ID)
+
+	if resolution == ReconcileResolveBalance {
+		userAccount, counterAccount := model.LedgerAccountUserWallet, model.LedgerAccountCashClearing
+		if d.Field == model.DiscrepancyFieldGreenPoints {
+			userAccount, counterAccount = model.LedgerAccountUserPoints, model.LedgerAccountPointsPool
+		}
+		if _, err := postLedgerEntry(tx, LedgerBizReconcile, d.ID, remark,
+			ledgerLeg{AccountType: userAccount, OwnerID: user.ID, Amount: -diff},
+			ledgerLeg{AccountType: counterAccount, Amount: diff},
+		); err != nil {
+			return err
+		}
+		if d.Field != model.DiscrepancyFieldGreenPoints {
+			return nil
+		}
+		// 积分余额被修正后同步调整积分批次
+		now := time.Now()
+		if diff < 0 {
+			return grantPointBatch(tx, user.ID, int(-diff), "reconcile_correction", d.ID, now)
+		}
+		_, err := deductPointBatches(tx, user.ID, int(diff), now)
+		return err
+	}
+
+	if d.Field == model.DiscrepancyFieldBalance {
+		return tx.Create(&model.SysTransaction{
+			UserID:    user.ID,
+			Type:      TransactionTypeAdjust,
+			Amount:    model.Money(diff),
+			RelatedID: d.ID,
+			Remark:    remark,
+			CreatedAt: time.Now(),
+		}).Error
+	}
+	return tx.Create(&model.GreenPointRecord{
+		UserID:    user.ID,
+		Action:    "reconcile_correction",
+		Points:    int(diff),
+		CreatedAt: time.Now(),
+	}).Error
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RedeemService struct{}
+
+const (
+	pointActionRedeem       = "points_redeem"
+	pointActionRedeemRefund = "redeem_refund"
+
+	maxRedeemQuantity = 99
+)
+
+// SaveItem 新增/修改兑换品 (Admin/Store)，store 角色只能维护本门店兑付的兑换品
+func (s *RedeemService) SaveItem(userID int64, role string, item *model.RedeemItem) error {
+	if err := checkRedeemItemScope(userID, role, item.StoreID); err != nil {
+		return err
+	}
+	scope, err := ResolveStoreScope(userID, role)
+	if err != nil {
+		return err
+	}
+
+	if item.ProductID > 0 {
+		var product model.Product
+		if err := global.DB.First(&product, item.ProductID).Error; err != nil {
+			return errors.New("商品不存在")
+		}
+		if product.StoreID > 0 {
+			if err := scope.Check(product.StoreID); err != nil {
+				return err
+			}
+		}
+		// 关联商城商品时默认沿用商品的名称、图片和描述
+		if strings.TrimSpace(item.Name) == "" {
+			item.Name = product.Name
+		}
+		if item.ImageURL == "" {
+			item.ImageURL = product.ImageURL
+		}
+		if item.Description == "" {
+			item.Description = product.Description
+		}
+	}
+
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return errors.New("请填写兑换商品名称")
+	}
+	if item.Type != model.RedeemItemGoods && item.Type != model.RedeemItemService {
+		return errors.New("不支持的兑换商品类型")
+	}
+	if item.Points <= 0 {
+		return errors.New("兑换所需积分必须大于0")
+	}
+	if item.Stock < 0 || item.PerUserLimit < 0 {
+		return errors.New("库存和限兑数量不能为负数")
+	}
+	if item.StartAt != nil && item.EndAt != nil && !item.EndAt.After(*item.StartAt) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+
+	if item.ID > 0 {
+		var existing model.RedeemItem
+		if err := global.DB.First(&existing, item.ID).Error; err != nil {
+			return errors.New("兑换商品不存在")
+		}
+		if err := checkRedeemItemScope(userID, role, existing.StoreID); err != nil {
+			return err
+		}
+		item.UpdatedAt = time.Now()
+		return global.DB.Model(&model.RedeemItem{}).Where("id = ?", item.ID).
+			Select("name", "description", "image_url", "type", "product_id", "store_id", "points", "stock",
+				"per_user_limit", "start_at", "end_at", "sort", "status", "updated_at").
+			Updates(item).Error
+	}
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	return global.DB.Create(item).Error
+}
+
+// DeleteItem 删除未被兑换过的兑换品，已有兑换记录的请改为下架
+func (s *RedeemService) DeleteItem(userID int64, role string, id int64) error {
+	var item model.RedeemItem
+	if err := global.DB.First(&item, id).Error; err != nil {
+		return errors.New("兑换商品不存在")
+	}
+	if err := checkRedeemItemScope(userID, role, item.StoreID); err != nil {
+		return err
+	}
+	var count int64
+	global.DB.Model(&model.Redemption{}).Where("item_id = ?", id).Count(&count)
+	if count > 0 {
+		return errors.New("该商品已有兑换记录，请改为下架")
+	}
+	return global.DB.Delete(&model.RedeemItem{}, id).Error
+}
+
+// ListCatalog 当前可兑换的目录，itemType 为 0 时不过滤
+func (s *RedeemService) ListCatalog(itemType, page, size int) ([]model.RedeemItem, int64, error) {
+	var list []model.RedeemItem
+	var total int64
+	now := time.Now()
+	db := global.DB.Model(&model.RedeemItem{}).
+		Where("status = 1 AND stock > 0").
+		Where("start_at IS NULL OR start_at <= ?", now).
+		Where("end_at IS NULL OR end_at > ?", now)
+	if itemType > 0 {
+		db = db.Where("type = ?", itemType)
+	}
+	db.Count(&total)
+	err := db.Preload("Store").
+		Order("sort desc, id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// GetItem 兑换品详情
+func (s *RedeemService) GetItem(id int64) (*model.RedeemItem, error) {
+	var item model.RedeemItem
+	if err := global.DB.Preload("Store").Preload("Product").First(&item, id).Error; err != nil {
+		return nil, errors.New("兑换商品不存在")
+	}
+	return &item, nil
+}
+
+// ListManagedItems 后台兑换品列表 (Admin/Store)
+func (s *RedeemService) ListManagedItems(userID int64, role string, name string, page, size int) ([]model.RedeemItem, int64, error) {
+	scope, err := ResolveStoreScope(userID, role)
+	if err != nil {
+		return nil, 0, err
+	}
+	var list []model.RedeemItem
+	var total int64
+	db := scope.Apply(global.DB.Model(&model.RedeemItem{}), "store_id")
+	if name != "" {
+		db = db.Where("name LIKE ?", "%"+name+"%")
+	}
+	db.Count(&total)
+	err = db.Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// Redeem 用积分兑换，积分按过期时间先到先用扣减，同时生成待兑付记录
+func (s *RedeemService) Redeem(userID, itemID int64, quantity int, remark string) (*model.Redemption, error) {
+	if quantity <= 0 {
+		quantity = 1
+	}
+	if quantity > maxRedeemQuantity {
+		return nil, errors.New("兑换数量过大")
+	}
+
+	var redemption *model.Redemption
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var user model.SysUser
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("用户不存在")
+		}
+		var item model.RedeemItem
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error; err != nil {
+			return errors.New("兑换商品不存在")
+		}
+
+		now := time.Now()
+		if item.Status != 1 || (item.StartAt != nil && now.Before(*item.StartAt)) || (item.EndAt != nil && !now.Before(*item.EndAt)) {
+			return errors.New("兑换商品未上架或不在兑换时间内")
+		}
+		if item.Stock < quantity {
+			return errors.New("库存不足")
+		}
+		if item.PerUserLimit > 0 {
+			var redeemed int
+			if err := tx.Model(&model.Redemption{}).
+				Select("COALESCE(SUM(quantity), 0)").
+				Where("user_id = ? AND item_id = ? AND status <> ?", userID, itemID, model.RedemptionCancelled).
+				Scan(&redeemed).Error; err != nil {
+				return err
+			}
+			if redeemed+quantity > item.PerUserLimit {
+				left := item.PerUserLimit - redeemed
+				if left < 0 {
+					left = 0
+				}
+				return fmt.Errorf("超出限兑数量，您还可兑换 %d 件", left)
+			}
+		}
+
+		points := item.Points * quantity
+		if user.GreenPoints < points {
+			return errors.New("积分不足")
+		}
+
+		code, err := generateRedeemCode(tx, item.StoreID)
+		if err != nil {
+			return err
+		}
+		redemption = &model.Redemption{
+			RedeemNo:  fmt.Sprintf("RD%d%d", now.UnixNano(), userID),
+			UserID:    userID,
+			ItemID:    item.ID,
+			ItemName:  item.Name,
+			ItemType:  item.Type,
+			StoreID:   item.StoreID,
+			Quantity:  quantity,
+			Points:    points,
+			Code:      code,
+			Remark:    strings.TrimSpace(remark),
+			Status:    model.RedemptionPending,
+			CreatedAt: now,
+		}
+		if err := tx.Create(redemption).Error; err != nil {
+			return err
+		}
+
+		if err := consumePointBatches(tx, &user, points, now); err != nil {
+			return err
+		}
+		if _, err := postLedgerEntry(tx, LedgerBizPointRedeem, redemption.ID, "Redeem "+item.Name,
+			ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: userID, Amount: -int64(points)},
+			ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: int64(points)},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&model.GreenPointRecord{
+			UserID:    userID,
+			Action:    pointActionRedeem,
+			Points:    -points,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&model.RedeemItem{}).
+			Where("id = ? AND stock >= ?", item.ID, quantity).
+			Update("stock", gorm.Expr("stock - ?", quantity))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("库存不足")
+		}
+
+		return sendUserNotice(tx, userID, "积分兑换成功",
+			fmt.Sprintf("您已使用 %d 积分兑换 %s x%d，兑付码 %s。", points, item.Name, quantity, code),
+			"redemption", redemption.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// ListMine 我的兑换记录
+func (s *RedeemService) ListMine(userID int64, status *int, page, size int) ([]model.Redemption, int64, error) {
+	var list []model.Redemption
+	var total int64
+	db := global.DB.Model(&model.Redemption{}).Where("user_id = ?", userID)
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	db.Count(&total)
+	err := db.Preload("Item").Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// ListRecords 后台兑付单列表 (Admin/Store)，可按状态和兑付码查询
+func (s *RedeemService) ListRecords(userID int64, role string, status *int, code string, page, size int) ([]model.Redemption, int64, error) {
+	scope, err := ResolveStoreScope(userID, role)
+	if err != nil {
+		return nil, 0, err
+	}
+	var list []model.Redemption
+	var total int64
+	db := scope.Apply(global.DB.Model(&model.Redemption{}), "store_id")
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	if code != "" {
+		db = db.Where("code = ?", code)
+	}
+	db.Count(&total)
+	err = db.Preload("SysUser", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, real_name, mobile") }).
+		Order("id desc").Offset((page - 1) * size).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// Fulfil 核对兑付码后完成兑付 (Admin/Store)
+func (s *RedeemService) Fulfil(operatorID int64, role string, id int64, code string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var r model.Redemption
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&r, id).Error; err != nil {
+			return errors.New("兑换记录不存在")
+		}
+		if err := checkRedeemItemScope(operatorID, role, r.StoreID); err != nil {
+			return err
+		}
+		if r.Status != model.RedemptionPending {
+			return errors.New("兑换记录不是待兑付状态")
+		}
+		if strings.TrimSpace(code) != r.Code {
+			return errors.New("兑换码错误")
+		}
+		now := time.Now()
+		if err := tx.Model(&r).Updates(map[string]interface{}{
+			"status":       model.RedemptionFulfilled,
+			"operator_id":  operatorID,
+			"fulfilled_at": &now,
+		}).Error; err != nil {
+			return err
+		}
+		return sendUserNotice(tx, r.UserID, "积分兑换已兑付",
+			fmt.Sprintf("您兑换的 %s x%d 已完成兑付。", r.ItemName, r.Quantity), "redemption", r.ID)
+	})
+}
+
+// Cancel 后台取消待兑付的记录 (Admin/Store)，积分和库存退回
+func (s *RedeemService) Cancel(operatorID int64, role string, id int64, note string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var r model.Redemption
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&r, id).Error; err != nil {
+			return errors.New("兑换记录不存在")
+		}
+		if err := checkRedeemItemScope(operatorID, role, r.StoreID); err != nil {
+			return err
+		}
+		return cancelRedemption(tx, &r, operatorID, note)
+	})
+}
+
+// CancelMine 用户取消自己待兑付的记录
+func (s *RedeemService) CancelMine(userID, id int64) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var r model.Redemption
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", id, userID).First(&r).Error; err != nil {
+			return errors.New("兑换记录不存在")
+		}
+		return cancelRedemption(tx, &r, userID, "cancelled by user")
+	})
+}
+
+func cancelRedemption(tx *gorm.DB, r *model.Redemption, operatorID int64, note string) error {
+	if r.Status != model.RedemptionPending {
+		return errors.New("兑换记录不是待兑付状态")
+	}
+	var user model.SysUser
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, r.UserID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+
+	now := time.Now()
+	if _, err := postLedgerEntry(tx, LedgerBizPointRedeem, r.ID, "Cancel redemption "+r.RedeemNo,
+		ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: -int64(r.Points)},
+		ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: r.UserID, Amount: int64(r.Points)},
+	); err != nil {
+		return err
+	}
+	if err := tx.Create(&model.GreenPointRecord{
+		UserID:    r.UserID,
+		Action:    pointActionRedeemRefund,
+		Points:    r.Points,
+		CreatedAt: now,
+	}).Error; err != nil {
+		return err
+	}
+	// 退回的积分作为新批次重新计算有效期
+	if err := grantPointBatch(tx, r.UserID, r.Points, pointActionRedeemRefund, r.ID, now); err != nil {
+		return err
+	}
+	if err := tx.Model(&model.RedeemItem{}).Where("id = ?", r.ItemID).
+		Update("stock", gorm.Expr("stock + ?", r.Quantity)).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(r).Updates(map[string]interface{}{
+		"status":       model.RedemptionCancelled,
+		"operator_id":  operatorID,
+		"cancel_note":  strings.TrimSpace(note),
+		"cancelled_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	return sendUserNotice(tx, r.UserID, "积分兑换已取消",
+		fmt.Sprintf("您兑换的 %s x%d 已取消，%d 积分已退回。", r.ItemName, r.Quantity, r.Points), "redemption", r.ID)
+}
+
+// checkRedeemItemScope 平台兑付(store_id 为 0)的兑换品只有 admin 可以操作
+func checkRedeemItemScope(userID int64, role string, storeID int64) error {
+	if storeID == 0 {
+		if role == "admin" {
+			return nil
+		}
+		return ErrStoreForbidden
+	}
+	return checkStoreScope(userID, role, storeID)
+}
+
+func generateRedeemCode(tx *gorm.DB, storeID int64) (string, error) {
+	for i := 0; i < 10; i++ {
+		code := fmt.Sprintf("%08d", rand.Intn(100000000))
+		var count int64
+		if err := tx.Model(&model.Redemption{}).
+			Where("store_id = ? AND code = ? AND status = ?", storeID, code, model.RedemptionPending).
+			Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("兑换码生成失败，请重试")
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TransactionTypeRefund = 5
+
+	RefundTypeOnly   = 1
+	RefundTypeReturn = 2
+
+	RefundStatusPending  = 0
+	RefundStatusApproved = 1
+	RefundStatusRejected = 2
+)
+
+type RefundService struct{}
+
+// ApplyRefund 用户发起售后申请，items 为空时申请退回全部可退商品
+func (s *RefundService) ApplyRefund(userID, orderID int64, refundType int, reason string, items []model.RefundItemParam) (*model.OrderRefund, error) {
+	if refundType != RefundTypeOnly && refundType != RefundTypeReturn {
+		refundType = RefundTypeOnly
+	}
+
+	var refund *model.OrderRefund
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Items").
+			Where("id = ? AND user_id = ?", orderID, userID).
+			First(&order).Error; err != nil {
+			return errors.New("order not found")
+		}
+		if order.Status != model.OrderStatusPaid && order.Status != model.OrderStatusShipped && order.Status != model.OrderStatusReceived {
+			return errors.New("only paid, shipped or received orders can be refunded")
+		}
+		if err := checkGroupBuySucceeded(tx, &order); err != nil {
+			return err
+		}
+
+		refundItems, amountCents, err := buildRefundItems(&order, items)
+		if err != nil {
+			return err
+		}
+
+		refund = &model.OrderRefund{
+			RefundNo:          fmt.Sprintf("R%d%d", time.Now().UnixNano(), userID),
+			OrderID:           order.ID,
+			UserID:            userID,
+			StoreID:           order.StoreID,
+			Type:              refundType,
+			Reason:            reason,
+			Amount:            model.Money(amountCents),
+			Status:            RefundStatusPending,
+			OrderStatusBefore: order.Status,
+			Items:             refundItems,
+			CreatedAt:         time.Now(),
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+
+		return transitOrderStatus(tx, &order, model.OrderStatusRefunding, userID, OrderOperatorUser, "refund requested: "+refund.RefundNo, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// AuditRefund 管理员/门店审核售后申请，通过后按原支付比例退回积分与余额
+func (s *RefundService) AuditRefund(auditorID int64, auditorRole string, refundID int64, approve bool, remark string) (*model.OrderRefund, error) {
+	var refund model.OrderRefund
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Items").
+			First(&refund, refundID).Error; err != nil {
+			return errors.New("refund request not found")
+		}
+		if err := checkStoreScope(auditorID, auditorRole, refund.StoreID); err != nil {
+			return err
+		}
+		if refund.Status != RefundStatusPending {
+			return errors.New("refund request has already been processed")
+		}
+
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Items").
+			First(&order, refund.OrderID).Error; err != nil {
+			return errors.New("order not found")
+		}
+
+		now := time.Now()
+		if !approve {
+			if err := transitOrderStatus(tx, &order, refund.OrderStatusBefore, auditorID, auditorRole, "refund rejected: "+refund.RefundNo, nil); err != nil {
+				return err
+			}
+			refund.Status = RefundStatusRejected
+			refund.AuditorID = auditorID
+			refund.AuditRemark = remark
+			refund.AuditedAt = &now
+			return tx.Model(&model.OrderRefund{}).Where("id = ?", refund.ID).Updates(map[string]interface{}{
+				"status":       refund.Status,
+				"auditor_id":   auditorID,
+				"audit_remark": remark,
+				"audited_at":   &now,
+			}).Error
+		}
+
+		return s.executeRefund(tx, &order, &refund, auditorID, auditorRole, remark, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (s *RefundService) executeRefund(tx *gorm.DB, order *model.Order, refund *model.OrderRefund, auditorID int64, auditorRole, remark string, now time.Time) error {
+	itemMap := make(map[int64]model.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		itemMap[item.ID] = item
+	}
+
+	for _, ri := range refund.Items {
+		item, ok := itemMap[ri.OrderItemID]
+		if !ok || item.Quantity-item.RefundedQty < ri.Quantity {
+			return errors.New("refund quantity exceeds refundable quantity")
+		}
+		item.RefundedQty += ri.Quantity
+		itemMap[item.ID] = item
+
+		if err := tx.Model(&model.OrderItem{}).Where("id = ?", item.ID).
+			Update("refunded_qty", gorm.Expr("refunded_qty + ?", ri.Quantity)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Product{}).Where("id = ?", ri.ProductID).
+			Update("sales", gorm.Expr("GREATEST(sales - ?, 0)", ri.Quantity)).Error; err != nil {
+			return err
+		}
+		if err := returnProductStock(tx, item.StoreStock, order.StoreID, ri.ProductID, ri.Quantity, model.StockMovementRefund, refund.ID, auditorID, refund.RefundNo); err != nil {
+			return err
+		}
+	}
+
+	fullyRefunded := true
+	for _, item := range itemMap {
+		if item.RefundedQty < item.Quantity {
+			fullyRefunded = false
+			break
+		}
+	}
+
+	points, balanceCents := splitRefundAmount(order, int(refund.Amount.Cents()), fullyRefunded)
+
+	var user model.SysUser
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, order.UserID).Error; err != nil {
+		return errors.New("user not found")
+	}
+
+	// 冲回支付时的分录：门店(或平台)收入减少，积分抵扣部分退回平台收入
+	income := orderIncomeLeg(order.StoreID, -(balanceCents + points*CentsPerGreenPoint))
+	if _, err := postLedgerEntry(tx, LedgerBizRefund, refund.ID, fmt.Sprintf("Refund %s for order %s", refund.RefundNo, order.OrderNo),
+		income,
+		ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: user.ID, Amount: int64(balanceCents)},
+		ledgerLeg{AccountType: model.LedgerAccountPlatformRevenue, Amount: int64(points * CentsPerGreenPoint)},
+		ledgerLeg{AccountType: model.LedgerAccountPointsPool, Amount: -int64(points)},
+		ledgerLeg{AccountType: model.LedgerAccountUserPoints, OwnerID: user.ID, Amount: int64(points)},
+	); err != nil {
+		return err
+	}
+
+	if points > 0 {
+		record := model.GreenPointRecord{
+			UserID:    user.ID,
+			Action:    "order_refund",
+			Points:    points,
+			CreatedAt: now,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		// 退回的积分作为新批次重新计算有效期
+		if err := grantPointBatch(tx, user.ID, points, record.Action, refund.ID, now); err != nil {
+			return err
+		}
+	}
+	if balanceCents > 0 {
+		transaction := model.SysTransaction{
+			UserID:    user.ID,
+			Type:      TransactionTypeRefund,
+			Amount:    model.Money(balanceCents),
+			RelatedID: order.ID,
+			Remark:    fmt.Sprintf("Refund %s for order %s", refund.RefundNo, order.OrderNo),
+			CreatedAt: now,
+		}
+		if err := tx.Create(&transaction).Error; err != nil {
+			return err
+		}
+	}
+
+	orderStatus := refund.OrderStatusBefore
+	if fullyRefunded {
+		orderStatus = model.OrderStatusRefunded
+	}
+	if err := transitOrderStatus(tx, order, orderStatus, auditorID, auditorRole, "refund approved: "+refund.RefundNo, map[string]interface{}{
+		"refunded_points":  gorm.Expr("refunded_points + ?", points),
+		"refunded_balance": gorm.Expr("refunded_balance + CAST(? AS DECIMAL(12,2))", model.Money(balanceCents)),
+	}); err != nil {
+		return err
+	}
+
+	refund.Status = RefundStatusApproved
+	refund.RefundPoints = points
+	refund.RefundBalance = model.Money(balanceCents)
+	refund.AuditorID = auditorID
+	refund.AuditRemark = remark
+	refund.AuditedAt = &now
+	return tx.Model(&model.OrderRefund{}).Where("id = ?", refund.ID).Updates(map[string]interface{}{
+		"status":         refund.Status,
+		"refund_points":  points,
+		"refund_balance": refund.RefundBalance,
+		"auditor_id":     auditorID,
+		"audit_remark":   remark,
+		"audited_at":     &now,
+	}).Error
+}
+
+func (s *RefundService) ListUserRefunds(userID int64, page, size int) ([]model.OrderRefund, int64, error) {
+	var list []model.OrderRefund
+	var total int64
+	db := global.DB.Model(&model.OrderRefund{}).Where("user_id = ?", userID)
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("Items.Product").Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+func (s *RefundService) ListAllRefunds(operatorID int64, operatorRole string, page, size int, status *int) ([]model.OrderRefund, int64, error) {
+	var list []model.OrderRefund
+	var total int64
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := scope.Apply(global.DB.Model(&model.OrderRefund{}), "store_id")
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err = db.Preload("Items.Product").Preload("Order").Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// buildRefundItems 校验申请数量不超过可退数量，并按订单实付比例计算退款金额(分)
+func buildRefundItems(order *model.Order, params []model.RefundItemParam) ([]model.OrderRefundItem, int, error) {
+	itemMap := make(map[int64]model.OrderItem, len(order.Items))
+	grossCents := 0
+	for _, item := range order.Items {
+		itemMap[item.ID] = item
+		grossCents += orderItemCents(item)
+	}
+	payableCents := int(order.TotalAmount.Cents())
+
+	if len(params) == 0 {
+		for _, item := range order.Items {
+			if remaining := item.Quantity - item.RefundedQty; remaining > 0 {
+				params = append(params, model.RefundItemParam{OrderItemID: item.ID, Quantity: remaining})
+			}
+		}
+	}
+	if len(params) == 0 {
+		return nil, 0, errors.New("no refundable items in this order")
+	}
+
+	requested := make(map[int64]int, len(params))
+	var refundItems []model.OrderRefundItem
+	amountCents := 0
+	for _, p := range params {
+		item, ok := itemMap[p.OrderItemID]
+		if !ok {
+			return nil, 0, fmt.Errorf("order item %d does not belong to this order", p.OrderItemID)
+		}
+		if p.Quantity <= 0 {
+			return nil, 0, errors.New("refund quantity must be greater than 0")
+		}
+		requested[item.ID] += p.Quantity
+		if requested[item.ID] > item.Quantity-item.RefundedQty {
+			return nil, 0, fmt.Errorf("order item %d has only %d refundable", item.ID, item.Quantity-item.RefundedQty)
+		}
+
+		// 订单使用了优惠券时，按实付占比分摊到每个商品
+		itemCents := orderItemCents(item) * p.Quantity / item.Quantity
+		if grossCents > 0 && payableCents != grossCents {
+			itemCents = itemCents * payableCents / grossCents
+		}
+		amountCents += itemCents
+		refundItems = append(refundItems, model.OrderRefundItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			Quantity:    p.Quantity,
+			Amount:      model.Money(itemCents),
+		})
+	}
+
+	// 退完全部剩余商品时，退款金额取剩余实付，消除按比例分摊产生的分差
+	final := true
+	for _, item := range order.Items {
+		if item.Quantity-item.RefundedQty > requested[item.ID] {
+			final = false
+			break
+		}
+	}
+	if final {
+		refundedCents := order.RefundedPoints*CentsPerGreenPoint + int(order.RefundedBalance.Cents())
+		amountCents = payableCents - refundedCents
+		if amountCents < 0 {
+			amountCents = 0
+		}
+	}
+	return refundItems, amountCents, nil
+}
+
+// splitRefundAmount 按订单原积分/余额支付比例拆分退款金额。
+// 最后一次退款直接退回剩余的全部积分与余额，避免多次部分退款累计出现分差。
+func splitRefundAmount(order *model.Order, amountCents int, final bool) (int, int) {
+	remainingPoints := order.UsedPoints - order.RefundedPoints
+	remainingBalanceCents := int(order.UsedBalance.Cents()) - int(order.RefundedBalance.Cents())
+	if remainingPoints < 0 {
+		remainingPoints = 0
+	}
+	if remainingBalanceCents < 0 {
+		remainingBalanceCents = 0
+	}
+	if final {
+		return remainingPoints, remainingBalanceCents
+	}
+
+	totalCents := int(order.TotalAmount.Cents())
+	if totalCents <= 0 {
+		return 0, 0
+	}
+
+	points := minInt(amountCents*order.UsedPoints/totalCents, remainingPoints)
+	balanceCents := amountCents - points*CentsPerGreenPoint
+	if balanceCents > remainingBalanceCents {
+		shortfall := balanceCents - remainingBalanceCents
+		extraPoints := minInt((shortfall+CentsPerGreenPoint-1)/CentsPerGreenPoint, remainingPoints-points)
+		points += extraPoints
+		balanceCents = amountCents - points*CentsPerGreenPoint
+		if balanceCents > remainingBalanceCents {
+			balanceCents = remainingBalanceCents
+		}
+	}
+	if balanceCents < 0 {
+		balanceCents = 0
+	}
+	return points, balanceCents
+}
+
+// systemRefundOrder 系统发起的全额退款：生成售后单并立即执行，用于拼团失败、超期未自提等场景
+func systemRefundOrder(tx *gorm.DB, order *model.Order, reason string) error {
+	refundItems, amountCents, err := buildRefundItems(order, nil)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	refund := &model.OrderRefund{
+		RefundNo:          fmt.Sprintf("R%d%d", now.UnixNano(), order.UserID),
+		OrderID:           order.ID,
+		UserID:            order.UserID,
+		StoreID:           order.StoreID,
+		Type:              RefundTypeOnly,
+		Reason:            reason,
+		Amount:            model.Money(amountCents),
+		Status:            RefundStatusPending,
+		OrderStatusBefore: order.Status,
+		Items:             refundItems,
+		CreatedAt:         now,
+	}
+	if err := tx.Create(refund).Error; err != nil {
+		return err
+	}
+	if err := transitOrderStatus(tx, order, model.OrderStatusRefunding, 0, OrderOperatorSystem, "refund requested: "+refund.RefundNo, nil); err != nil {
+		return err
+	}
+	return (&RefundService{}).executeRefund(tx, order, refund, 0, OrderOperatorSystem, reason, now)
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"testing"
+
+	"smartcommunity/internal/model"
+)
+
+// refundTestOrder 两件商品原价合计 110.00，用券后实付 100.00：积分 333 分(33.30 元) + 余额 66.70 元
+func refundTestOrder() *model.Order {
+	return &model.Order{
+		ID:          1,
+		TotalAmount: 10000,
+		UsedPoints:  333,
+		UsedBalance: 6670,
+		Items: []model.OrderItem{
+			{ID: 1, ProductID: 11, Price: 3333, Quantity: 3},
+			{ID: 2, ProductID: 12, Price: 1001, Quantity: 1},
+		},
+	}
+}
+
+func TestBuildRefundItems(t *testing.T) {
+	tests := []struct {
+		name      string
+		refunded  map[int64]int // 已退数量
+		refundedP int
+		refundedB model.Money
+		params    []model.RefundItemParam
+		wantCents int
+		wantItems map[int64]model.Money
+		wantErr   bool
+	}{
+		{
+			name:      "full refund without params",
+			wantCents: 10000,
+			wantItems: map[int64]model.Money{1: 9090, 2: 910},
+		},
+		{
+			name:      "partial refund is prorated by payable amount",
+			params:    []model.RefundItemParam{{OrderItemID: 1, Quantity: 1}},
+			wantCents: 3030,
+			wantItems: map[int64]model.Money{1: 3030},
+		},
+		{
+			name:      "same item requested twice is merged",
+			params:    []model.RefundItemParam{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 1, Quantity: 1}},
+			wantCents: 6060,
+		},
+		{
+			name:      "final refund takes the remaining payment",
+			refunded:  map[int64]int{1: 2},
+			refundedP: 201,
+			refundedB: 4050,
+			wantCents: 10000 - 2010 - 4050,
+			wantItems: map[int64]model.Money{1: 3030, 2: 910},
+		},
+		{
+			name:      "final refund never goes negative",
+			refunded:  map[int64]int{1: 2},
+			refundedP: 333,
+			refundedB: 6670,
+			params:    []model.RefundItemParam{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 2, Quantity: 1}},
+			wantCents: 0,
+		},
+		{
+			name:    "quantity above refundable",
+			params:  []model.RefundItemParam{{OrderItemID: 2, Quantity: 2}},
+			wantErr: true,
+		},
+		{
+			name:     "quantity above refundable after earlier refund",
+			refunded: map[int64]int{1: 2},
+			params:   []model.RefundItemParam{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 1, Quantity: 1}},
+			wantErr:  true,
+		},
+		{
+			name:    "item of another order",
+			params:  []model.RefundItemParam{{OrderItemID: 99, Quantity: 1}},
+			wantErr: true,
+		},
+		{
+			name:    "zero quantity",
+			params:  []model.RefundItemParam{{OrderItemID: 1, Quantity: 0}},
+			wantErr: true,
+		},
+		{
+			name:     "nothing left to refund",
+			refunded: map[int64]int{1: 3, 2: 1},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		order := refundTestOrder()
+		for i := range order.Items {
+			order.Items[i].RefundedQty = tt.refunded[order.Items[i].ID]
+		}
+		order.RefundedPoints = tt.refundedP
+		order.RefundedBalance = tt.refundedB
+
+		items, cents, err := buildRefundItems(order, tt.params)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: buildRefundItems() = %d, want error", tt.name, cents)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: buildRefundItems() unexpected error: %v", tt.name, err)
+			continue
+		}
+		if cents != tt.wantCents {
+			t.Errorf("%s: refund amount = %d, want %d", tt.name, cents, tt.wantCents)
+		}
+		for _, item := range items {
+			if want, ok := tt.wantItems[item.OrderItemID]; ok && item.Amount != want {
+				t.Errorf("%s: item %d amount = %d, want %d", tt.name, item.OrderItemID, item.Amount, want)
+			}
+		}
+	}
+}
+
+func TestSplitRefundAmount(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       model.Money
+		usedPoints  int
+		usedBalance model.Money
+		refundedP   int
+		refundedB   model.Money
+		amount      int
+		final       bool
+		wantPoints  int
+		wantBalance int
+	}{
+		{name: "balance only", total: 5000, usedBalance: 5000, amount: 1234, wantBalance: 1234},
+		{name: "points only", total: 5000, usedPoints: 500, amount: 1230, wantPoints: 123},
+		{name: "points only rounds up to cover the amount", total: 5000, usedPoints: 500, amount: 1234, wantPoints: 124},
+		{name: "mixed by ratio", total: 10000, usedPoints: 333, usedBalance: 6670, amount: 3030, wantPoints: 100, wantBalance: 2030},
+		{name: "balance exhausted falls back to points", total: 10000, usedPoints: 333, usedBalance: 6670, refundedB: 6000, amount: 3030, wantPoints: 236, wantBalance: 670},
+		{name: "final returns everything left", total: 10000, usedPoints: 333, usedBalance: 6670, refundedP: 201, refundedB: 4050, amount: 1, final: true, wantPoints: 132, wantBalance: 2620},
+		{name: "over-refunded order clamps to zero", total: 10000, usedPoints: 100, usedBalance: 9000, refundedP: 200, refundedB: 9500, final: true},
+		{name: "free order", total: 0, amount: 100},
+	}
+	for _, tt := range tests {
+		order := &model.Order{
+			TotalAmount:     tt.total,
+			UsedPoints:      tt.usedPoints,
+			UsedBalance:     tt.usedBalance,
+			RefundedPoints:  tt.refundedP,
+			RefundedBalance: tt.refundedB,
+		}
+		points, balance := splitRefundAmount(order, tt.amount, tt.final)
+		if points != tt.wantPoints || balance != tt.wantBalance {
+			t.Errorf("%s: splitRefundAmount() = (%d, %d), want (%d, %d)", tt.name, points, balance, tt.wantPoints, tt.wantBalance)
+		}
+	}
+}
+
+// TestRefundSequenceReturnsExactPayment 模拟 executeRefund 的记账过程：无论怎样拆分多次退款，
+// 每次退回的积分与余额之和等于退款金额，全部退完后累计退回的积分与余额恰好等于原支付
+func TestRefundSequenceReturnsExactPayment(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps [][]model.RefundItemParam // nil 表示退全部剩余商品
+	}{
+		{name: "single full refund", steps: [][]model.RefundItemParam{nil}},
+		{name: "partial then final", steps: [][]model.RefundItemParam{
+			{{OrderItemID: 2, Quantity: 1}},
+			nil,
+		}},
+		{name: "multiple partials", steps: [][]model.RefundItemParam{
+			{{OrderItemID: 1, Quantity: 1}},
+			{{OrderItemID: 1, Quantity: 1}},
+			{{OrderItemID: 2, Quantity: 1}},
+			{{OrderItemID: 1, Quantity: 1}},
+		}},
+		{name: "item by item", steps: [][]model.RefundItemParam{
+			{{OrderItemID: 1, Quantity: 1}},
+			{{OrderItemID: 1, Quantity: 2}},
+			{{OrderItemID: 2, Quantity: 1}},
+		}},
+	}
+	for _, tt := range tests {
+		order := refundTestOrder()
+		var refunded model.Money
+		for i, params := range tt.steps {
+			refundItems, amountCents, err := buildRefundItems(order, params)
+			if err != nil {
+				t.Fatalf("%s: step %d: buildRefundItems() unexpected error: %v", tt.name, i+1, err)
+			}
+			for _, ri := range refundItems {
+				for j := range order.Items {
+					if order.Items[j].ID == ri.OrderItemID {
+						order.Items[j].RefundedQty += ri.Quantity
+					}
+				}
+			}
+			final := true
+			for _, item := range order.Items {
+				if item.RefundedQty < item.Quantity {
+					final = false
+				}
+			}
+
+			points, balanceCents := splitRefundAmount(order, amountCents, final)
+			if got := points*CentsPerGreenPoint + balanceCents; got != amountCents {
+				t.Errorf("%s: step %d: refunded %d points + %d cents = %d, want %d", tt.name, i+1, points, balanceCents, got, amountCents)
+			}
+			order.RefundedPoints += points
+			order.RefundedBalance += model.Money(balanceCents)
+			refunded += model.Money(amountCents)
+		}
+
+		if order.RefundedPoints != order.UsedPoints || order.RefundedBalance != order.UsedBalance {
+			t.Errorf("%s: refunded (%d points, %d cents), want (%d points, %d cents)",
+				tt.name, order.RefundedPoints, order.RefundedBalance, order.UsedPoints, order.UsedBalance)
+		}
+		if refunded != order.TotalAmount {
+			t.Errorf("%s: refunded %d cents in total, want %d", tt.name, refunded, order.TotalAmount)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"smartcommunity/internal/config"
+)
+
+const (
+	SettlementCycleDaily  = "daily"
+	SettlementCycleWeekly = "weekly"
+
+	defaultSettlementHour = 2
+)
+
+func settlementCycle() string {
+	if config.Conf != nil && config.Conf.Settlement.Cycle == SettlementCycleWeekly {
+		return SettlementCycleWeekly
+	}
+	return SettlementCycleDaily
+}
+
+func settlementHour() int {
+	if config.Conf != nil && config.Conf.Settlement.RunHour > 0 && config.Conf.Settlement.RunHour < 24 {
+		return config.Conf.Settlement.RunHour
+	}
+	return defaultSettlementHour
+}
+
+// StartSettlementScheduler 按日或按周生成上一周期的门店结算单
+func StartSettlementScheduler() {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		log.Printf("load Asia/Shanghai location failed, fallback to local: %v", err)
+		location = time.Local
+	}
+
+	settlementService := &SettlementService{}
+	cycle := settlementCycle()
+	hour := settlementHour()
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			nextRun := nextSettlementRun(now, cycle, hour, location)
+			log.Printf("settlement scheduler armed, cycle=%s next run at %s", cycle, nextRun.Format("2006-01-02 15:04:05"))
+			timer := time.NewTimer(time.Until(nextRun))
+			<-timer.C
+
+			periodStart, periodEnd := settlementPeriod(time.Now().In(location), cycle, location)
+			list, err := settlementService.GenerateSettlements(0, periodStart, periodEnd)
+			if err != nil {
+				log.Printf("generate settlements failed: %v", err)
+				continue
+			}
+			log.Printf("generated %d store settlements for %s ~ %s", len(list), periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02"))
+		}
+	}()
+}
+
+func nextSettlementRun(now time.Time, cycle string, hour int, location *time.Location) time.Time {
+	runAt := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, location)
+	if !now.Before(runAt) {
+		runAt = runAt.AddDate(0, 0, 1)
+	}
+	if cycle == SettlementCycleWeekly {
+		for runAt.Weekday() != time.Monday {
+			runAt = runAt.AddDate(0, 0, 1)
+		}
+	}
+	return runAt
+}
+
+// settlementPeriod 返回上一个完整周期 [start, end)
+func settlementPeriod(now time.Time, cycle string, location *time.Location) (time.Time, time.Time) {
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if cycle == SettlementCycleWeekly {
+		offset := (int(end.Weekday()) + 6) % 7 // 距本周一的天数
+		end = end.AddDate(0, 0, -offset)
+		return end.AddDate(0, 0, -7), end
+	}
+	return end.AddDate(0, 0, -1), end
+}
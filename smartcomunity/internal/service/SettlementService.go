@@ -0,0 +1,308 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SettlementService struct{}
+
+// settlementOrderStatuses 已支付且已收货(含交易关闭)的订单计入门店收入
+func settlementOrderStatuses() []int {
+	return []int{model.OrderStatusReceived, model.OrderStatusClosed}
+}
+
+// GenerateSettlements 为所有门店生成截至 periodEnd 的结算单，没有新增明细的门店跳过
+func (s *SettlementService) GenerateSettlements(operatorID int64, periodStart, periodEnd time.Time) ([]model.StoreSettlement, error) {
+	if !periodEnd.After(periodStart) {
+		return nil, errors.New("结算截止时间必须晚于开始时间")
+	}
+
+	var stores []model.Store
+	if err := global.DB.Order("id asc").Find(&stores).Error; err != nil {
+		return nil, err
+	}
+
+	var result []model.StoreSettlement
+	for _, store := range stores {
+		var settlement *model.StoreSettlement
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			settlement, err = settleStore(tx, &store, periodStart, periodEnd, operatorID)
+			return err
+		})
+		if err != nil {
+			log.Printf("generate settlement failed, storeID=%d err=%v", store.ID, err)
+			return result, err
+		}
+		if settlement != nil {
+			result = append(result, *settlement)
+		}
+	}
+	return result, nil
+}
+
+// settleStore 汇总门店未结算的收货订单，以及已结算(或本次结算)订单上的退款
+func settleStore(tx *gorm.DB, store *model.Store, periodStart, periodEnd time.Time, operatorID int64) (*model.StoreSettlement, error) {
+	settledOrders := tx.Model(&model.StoreSettlementItem{}).Select("related_id").Where("item_type = ?", model.SettlementItemOrder)
+	settledRefunds := tx.Model(&model.StoreSettlementItem{}).Select("related_id").Where("item_type = ?", model.SettlementItemRefund)
+
+	var orders []model.Order
+	if err := tx.Where("store_id = ? AND status IN ? AND paid_at < ?", store.ID, settlementOrderStatuses(), periodEnd).
+		Where("id NOT IN (?)", settledOrders).
+		Order("id asc").
+		Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	orderIDs := make([]int64, 0, len(orders))
+	for _, o := range orders {
+		orderIDs = append(orderIDs, o.ID)
+	}
+
+	refundQuery := tx.Where("store_id = ? AND status = ? AND audited_at < ?", store.ID, RefundStatusApproved, periodEnd).
+		Where("id NOT IN (?)", settledRefunds)
+	if len(orderIDs) > 0 {
+		refundQuery = refundQuery.Where("order_id IN (?) OR order_id IN ?", settledOrders, orderIDs)
+	} else {
+		refundQuery = refundQuery.Where("order_id IN (?)", settledOrders)
+	}
+	var refunds []model.OrderRefund
+	if err := refundQuery.Preload("Order").Order("id asc").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+
+	if len(orders) == 0 && len(refunds) == 0 {
+		return nil, nil
+	}
+
+	var items []model.StoreSettlementItem
+	grossCents, refundCents := 0, 0
+	for _, o := range orders {
+		cents := int(o.TotalAmount.Cents())
+		grossCents += cents
+		occurredAt := o.CreatedAt
+		if o.PaidAt != nil {
+			occurredAt = *o.PaidAt
+		}
+		items = append(items, model.StoreSettlementItem{
+			ItemType:   model.SettlementItemOrder,
+			RelatedID:  o.ID,
+			OrderID:    o.ID,
+			OrderNo:    o.OrderNo,
+			Amount:     model.Money(cents),
+			OccurredAt: occurredAt,
+		})
+	}
+	for _, r := range refunds {
+		cents := int(r.Amount.Cents())
+		refundCents += cents
+		occurredAt := r.CreatedAt
+		if r.AuditedAt != nil {
+			occurredAt = *r.AuditedAt
+		}
+		items = append(items, model.StoreSettlementItem{
+			ItemType:   model.SettlementItemRefund,
+			RelatedID:  r.ID,
+			OrderID:    r.OrderID,
+			OrderNo:    r.Order.OrderNo,
+			Amount:     -model.Money(cents),
+			OccurredAt: occurredAt,
+		})
+	}
+
+	// 佣金按扣除退款后的金额计算，退款大于收入时为负数，即退回此前收取的佣金
+	baseCents := grossCents - refundCents
+	commissionCents := int(math.Round(float64(baseCents) * store.CommissionRate))
+
+	settlement := &model.StoreSettlement{
+		SettlementNo:     fmt.Sprintf("S%d%d", time.Now().UnixNano(), store.ID),
+		StoreID:          store.ID,
+		PeriodStart:      periodStart,
+		PeriodEnd:        periodEnd,
+		OrderCount:       len(orders),
+		GrossAmount:      model.Money(grossCents),
+		RefundAmount:     model.Money(refundCents),
+		CommissionRate:   store.CommissionRate,
+		CommissionAmount: model.Money(commissionCents),
+		NetAmount:        model.Money(baseCents - commissionCents),
+		Status:           model.SettlementStatusPending,
+		OperatorID:       operatorID,
+		Items:            items,
+		CreatedAt:        time.Now(),
+	}
+	if err := tx.Create(settlement).Error; err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// ListSettlements 结算单列表，门店账号只能看到自己门店的结算单
+func (s *SettlementService) ListSettlements(operatorID int64, operatorRole string, storeID int64, status *int, page, size int) ([]model.StoreSettlement, int64, error) {
+	var list []model.StoreSettlement
+	var total int64
+
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, 0, err
+	}
+	db := scope.Apply(global.DB.Model(&model.StoreSettlement{}), "store_id")
+	if storeID > 0 {
+		db = db.Where("store_id = ?", storeID)
+	}
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err = db.Preload("Store").Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// GetSettlement 结算单详情及明细
+func (s *SettlementService) GetSettlement(operatorID int64, operatorRole string, id int64) (*model.StoreSettlement, error) {
+	var settlement model.StoreSettlement
+	if err := global.DB.Preload("Store").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("occurred_at asc, id asc") }).
+		First(&settlement, id).Error; err != nil {
+		return nil, errors.New("结算单不存在")
+	}
+	if err := checkStoreScope(operatorID, operatorRole, settlement.StoreID); err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// ConfirmSettlement 确认结算单 (Admin)
+func (s *SettlementService) ConfirmSettlement(operatorID, id int64) error {
+	now := time.Now()
+	return updateSettlementStatus(id, model.SettlementStatusPending, map[string]interface{}{
+		"status":       model.SettlementStatusConfirmed,
+		"operator_id":  operatorID,
+		"confirmed_at": &now,
+	})
+}
+
+// MarkSettlementPaid 标记结算单已打款，冲减应付门店账户并确认平台佣金 (Admin)
+func (s *SettlementService) MarkSettlementPaid(operatorID, id int64, remark string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var settlement model.StoreSettlement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&settlement, id).Error; err != nil {
+			return errors.New("结算单不存在")
+		}
+		if settlement.Status != model.SettlementStatusConfirmed {
+			return errors.New("结算单不存在或状态已变更")
+		}
+
+		payableCents := settlement.GrossAmount.Cents() - settlement.RefundAmount.Cents()
+		commissionCents := settlement.CommissionAmount.Cents()
+		if _, err := postLedgerEntry(tx, LedgerBizSettlement, settlement.ID, "Settlement "+settlement.SettlementNo,
+			ledgerLeg{AccountType: model.LedgerAccountStorePayable, OwnerID: settlement.StoreID, Amount: -payableCents},
+			ledgerLeg{AccountType: model.LedgerAccountPlatformRevenue, Amount: commissionCents},
+			ledgerLeg{AccountType: model.LedgerAccountCashClearing, Amount: payableCents - commissionCents},
+		); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&model.StoreSettlement{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      model.SettlementStatusPaid,
+			"operator_id": operatorID,
+			"remark":      remark,
+			"paid_at":     &now,
+		}).Error
+	})
+}
+
+// CancelSettlement 作废待确认的结算单并释放明细，下次结算时重新计入 (Admin)
+func (s *SettlementService) CancelSettlement(operatorID, id int64, remark string) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var settlement model.StoreSettlement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&settlement, id).Error; err != nil {
+			return errors.New("结算单不存在")
+		}
+		if settlement.Status != model.SettlementStatusPending {
+			return errors.New("只能作废待确认的结算单")
+		}
+		if err := tx.Where("settlement_id = ?", id).Delete(&model.StoreSettlementItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.StoreSettlement{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"status":      model.SettlementStatusCancelled,
+			"operator_id": operatorID,
+			"remark":      remark,
+		}).Error
+	})
+}
+
+func updateSettlementStatus(id int64, from int, updates map[string]interface{}) error {
+	result := global.DB.Model(&model.StoreSettlement{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("结算单不存在或状态已变更")
+	}
+	return nil
+}
+
+var settlementStatusNames = map[int]string{
+	model.SettlementStatusPending:   "待确认",
+	model.SettlementStatusConfirmed: "待打款",
+	model.SettlementStatusPaid:      "已打款",
+	model.SettlementStatusCancelled: "已作废",
+}
+
+// WriteSettlementCSV 输出结算对账单，包含汇总与逐笔明细
+func WriteSettlementCSV(w io.Writer, settlement *model.StoreSettlement) error {
+	// UTF-8 BOM，保证 Excel 打开中文不乱码
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	rows := [][]string{
+		{"结算单号", settlement.SettlementNo},
+		{"门店", settlement.Store.Name},
+		{"结算周期", settlement.PeriodStart.Format("2006-01-02 15:04:05") + " ~ " + settlement.PeriodEnd.Format("2006-01-02 15:04:05")},
+		{"状态", settlementStatusNames[settlement.Status]},
+		{"订单数", fmt.Sprint(settlement.OrderCount)},
+		{"订单收入", settlement.GrossAmount.String()},
+		{"退款扣减", settlement.RefundAmount.String()},
+		{"佣金比例", fmt.Sprintf("%.2f%%", settlement.CommissionRate*100)},
+		{"平台佣金", settlement.CommissionAmount.String()},
+		{"应结金额", settlement.NetAmount.String()},
+		{},
+		{"类型", "订单号", "业务ID", "金额", "发生时间"},
+	}
+	for _, item := range settlement.Items {
+		itemType := "订单收入"
+		if item.ItemType == model.SettlementItemRefund {
+			itemType = "退款扣减"
+		}
+		rows = append(rows, []string{
+			itemType,
+			item.OrderNo,
+			fmt.Sprint(item.RelatedID),
+			item.Amount.String(),
+			item.OccurredAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+	"smartcommunity/pkg/utils"
+)
+
+type StatementService struct{}
+
+const (
+	StatementFormatCSV  = "csv"
+	StatementFormatXLSX = "xlsx"
+
+	// 对账单中积分记录的类型筛选值
+	StatementTypePoints = "points"
+
+	statementKindBalance = "balance"
+	statementKindPoints  = "points"
+
+	// 用户自助导出的最大时间跨度
+	StatementUserMaxRange = 366 * 24 * time.Hour
+)
+
+// statementTypeCodes 筛选参数到流水类型的映射
+var statementTypeCodes = map[string]int{
+	"order":        PayTypeOrder,
+	"property_fee": PayTypePropertyFee,
+	"topup":        TransactionTypeTopUp,
+	"transfer":     TransactionTypeTransfer,
+	"refund":       TransactionTypeRefund,
+	"adjust":       TransactionTypeAdjust,
+}
+
+var statementTypeNames = map[int]string{
+	PayTypeOrder:            "商城消费",
+	PayTypePropertyFee:      "物业缴费",
+	TransactionTypeTopUp:    "充值",
+	TransactionTypeTransfer: "转账",
+	TransactionTypeRefund:   "退款",
+	TransactionTypeAdjust:   "余额调整",
+}
+
+var statementPointActionNames = map[string]string{
+	"mall_consume":           "商城积分抵扣",
+	"property_fee":           "物业费积分抵扣",
+	"order_refund":           "退款退回积分",
+	"garbage_classification": "垃圾分类奖励",
+	"reconcile_correction":   "对账补录",
+	pointActionExpired:       "积分过期",
+	pointActionRedeem:        "积分兑换",
+	pointActionRedeemRefund:  "兑换取消退回",
+}
+
+// StatementFilter 对账单查询条件，UserID 为 0 时导出全部用户
+type StatementFilter struct {
+	UserID int64
+	Start  time.Time
+	End    time.Time
+	Types  []string
+}
+
+// StatementRow 对账单中的一行，余额流水与积分记录合并按时间排序
+type StatementRow struct {
+	Kind      string
+	ID        int64
+	UserID    int64
+	Username  string
+	Mobile    string
+	TxType    int
+	Action    string
+	Amount    model.Money
+	Points    int
+	RelatedID int64
+	Remark    string
+	CreatedAt time.Time
+}
+
+// ParseStatementTypes 解析逗号分隔的类型筛选，如 "order,topup,points"
+func ParseStatementTypes(raw string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if _, ok := statementTypeCodes[t]; !ok && t != StatementTypePoints {
+			return nil, fmt.Errorf("不支持的流水类型: %s", t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// Stream 按时间顺序逐行读取对账单，数据库游标遍历，不一次性加载
+func (s *StatementService) Stream(filter StatementFilter, fn func(*StatementRow) error) error {
+	if !filter.End.After(filter.Start) {
+		return errors.New("结束时间需晚于开始时间")
+	}
+
+	var codes []int
+	includePoints := len(filter.Types) == 0
+	for _, t := range filter.Types {
+		if t == StatementTypePoints {
+			includePoints = true
+			continue
+		}
+		codes = append(codes, statementTypeCodes[t])
+	}
+	includeBalance := len(filter.Types) == 0 || len(codes) > 0
+
+	var parts []string
+	var args []interface{}
+	if includeBalance {
+		where := "created_at >= ? AND created_at < ?"
+		partArgs := []interface{}{filter.Start, filter.End}
+		if filter.UserID > 0 {
+			where += " AND user_id = ?"
+			partArgs = append(partArgs, filter.UserID)
+		}
+		if len(codes) > 0 {
+			where += " AND type IN ?"
+			partArgs = append(partArgs, codes)
+		}
+		parts = append(parts, "SELECT '"+statementKindBalance+"' AS kind, id, user_id, type AS tx_type, '' AS action, amount, 0 AS points, related_id, remark, created_at FROM sys_transaction WHERE "+where)
+		args = append(args, partArgs...)
+	}
+	if includePoints {
+		where := "created_at >= ? AND created_at < ?"
+		partArgs := []interface{}{filter.Start, filter.End}
+		if filter.UserID > 0 {
+			where += " AND user_id = ?"
+			partArgs = append(partArgs, filter.UserID)
+		}
+		parts = append(parts, "SELECT '"+statementKindPoints+"' AS kind, id, user_id, 0 AS tx_type, action, 0 AS amount, points, 0 AS related_id, '' AS remark, created_at FROM green_point_record WHERE "+where)
+		args = append(args, partArgs...)
+	}
+
+	query := "SELECT s.*, COALESCE(u.username, '') AS username, COALESCE(u.mobile, '') AS mobile FROM (" + strings.Join(parts, " UNION ALL ") + ") s " +
+		"LEFT JOIN sys_user u ON u.id = s.user_id ORDER BY s.created_at, s.kind, s.id"
+	rows, err := global.DB.Raw(query, args...).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row StatementRow
+		if err := global.DB.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Export 以 CSV 或 XLSX 格式写出对账单，withUser 为 true 时附带用户列（后台导出）
+func (s *StatementService) Export(w io.Writer, format string, filter StatementFilter, withUser bool) error {
+	header := []string{"时间", "类别", "类型", "金额(元)", "积分", "业务ID", "备注"}
+	if withUser {
+		header = append([]string{"用户ID", "用户名", "手机号"}, header...)
+	}
+
+	switch format {
+	case StatementFormatCSV:
+		// UTF-8 BOM，保证 Excel 打开中文不乱码
+		if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		err := s.Stream(filter, func(row *StatementRow) error {
+			record := []string{
+				row.CreatedAt.Format("2006-01-02 15:04:05"),
+				statementKindName(row.Kind),
+				statementRowTypeName(row),
+				row.Amount.String(),
+				fmt.Sprint(row.Points),
+				fmt.Sprint(row.RelatedID),
+				csvSafeText(row.Remark),
+			}
+			if withUser {
+				record = append([]string{fmt.Sprint(row.UserID), csvSafeText(row.Username), maskMobile(row.Mobile)}, record...)
+			}
+			return cw.Write(record)
+		})
+		cw.Flush()
+		if err != nil {
+			return err
+		}
+		return cw.Error()
+	case StatementFormatXLSX:
+		xw, err := utils.NewXLSXStreamWriter(w, "对账单")
+		if err != nil {
+			return err
+		}
+		cells := make([]interface{}, len(header))
+		for i, h := range header {
+			cells[i] = h
+		}
+		if err := xw.WriteRow(cells...); err != nil {
+			return err
+		}
+		err = s.Stream(filter, func(row *StatementRow) error {
+			cells := []interface{}{
+				row.CreatedAt.Format("2006-01-02 15:04:05"),
+				statementKindName(row.Kind),
+				statementRowTypeName(row),
+				row.Amount.Float(),
+				row.Points,
+				row.RelatedID,
+				row.Remark,
+			}
+			if withUser {
+				cells = append([]interface{}{row.UserID, row.Username, maskMobile(row.Mobile)}, cells...)
+			}
+			return xw.WriteRow(cells...)
+		})
+		if err != nil {
+			return err
+		}
+		return xw.Close()
+	}
+	return errors.New("不支持的导出格式")
+}
+
+func statementKindName(kind string) string {
+	if kind == statementKindPoints {
+		return "积分"
+	}
+	return "余额"
+}
+
+func statementRowTypeName(row *StatementRow) string {
+	if row.Kind == statementKindPoints {
+		if name, ok := statementPointActionNames[row.Action]; ok {
+			return name
+		}
+		return row.Action
+	}
+	if name, ok := statementTypeNames[row.TxType]; ok {
+		return name
+	}
+	return fmt.Sprintf("类型%d", row.TxType)
+}
+
+// csvSafeText 防止以公式字符开头的文本在 Excel 中被当作公式执行
+func csvSafeText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"path/filepath"
 	"smartcommunity/internal/config"
@@ -35,11 +37,35 @@ func (s *StorageService) UploadMultipartFile(fileHeader *multipart.FileHeader, d
 		return "", "", err
 	}
 
+	return s.objectURL(info.Key), info.Key, nil
+}
+
+// PutBytes 上传服务端生成的文件（如电子收据），objectName 由调用方决定
+func (s *StorageService) PutBytes(objectName, contentType string, data []byte) (string, string, error) {
+	ctx := context.Background()
+	info, err := global.MinioClient.PutObject(ctx, config.Conf.MinIO.Bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return s.objectURL(info.Key), info.Key, nil
+}
+
+// GetBytes 读取已存储的对象内容
+func (s *StorageService) GetBytes(objectName string) ([]byte, error) {
+	obj, err := global.MinioClient.GetObject(context.Background(), config.Conf.MinIO.Bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+func (s *StorageService) objectURL(key string) string {
 	protocol := "http://"
 	if config.Conf.MinIO.UseSSL {
 		protocol = "https://"
 	}
-	url := fmt.Sprintf("%s%s/%s/%s", protocol, config.Conf.MinIO.Endpoint, bucketName, info.Key)
-
-	return url, info.Key, nil
+	return fmt.Sprintf("%s%s/%s/%s", protocol, config.Conf.MinIO.Endpoint, config.Conf.MinIO.Bucket, key)
 }
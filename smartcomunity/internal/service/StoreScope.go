@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ErrStoreForbidden 操作了未绑定门店的数据
+var ErrStoreForbidden = errors.New("无权操作该门店的数据")
+
+// StoreScope 后台账号可访问的门店范围：admin 不受限，store 角色仅限已绑定的门店
+type StoreScope struct {
+	All      bool
+	StoreIDs []int64
+}
+
+// ResolveStoreScope 根据登录账号的角色解析门店范围，admin 与 store 以外的角色无权访问门店数据
+func ResolveStoreScope(userID int64, role string) (*StoreScope, error) {
+	if role == "admin" {
+		return &StoreScope{All: true}, nil
+	}
+	if role != "store" {
+		return nil, ErrStoreForbidden
+	}
+	var storeIDs []int64
+	if err := global.DB.Model(&model.SysUserStore{}).
+		Where("user_id = ?", userID).
+		Order("store_id asc").
+		Pluck("store_id", &storeIDs).Error; err != nil {
+		return nil, err
+	}
+	return &StoreScope{StoreIDs: storeIDs}, nil
+}
+
+// Allows 是否可以访问指定门店
+func (s *StoreScope) Allows(storeID int64) bool {
+	if s.All {
+		return true
+	}
+	for _, id := range s.StoreIDs {
+		if id == storeID && storeID > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 不可访问时返回 ErrStoreForbidden
+func (s *StoreScope) Check(storeID int64) error {
+	if !s.Allows(storeID) {
+		return ErrStoreForbidden
+	}
+	return nil
+}
+
+// Apply 按门店范围过滤查询，column 为门店字段名
+func (s *StoreScope) Apply(db *gorm.DB, column string) *gorm.DB {
+	if s.All {
+		return db
+	}
+	if len(s.StoreIDs) == 0 {
+		return db.Where("1 = 0")
+	}
+	return db.Where(column+" IN ?", s.StoreIDs)
+}
+
+// checkStoreScope 解析范围并校验门店权限
+func checkStoreScope(userID int64, role string, storeID int64) error {
+	scope, err := ResolveStoreScope(userID, role)
+	if err != nil {
+		return err
+	}
+	return scope.Check(storeID)
+}
@@ -1,8 +1,15 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"smartcommunity/internal/global"
 	"smartcommunity/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type StoreService struct{}
@@ -21,8 +28,31 @@ func (s *StoreService) CreateStore(store *model.Store) error {
 }
 
 // UpdateStore 修改门店
-func (s *StoreService) UpdateStore(store *model.Store) error {
-	return global.DB.Model(&model.Store{}).Where("id = ?", store.ID).Updates(store).Error
+func (s *StoreService) UpdateStore(operatorID int64, operatorRole string, store *model.Store) error {
+	if err := checkStoreScope(operatorID, operatorRole, store.ID); err != nil {
+		return err
+	}
+	// 佣金比例只能通过 SetCommissionRate 修改
+	return global.DB.Model(&model.Store{}).Where("id = ?", store.ID).Omit("commission_rate").Updates(store).Error
+}
+
+// SetCommissionRate 设置门店平台佣金比例 (Admin)
+func (s *StoreService) SetCommissionRate(storeID int64, rate float64) error {
+	if rate < 0 || rate >= 1 {
+		return errors.New("佣金比例需在 0 到 1 之间")
+	}
+	result := global.DB.Model(&model.Store{}).Where("id = ?", storeID).Update("commission_rate", rate)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		global.DB.Model(&model.Store{}).Where("id = ?", storeID).Count(&count)
+		if count == 0 {
+			return errors.New("门店不存在")
+		}
+	}
+	return nil
 }
 
 // DeleteStore 删除门店
@@ -31,20 +61,44 @@ func (s *StoreService) DeleteStore(id int64) error {
 }
 
 // BindProduct 为门店分配商品库存
-func (s *StoreService) BindProduct(storeID, productID int64, stock int) error {
-	var sp model.StoreProduct
-	// 检查是否已存在
-	if err := global.DB.Where("store_id = ? AND product_id = ?", storeID, productID).First(&sp).Error; err == nil {
-		// 已存在则更新库存
-		return global.DB.Model(&sp).Update("stock", stock).Error
+func (s *StoreService) BindProduct(operatorID int64, operatorRole string, storeID, productID int64, stock int) error {
+	if stock < 0 {
+		return errors.New("库存不能为负数")
 	}
-	// 不存在则创建
-	sp = model.StoreProduct{
-		StoreID:   storeID,
-		ProductID: productID,
-		Stock:     stock,
+	if err := checkStoreScope(operatorID, operatorRole, storeID); err != nil {
+		return err
 	}
-	return global.DB.Create(&sp).Error
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var sp model.StoreProduct
+		// 检查是否已存在
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND product_id = ?", storeID, productID).
+			First(&sp).Error
+		if err == nil {
+			// 已存在则更新库存
+			delta := stock - sp.Stock
+			if delta == 0 {
+				return nil
+			}
+			if err := tx.Model(&sp).Update("stock", stock).Error; err != nil {
+				return err
+			}
+			return writeStockMovement(tx, storeID, productID, model.StockMovementAdjust, delta, stock, 0, operatorID, "")
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		// 不存在则创建
+		sp = model.StoreProduct{
+			StoreID:   storeID,
+			ProductID: productID,
+			Stock:     stock,
+		}
+		if err := tx.Create(&sp).Error; err != nil {
+			return err
+		}
+		return writeStockMovement(tx, storeID, productID, model.StockMovementAdjust, stock, stock, 0, operatorID, "")
+	})
 }
 
 // GetStoreStock 获取门店某商品库存
@@ -53,3 +107,209 @@ func (s *StoreService) GetStoreStock(storeID, productID int64) (int, error) {
 	err := global.DB.Where("store_id = ? AND product_id = ?", storeID, productID).First(&sp).Error
 	return sp.Stock, err
 }
+
+// ListStoreStock 门店商品库存列表
+func (s *StoreService) ListStoreStock(operatorID int64, operatorRole string, storeID int64, page, size int) ([]model.StoreProduct, int64, error) {
+	if err := checkStoreScope(operatorID, operatorRole, storeID); err != nil {
+		return nil, 0, err
+	}
+	var list []model.StoreProduct
+	var total int64
+	db := global.DB.Model(&model.StoreProduct{}).Where("store_id = ?", storeID)
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Preload("Product").Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// TransferStock 门店间调拨库存
+func (s *StoreService) TransferStock(operatorID int64, operatorRole string, fromStoreID, toStoreID, productID int64, quantity int) error {
+	if quantity <= 0 {
+		return errors.New("调拨数量必须大于0")
+	}
+	if fromStoreID == toStoreID {
+		return errors.New("调出门店与调入门店不能相同")
+	}
+	// 门店账号只能在自己绑定的门店之间调拨
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return err
+	}
+	if err := scope.Check(fromStoreID); err != nil {
+		return err
+	}
+	if err := scope.Check(toStoreID); err != nil {
+		return err
+	}
+
+	var count int64
+	global.DB.Model(&model.Store{}).Where("id = ?", toStoreID).Count(&count)
+	if count == 0 {
+		return errors.New("调入门店不存在")
+	}
+
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		remark := fmt.Sprintf("transfer %d -> %d", fromStoreID, toStoreID)
+		if err := decreaseStoreStock(tx, fromStoreID, productID, quantity, model.StockMovementTransferOut, toStoreID, operatorID, remark); err != nil {
+			return err
+		}
+
+		var target model.StoreProduct
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND product_id = ?", toStoreID, productID).
+			First(&target).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			target = model.StoreProduct{StoreID: toStoreID, ProductID: productID}
+			if err := tx.Create(&target).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		_, err = increaseStoreStock(tx, toStoreID, productID, quantity, model.StockMovementTransferIn, fromStoreID, operatorID, remark)
+		return err
+	})
+}
+
+// ListStockMovements 门店库存流水
+func (s *StoreService) ListStockMovements(operatorID int64, operatorRole string, storeID, productID int64, page, size int) ([]model.StoreStockMovement, int64, error) {
+	if err := checkStoreScope(operatorID, operatorRole, storeID); err != nil {
+		return nil, 0, err
+	}
+	var list []model.StoreStockMovement
+	var total int64
+	db := global.DB.Model(&model.StoreStockMovement{}).Where("store_id = ?", storeID)
+	if productID > 0 {
+		db = db.Where("product_id = ?", productID)
+	}
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error
+	return list, total, err
+}
+
+// BindUser 将门店账号绑定到门店 (Admin)
+func (s *StoreService) BindUser(userID, storeID int64) error {
+	var user model.SysUser
+	if err := global.DB.First(&user, userID).Error; err != nil {
+		return errors.New("用户不存在")
+	}
+	if user.Role != "store" {
+		return errors.New("只能绑定门店角色的账号")
+	}
+	var count int64
+	global.DB.Model(&model.Store{}).Where("id = ?", storeID).Count(&count)
+	if count == 0 {
+		return errors.New("门店不存在")
+	}
+
+	binding := model.SysUserStore{UserID: userID, StoreID: storeID, CreatedAt: time.Now()}
+	return global.DB.Where("user_id = ? AND store_id = ?", userID, storeID).FirstOrCreate(&binding).Error
+}
+
+// UnbindUser 解除门店账号与门店的绑定 (Admin)
+func (s *StoreService) UnbindUser(userID, storeID int64) error {
+	return global.DB.Where("user_id = ? AND store_id = ?", userID, storeID).Delete(&model.SysUserStore{}).Error
+}
+
+// ListStoreUsers 门店绑定的账号 (Admin)
+func (s *StoreService) ListStoreUsers(storeID int64) ([]model.SysUserStore, error) {
+	var list []model.SysUserStore
+	err := global.DB.Preload("SysUser").Where("store_id = ?", storeID).Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// ListManagedStores 当前账号可管理的门店
+func (s *StoreService) ListManagedStores(operatorID int64, operatorRole string) ([]model.Store, error) {
+	scope, err := ResolveStoreScope(operatorID, operatorRole)
+	if err != nil {
+		return nil, err
+	}
+	var list []model.Store
+	err = scope.Apply(global.DB.Model(&model.Store{}), "id").Order("id asc").Find(&list).Error
+	return list, err
+}
+
+// storeManagesStock 门店是否启用了独立库存(至少绑定过一个商品)
+func storeManagesStock(tx *gorm.DB, storeID int64) (bool, error) {
+	if storeID <= 0 {
+		return false, nil
+	}
+	var count int64
+	if err := tx.Model(&model.StoreProduct{}).Where("store_id = ?", storeID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// decreaseStoreStock 扣减门店库存，库存不足或未铺货时返回错误
+func decreaseStoreStock(tx *gorm.DB, storeID, productID int64, quantity int, movementType string, relatedID, operatorID int64, remark string) error {
+	result := tx.Model(&model.StoreProduct{}).
+		Where("store_id = ? AND product_id = ? AND stock >= ?", storeID, productID, quantity).
+		Update("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("门店库存不足")
+	}
+
+	var sp model.StoreProduct
+	if err := tx.Where("store_id = ? AND product_id = ?", storeID, productID).First(&sp).Error; err != nil {
+		return err
+	}
+	return writeStockMovement(tx, storeID, productID, movementType, -quantity, sp.Stock, relatedID, operatorID, remark)
+}
+
+// increaseStoreStock 归还门店库存，门店未绑定该商品时跳过并返回 false
+func increaseStoreStock(tx *gorm.DB, storeID, productID int64, quantity int, movementType string, relatedID, operatorID int64, remark string) (bool, error) {
+	result := tx.Model(&model.StoreProduct{}).
+		Where("store_id = ? AND product_id = ?", storeID, productID).
+		Update("stock", gorm.Expr("stock + ?", quantity))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	var sp model.StoreProduct
+	if err := tx.Where("store_id = ? AND product_id = ?", storeID, productID).First(&sp).Error; err != nil {
+		return false, err
+	}
+	return true, writeStockMovement(tx, storeID, productID, movementType, quantity, sp.Stock, relatedID, operatorID, remark)
+}
+
+// returnProductStock 按占用时记录的库存池归还库存：storeStock 为 true 时退回门店库存，否则退回商品总库存。
+// 门店在此期间解绑了该商品时重新建立绑定，保证库存回到原来的池子
+func returnProductStock(tx *gorm.DB, storeStock bool, storeID, productID int64, quantity int, movementType string, relatedID, operatorID int64, remark string) error {
+	if !storeStock || storeID <= 0 {
+		return tx.Model(&model.Product{}).Where("id = ?", productID).
+			Update("stock", gorm.Expr("stock + ?", quantity)).Error
+	}
+	restored, err := increaseStoreStock(tx, storeID, productID, quantity, movementType, relatedID, operatorID, remark)
+	if err != nil || restored {
+		return err
+	}
+	if err := tx.Create(&model.StoreProduct{StoreID: storeID, ProductID: productID, Stock: quantity}).Error; err != nil {
+		return err
+	}
+	return writeStockMovement(tx, storeID, productID, movementType, quantity, quantity, relatedID, operatorID, remark)
+}
+
+func writeStockMovement(tx *gorm.DB, storeID, productID int64, movementType string, change, stockAfter int, relatedID, operatorID int64, remark string) error {
+	movement := model.StoreStockMovement{
+		StoreID:    storeID,
+		ProductID:  productID,
+		Type:       movementType,
+		Change:     change,
+		StockAfter: stockAfter,
+		RelatedID:  relatedID,
+		OperatorID: operatorID,
+		Remark:     remark,
+		CreatedAt:  time.Now(),
+	}
+	return tx.Create(&movement).Error
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+const transferSettleScanInterval = 30 * time.Second
+
+// StartTransferSettleScheduler 定期将撤销期已过的转账入账到收款人
+func StartTransferSettleScheduler() {
+	transferService := &TransferService{}
+
+	go func() {
+		log.Printf("transfer settle scheduler armed, window=%s interval=%s", transferPendingWindow(), transferSettleScanInterval)
+		ticker := time.NewTicker(transferSettleScanInterval)
+		defer ticker.Stop()
+
+		for {
+			count, err := transferService.SettleDueTransfers()
+			if err != nil {
+				log.Printf("settle pending transfers failed: %v", err)
+			} else if count > 0 {
+				log.Printf("settled %d pending transfers", count)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"smartcommunity/internal/config"
+	"smartcommunity/internal/global"
+	"smartcommunity/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransferService struct{}
+
+const (
+	defaultTransferPendingMinutes = 10
+	transferConfirmTTL            = 5 * time.Minute
+	transferSettleBatch           = 200
+)
+
+var defaultTransferLimit = config.TransferLimit{PerTransaction: 2000, Daily: 5000}
+
+// TransferRequest 发起转账的参数，ConfirmToken 来自收款人确认步骤
+type TransferRequest struct {
+	ToMobile     string
+	Amount       model.Money
+	ConfirmToken string
+	AuthType     string
+	Password     string
+	Remark       string
+}
+
+// TransferPreview 收款人确认信息，姓名和手机号已脱敏
+type TransferPreview struct {
+	ConfirmToken   string      `json:"confirm_token"`
+	PayeeName      string      `json:"payee_name"`
+	PayeeMobile    string      `json:"payee_mobile"`
+	Amount         model.Money `json:"amount"`
+	PendingMinutes int         `json:"pending_minutes"`
+	PerTransaction model.Money `json:"per_transaction_limit"` // 0 表示不限
+	DailyRemaining model.Money `json:"daily_remaining"`       // -1 表示不限
+	ExpiresIn      int         `json:"expires_in"`            // 确认凭证有效秒数
+}
+
+func transferPendingWindow() time.Duration {
+	if config.Conf == nil {
+		return defaultTransferPendingMinutes * time.Minute
+	}
+	if config.Conf.Transfer.PendingMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(config.Conf.Transfer.PendingMinutes) * time.Minute
+}
+
+// transferLimitFor 按角色取限额，未配置的角色使用 default
+func transferLimitFor(role string) config.TransferLimit {
+	if config.Conf != nil && len(config.Conf.Transfer.Limits) > 0 {
+		if limit, ok := config.Conf.Transfer.Limits[role]; ok {
+			return limit
+		}
+		if limit, ok := config.Conf.Transfer.Limits["default"]; ok {
+			return limit
+		}
+	}
+	return defaultTransferLimit
+}
+
+// checkTransferLimit 校验单笔和当日累计限额，返回当日剩余额度(-1 表示不限)
+func checkTransferLimit(db *gorm.DB, user *model.SysUser, amount model.Money) (model.Money, error) {
+	limit := transferLimitFor(user.Role)
+	if limit.PerTransaction > 0 && amount > model.Yuan(limit.PerTransaction) {
+		return 0, fmt.Errorf("单笔转账不能超过 %s 元", model.Yuan(limit.PerTransaction).String())
+	}
+	if limit.Daily <= 0 {
+		return -1, nil
+	}
+
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var used model.Money
+	if err := db.Model(&model.Transfer{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("from_user_id = ? AND status <> ? AND created_at >= ?", user.ID, model.TransferStatusCancelled, dayStart).
+		Scan(&used).Error; err != nil {
+		return 0, err
+	}
+	remaining := model.Yuan(limit.Daily) - used
+	if amount > remaining {
+		if remaining < 0 {
+			remaining = 0
+		}
+		return remaining, fmt.Errorf("超出当日转账限额，今日还可转 %s 元", remaining.String())
+	}
+	return remaining - amount, nil
+}
+
+// Preview 确认收款人：返回脱敏信息和一次性确认凭证，凭证与付款人、收款人和金额绑定
+func (s *TransferService) Preview(fromUserID int64, toMobile string, amount model.Money) (*TransferPreview, error) {
+	if amount <= 0 {
+		return nil, errors.New("转账金额必须大于 0")
+	}
+	var fromUser model.SysUser
+	if err := global.DB.First(&fromUser, fromUserID).Error; err != nil {
+		return nil, errors.New("付款人不存在")
+	}
+	var toUser model.SysUser
+	if err := global.DB.Where("mobile = ?", strings.TrimSpace(toMobile)).First(&toUser).Error; err != nil {
+		return nil, errors.New("收款人不存在")
+	}
+	if toUser.ID == fromUserID {
+		return nil, errors.New("不能转账给自己")
+	}
+	if fromUser.Balance < amount {
+		return nil, errors.New("余额不足")
+	}
+	remaining, err := checkTransferLimit(global.DB, &fromUser, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+	if err := global.RDB.Set(context.Background(), transferConfirmKey(token), transferConfirmValue(fromUserID, toUser.ID, amount), transferConfirmTTL).Err(); err != nil {
+		return nil, errors.New("系统繁忙，请稍后再试")
+	}
+
+	return &TransferPreview{
+		ConfirmToken:   token,
+		PayeeName:      maskName(displayName(&toUser)),
+		PayeeMobile:    maskMobile(toUser.Mobile),
+		Amount:         amount,
+		PendingMinutes: int(transferPendingWindow() / time.Minute),
+		PerTransaction: model.Yuan(transferLimitFor(fromUser.Role).PerTransaction),
+		DailyRemaining: remaining,
+		ExpiresIn:      int(transferConfirmTTL / time.Second),
+	}, nil
+}
+
+// Create 校验确认凭证和支付认证后扣款，资金进入待到账账户，撤销期结束后入账
+// 人脸认证由调用方在进入服务前完成
+func (s *TransferService) Create(fromUserID int64, req TransferRequest) (*model.Transfer, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("转账金额必须大于 0")
+	}
+	authType, err := normalizePaymentAuthType(req.AuthType)
+	if err != nil {
+		return nil, err
+	}
+	if req.ConfirmToken == "" {
+		return nil, errors.New("请先确认收款人信息")
+	}
+	// 确认凭证在全部校验通过后才作废，输错密码等情况无需重新确认收款人
+	confirmKey := transferConfirmKey(req.ConfirmToken)
+	confirmed, err := global.RDB.Get(context.Background(), confirmKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, errors.New("收款人确认已失效，请重新确认")
+	}
+	if err != nil {
+		return nil, errors.New("系统繁忙，请稍后再试")
+	}
+
+	var transfer *model.Transfer
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		var fromUser model.SysUser
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fromUser, fromUserID).Error; err != nil {
+			return errors.New("付款人不存在")
+		}
+		if authType == AuthTypePassword {
+			if err := checkPaymentPassword(&fromUser, req.Password); err != nil {
+				return err
+			}
+		}
+
+		var toUser model.SysUser
+		if err := tx.Where("mobile = ?", strings.TrimSpace(req.ToMobile)).First(&toUser).Error; err != nil {
+			return errors.New("收款人不存在")
+		}
+		if toUser.ID == fromUserID {
+			return errors.New("不能转账给自己")
+		}
+		if confirmed != transferConfirmValue(fromUserID, toUser.ID, req.Amount) {
+			return errors.New("收款人或金额与确认信息不一致，请重新确认")
+		}
+		if fromUser.Balance < req.Amount {
+			return errors.New("余额不足")
+		}
+		if _, err := checkTransferLimit(tx, &fromUser, req.Amount); err != nil {
+			return err
+		}
+		// 删除成功才算占用凭证，并发提交同一凭证时只有一笔能通过
+		deleted, err := global.RDB.Del(context.Background(), confirmKey).Result()
+		if err != nil {
+			return errors.New("系统繁忙，请稍后再试")
+		}
+		if deleted == 0 {
+			return errors.New("收款人确认已失效，请重新确认")
+		}
+
+		now := time.Now()
+		transfer = &model.Transfer{
+			TransferNo: fmt.Sprintf("TF%d%d", now.UnixNano(), fromUserID),
+			FromUserID: fromUserID,
+			ToUserID:   toUser.ID,
+			Amount:     req.Amount,
+			Remark:     req.Remark,
+			AuthType:   authType,
+			Status:     model.TransferStatusPending,
+			SettleAt:   now.Add(transferPendingWindow()),
+			CreatedAt:  now,
+		}
+		if err := tx.Create(transfer).Error; err != nil {
+			return err
+		}
+
+		cents := req.Amount.Cents()
+		if _, err := postLedgerEntry(tx, LedgerBizTransfer, transfer.ID, "Transfer to "+toUser.Username,
+			ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: fromUserID, Amount: -cents},
+			ledgerLeg{AccountType: model.LedgerAccountTransferPending, Amount: cents},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&model.SysTransaction{
+			UserID:    fromUserID,
+			Type:      TransactionTypeTransfer,
+			Amount:    -req.Amount,
+			RelatedID: transfer.ID,
+			Remark:    "Transfer to " + toUser.Username,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if !transfer.SettleAt.After(now) {
+			return settleTransfer(tx, transfer, fromUser.Username)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// Cancel 付款人在撤销期内撤回转账，资金原路退回
+func (s *TransferService) Cancel(userID, transferID int64) error {
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var transfer model.Transfer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND from_user_id = ?", transferID, userID).
+			First(&transfer).Error; err != nil {
+			return errors.New("转账记录不存在")
+		}
+		if transfer.Status != model.TransferStatusPending {
+			return errors.New("该转账已到账或已撤销")
+		}
+		now := time.Now()
+		if !now.Before(transfer.SettleAt) {
+			return errors.New("已超过可撤销时间")
+		}
+
+		cents := transfer.Amount.Cents()
+		if _, err := postLedgerEntry(tx, LedgerBizTransfer, transfer.ID, "Transfer cancelled",
+			ledgerLeg{AccountType: model.LedgerAccountTransferPending, Amount: -cents},
+			ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: userID, Amount: cents},
+		); err != nil {
+			return err
+		}
+		if err := tx.Create(&model.SysTransaction{
+			UserID:    userID,
+			Type:      TransactionTypeTransfer,
+			Amount:    transfer.Amount,
+			RelatedID: transfer.ID,
+			Remark:    "Transfer cancelled " + transfer.TransferNo,
+			CreatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&transfer).Updates(map[string]interface{}{
+			"status":       model.TransferStatusCancelled,
+			"cancelled_at": &now,
+		}).Error
+	})
+}
+
+// SettleDueTransfers 将撤销期已过的转账入账到收款人，返回本次到账笔数
+func (s *TransferService) SettleDueTransfers() (int, error) {
+	var ids []int64
+	if err := global.DB.Model(&model.Transfer{}).
+		Where("status = ? AND settle_at <= ?", model.TransferStatusPending, time.Now()).
+		Order("id asc").Limit(transferSettleBatch).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, id := range ids {
+		err := global.DB.Transaction(func(tx *gorm.DB) error {
+			var transfer model.Transfer
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, id).Error; err != nil {
+				return err
+			}
+			// 加锁后再确认状态，可能已被撤销
+			if transfer.Status != model.TransferStatusPending {
+				return nil
+			}
+			var fromUser model.SysUser
+			tx.Select("id, username").First(&fromUser, transfer.FromUserID)
+			if err := settleTransfer(tx, &transfer, fromUser.Username); err != nil {
+				return err
+			}
+			settled++
+			return nil
+		})
+		if err != nil {
+			log.Printf("settle transfer %d failed: %v", id, err)
+		}
+	}
+	return settled, nil
+}
+
+func settleTransfer(tx *gorm.DB, transfer *model.Transfer, fromUsername string) error {
+	cents := transfer.Amount.Cents()
+	if _, err := postLedgerEntry(tx, LedgerBizTransfer, transfer.ID, "Transfer from "+fromUsername,
+		ledgerLeg{AccountType: model.LedgerAccountTransferPending, Amount: -cents},
+		ledgerLeg{AccountType: model.LedgerAccountUserWallet, OwnerID: transfer.ToUserID, Amount: cents},
+	); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := tx.Create(&model.SysTransaction{
+		UserID:    transfer.ToUserID,
+		Type:      TransactionTypeTransfer,
+		Amount:    transfer.Amount,
+		RelatedID: transfer.ID,
+		Remark:    "Transfer from " + fromUsername,
+		CreatedAt: now,
+	}).Error; err != nil {
+		return err
+	}
+	transfer.Status = model.TransferStatusSettled
+	transfer.SettledAt = &now
+	if err := tx.Model(transfer).Updates(map[string]interface{}{
+		"status":     model.TransferStatusSettled,
+		"settled_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	return sendUserNotice(tx, transfer.ToUserID, "转账到账",
+		fmt.Sprintf("%s 向您转账 %s 元，已存入余额。", maskName(fromUsername), transfer.Amount.String()),
+		"transfer", transfer.ID)
+}
+
+// List 用户发出和收到的转账，对方信息脱敏
+func (s *TransferService) List(userID int64, page, size int) ([]model.Transfer, int64, error) {
+	var list []model.Transfer
+	var total int64
+	db := global.DB.Model(&model.Transfer{}).Where("from_user_id = ? OR to_user_id = ?", userID, userID)
+	db.Count(&total)
+
+	offset := (page - 1) * size
+	if err := db.Order("id desc").Offset(offset).Limit(size).Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+
+	userIDs := make([]int64, 0, len(list)*2)
+	for _, t := range list {
+		userIDs = append(userIDs, t.FromUserID, t.ToUserID)
+	}
+	var users []model.SysUser
+	if len(userIDs) > 0 {
+		global.DB.Select("id, username, real_name, mobile").Where("id IN ?", userIDs).Find(&users)
+	}
+	names := make(map[int64]model.SysUser, len(users))
+	for _, u := range users {
+		names[u.ID] = u
+	}
+	for i := range list {
+		from, to := names[list[i].FromUserID], names[list[i].ToUserID]
+		list[i].FromName = maskName(displayName(&from))
+		list[i].ToName = maskName(displayName(&to))
+		list[i].ToMobile = maskMobile(to.Mobile)
+	}
+	return list, total, nil
+}
+
+func transferConfirmKey(token string) string {
+	return "transfer:confirm:" + token
+}
+
+func transferConfirmValue(fromUserID, toUserID int64, amount model.Money) string {
+	return fmt.Sprintf("%d:%d:%d", fromUserID, toUserID, amount.Cents())
+}
+
+func displayName(user *model.SysUser) string {
+	if user.RealName != "" {
+		return user.RealName
+	}
+	return user.Username
+}
+
+// maskName 只保留姓名最后一个字，如 "张三" -> "*三"
+func maskName(name string) string {
+	runes := []rune(name)
+	if len(runes) <= 1 {
+		return name
+	}
+	return strings.Repeat("*", len(runes)-1) + string(runes[len(runes)-1])
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"log"
+	"time"
+)
+
+const walletReconcileHour = 3
+
+// StartWalletReconcileScheduler 每天凌晨对账一次钱包余额与积分
+func StartWalletReconcileScheduler() {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		log.Printf("load Asia/Shanghai location failed, fallback to local: %v", err)
+		location = time.Local
+	}
+
+	reconcileService := &ReconcileService{}
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			nextRun := time.Date(now.Year(), now.Month(), now.Day(), walletReconcileHour, 0, 0, 0, location)
+			if !now.Before(nextRun) {
+				nextRun = nextRun.AddDate(0, 0, 1)
+			}
+
+			log.Printf("wallet reconcile scheduler armed, next run at %s", nextRun.Format("2006-01-02 15:04:05"))
+			timer := time.NewTimer(time.Until(nextRun))
+			<-timer.C
+
+			run, err := reconcileService.RunWalletReconciliation(0)
+			if err != nil {
+				log.Printf("wallet reconciliation failed: %v", err)
+				continue
+			}
+			if run.MismatchCount > 0 {
+				log.Printf("wallet reconciliation %s found %d discrepancies among %d users", run.RunNo, run.MismatchCount, run.CheckedUsers)
+			}
+		}
+	}()
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"time"
+)
+
+const (
+	exifTagDateTime         = 0x0132
+	exifTagExifIFDPointer   = 0x8769
+	exifTagDateTimeOriginal = 0x9003
+)
+
+// ExifDateTime 读取 JPEG 中 EXIF 记录的拍摄时间(优先 DateTimeOriginal)，
+// EXIF 不带时区，按 loc 解析；没有或无法解析时返回 false
+func ExifDateTime(data []byte, loc *time.Location) (time.Time, bool) {
+	tiff := jpegExifSegment(data)
+	if tiff == nil || len(tiff) < 8 {
+		return time.Time{}, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0 := order.Uint32(tiff[4:8])
+	if exifIFD, ok := exifTagValue(tiff, order, ifd0, exifTagExifIFDPointer); ok {
+		if v, ok := exifTagValue(tiff, order, order.Uint32(exifIFD[8:12]), exifTagDateTimeOriginal); ok {
+			if t, ok := parseExifTime(tiff, order, v, loc); ok {
+				return t, true
+			}
+		}
+	}
+	if v, ok := exifTagValue(tiff, order, ifd0, exifTagDateTime); ok {
+		return parseExifTime(tiff, order, v, loc)
+	}
+	return time.Time{}, false
+}
+
+// jpegExifSegment 找到 APP1 Exif 段，返回其中的 TIFF 数据
+func jpegExifSegment(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return nil
+		}
+		marker := data[i+1]
+		// SOS 之后是图像数据，不会再有 EXIF
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return nil
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		i += 2 + size
+	}
+	return nil
+}
+
+// exifTagValue 返回 IFD 中指定标签条目的 4 字节值/偏移字段
+func exifTagValue(tiff []byte, order binary.ByteOrder, offset uint32, tag uint16) ([]byte, bool) {
+	if int(offset)+2 > len(tiff) {
+		return nil, false
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := int(offset) + 2 + i*12
+		if entry+12 > len(tiff) {
+			return nil, false
+		}
+		if order.Uint16(tiff[entry:entry+2]) == tag {
+			return tiff[entry : entry+12], true
+		}
+	}
+	return nil, false
+}
+
+// parseExifTime 解析 ASCII 类型的时间标签，格式为 "2006:01:02 15:04:05"
+func parseExifTime(tiff []byte, order binary.ByteOrder, entry []byte, loc *time.Location) (time.Time, bool) {
+	if order.Uint16(entry[2:4]) != 2 {
+		return time.Time{}, false
+	}
+	n := int(order.Uint32(entry[4:8]))
+	var raw []byte
+	if n <= 4 {
+		raw = entry[8 : 8+n]
+	} else {
+		start := int(order.Uint32(entry[8:12]))
+		if start+n > len(tiff) {
+			return time.Time{}, false
+		}
+		raw = tiff[start : start+n]
+	}
+	value := strings.TrimRight(string(raw), "\x00 ")
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+)
+
+// MaxHashImagePixels 计算指纹允许的最大像素数，避免小文件声明超大尺寸时解码耗尽内存
+const MaxHashImagePixels = 40_000_000
+
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// ImageDHash 计算图片的差异哈希(dHash)：缩放为 9x8 灰度图后逐行比较相邻像素，
+// 对缩放、压缩和轻微调色不敏感，可用汉明距离判断两张图片是否近似
+func ImageDHash(data []byte) (uint64, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxHashImagePixels {
+		return 0, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+
+	const w, h = 9, 8
+	var gray [h][w]float64
+	b := img.Bounds()
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := maxInt(b.Min.Y+(y+1)*b.Dy()/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := maxInt(b.Min.X+(x+1)*b.Dx()/w, x0+1)
+			gray[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y][x] < gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// HammingDistance 两个哈希不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// averageLuma 区域平均亮度，大图按步长抽样以控制计算量
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	stepX := maxInt((x1-x0)/16, 1)
+	stepY := maxInt((y1-y0)/16, 1)
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf16"
+)
+
+// SimplePDF 单页 A4 文本 PDF，使用阅读器内置的 STSong-Light 字体显示中文，无需嵌入字体文件
+type SimplePDF struct {
+	content bytes.Buffer
+}
+
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+func NewSimplePDF() *SimplePDF {
+	return &SimplePDF{}
+}
+
+// Text 在 (x, y) 处输出一行文字，坐标原点在页面左下角
+func (p *SimplePDF) Text(x, y, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, pdfHexUTF16(s))
+}
+
+// Line 画一条细线
+func (p *SimplePDF) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// TextWidth 估算文字宽度：ASCII 按半角，其余按全角
+func (p *SimplePDF) TextWidth(s string, size float64) float64 {
+	w := 0.0
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			w += 0.5
+		} else {
+			w += 1
+		}
+	}
+	return w * size
+}
+
+// Bytes 生成完整的 PDF 文件内容
+func (p *SimplePDF) Bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>", PDFPageWidth, PDFPageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 7 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func pdfHexUTF16(s string) string {
+	var buf bytes.Buffer
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&buf, "%04X", u)
+	}
+	return buf.String()
+}